@@ -0,0 +1,241 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Algorithm fully describes a key derivation setup, apart from the secret key
+// and the inputs that vary between derivations. It has a canonical string
+// representation of the form:
+//
+//	SP800-108-<mode>-<PRF>/R<counter width>[/<counter location>]/L<length width>[/LBYTES][/LFIRST]
+//
+// where mode is one of CTR, FB or DP for counter, feedback and double-pipeline
+// mode, PRF is the name of a PRF that can be created with NewPRFByName, and
+// counter location is one of BEFORE_FIXED, AFTER_FIXED or BEFORE_ITER, using the
+// names from the NIST CAVP test vectors. A counter width of zero indicates that
+// no counter is used, and a length width of zero indicates that the length field
+// is omitted. LBYTES indicates that the length field contains the length in bytes
+// and LFIRST indicates that it is placed before the label. For example,
+// "SP800-108-CTR-HMAC-SHA-256/R32/L32" describes the counter mode KDF with
+// HMAC-SHA-256 and the layouts recommended by NIST SP-800-108.
+type Algorithm struct {
+	Mode            Mode
+	PRF             string
+	CounterWidth    int
+	CounterLocation CounterLocation
+	LengthWidth     int
+	LengthInBytes   bool
+	LengthLocation  LengthLocation
+}
+
+const algorithmPrefix = "SP800-108-"
+
+var (
+	modeIdentifiers = map[Mode]string{
+		CounterMode:  "CTR",
+		FeedbackMode: "FB",
+		PipelineMode: "DP"}
+	counterLocationIdentifiers = map[CounterLocation]string{
+		CounterBeforeFixed: "BEFORE_FIXED",
+		CounterAfterFixed:  "AFTER_FIXED",
+		CounterBeforeIter:  "BEFORE_ITER"}
+)
+
+// parseWidth parses a counter or length field width from an algorithm
+// identifier, where zero indicates that the field is omitted.
+func parseWidth(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || (n != 0 && !validCounterWidth(n)) {
+		return 0, false
+	}
+	return n, true
+}
+
+// ParseAlgorithm parses an algorithm identifier in the form described by
+// Algorithm. The PRF name is matched against the names of the available PRFs
+// case-insensitively and ignoring hyphens, and the returned Algorithm contains
+// the canonical name of the matching PRF.
+func ParseAlgorithm(s string) (*Algorithm, error) {
+	if !strings.HasPrefix(s, algorithmPrefix) {
+		return nil, fmt.Errorf("invalid algorithm %q: missing %q prefix", s, algorithmPrefix)
+	}
+	rest := s[len(algorithmPrefix):]
+
+	a := new(Algorithm)
+
+	modeId, rest, ok := strings.Cut(rest, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid algorithm %q: missing PRF", s)
+	}
+	found := false
+	for m, id := range modeIdentifiers {
+		if modeId == id {
+			a.Mode = m
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid algorithm %q: unknown mode %q", s, modeId)
+	}
+
+	// The options are parsed from the end, as the PRF name may contain '/'.
+	tokens := strings.Split(rest, "/")
+	var haveCounter, haveLength bool
+Options:
+	for len(tokens) > 1 {
+		opt := tokens[len(tokens)-1]
+		switch {
+		case opt == "LBYTES":
+			a.LengthInBytes = true
+		case opt == "LFIRST":
+			a.LengthLocation = LengthBeforeLabel
+		case strings.HasPrefix(opt, "R"):
+			if a.CounterWidth, ok = parseWidth(opt[1:]); !ok {
+				return nil, fmt.Errorf("invalid algorithm %q: invalid counter width %q", s, opt[1:])
+			}
+			haveCounter = true
+		case strings.HasPrefix(opt, "L"):
+			if a.LengthWidth, ok = parseWidth(opt[1:]); !ok {
+				return nil, fmt.Errorf("invalid algorithm %q: invalid length width %q", s, opt[1:])
+			}
+			haveLength = true
+		default:
+			found := false
+			for l, id := range counterLocationIdentifiers {
+				if opt == id {
+					a.CounterLocation = l
+					found = true
+				}
+			}
+			if !found {
+				break Options
+			}
+		}
+		tokens = tokens[:len(tokens)-1]
+	}
+	if !haveCounter {
+		return nil, fmt.Errorf("invalid algorithm %q: missing counter width", s)
+	}
+	if !haveLength {
+		return nil, fmt.Errorf("invalid algorithm %q: missing length width", s)
+	}
+	if a.LengthWidth == 0 {
+		if a.LengthInBytes || a.LengthLocation != LengthAfterContext {
+			return nil, fmt.Errorf("invalid algorithm %q: length options supplied without a length field", s)
+		}
+		a.LengthLocation = LengthOmitted
+	}
+
+	name := strings.Join(tokens, "/")
+	if a.PRF, ok = canonicalPRFName(name); !ok {
+		return nil, fmt.Errorf("invalid algorithm %q: unknown PRF %q", s, name)
+	}
+	return a, nil
+}
+
+// String returns the canonical identifier for this algorithm.
+func (a *Algorithm) String() string {
+	var b strings.Builder
+	b.WriteString(algorithmPrefix)
+	if id, ok := modeIdentifiers[a.Mode]; ok {
+		b.WriteString(id)
+	} else {
+		b.WriteString(a.Mode.String())
+	}
+	b.WriteString("-" + a.PRF)
+	fmt.Fprintf(&b, "/R%d", a.CounterWidth)
+	if a.CounterLocation != CounterBeforeFixed {
+		if id, ok := counterLocationIdentifiers[a.CounterLocation]; ok {
+			b.WriteString("/" + id)
+		} else {
+			fmt.Fprintf(&b, "/CounterLocation(%d)", a.CounterLocation)
+		}
+	}
+	if a.LengthLocation == LengthOmitted {
+		b.WriteString("/L0")
+		return b.String()
+	}
+	width := a.LengthWidth
+	if width == 0 {
+		width = 32
+	}
+	fmt.Fprintf(&b, "/L%d", width)
+	if a.LengthInBytes {
+		b.WriteString("/LBYTES")
+	}
+	if a.LengthLocation == LengthBeforeLabel {
+		b.WriteString("/LFIRST")
+	}
+	return b.String()
+}
+
+// Set sets this algorithm from the supplied identifier in the same way as
+// ParseAlgorithm. It implements flag.Value.
+func (a *Algorithm) Set(s string) error {
+	parsed, err := ParseAlgorithm(s)
+	if err != nil {
+		return err
+	}
+	*a = *parsed
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a *Algorithm) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *Algorithm) UnmarshalText(text []byte) error {
+	return a.Set(string(text))
+}
+
+// Params returns the parameters for a derivation with this algorithm, using the
+// supplied secret key. The caller should set the remaining fields, such as the
+// label, context and length, before using the parameters.
+func (a *Algorithm) Params(key []byte) (*Params, error) {
+	if a.PRF == "" {
+		return nil, ErrNoPRF
+	}
+	prf, err := NewPRFByName(a.PRF)
+	if err != nil {
+		return nil, err
+	}
+	return &Params{
+		PRF:             prf,
+		Mode:            a.Mode,
+		Key:             key,
+		CounterWidth:    a.CounterWidth,
+		CounterLocation: a.CounterLocation,
+		LengthWidth:     a.LengthWidth,
+		LengthInBytes:   a.LengthInBytes,
+		LengthLocation:  a.LengthLocation}, nil
+}
+
+// Derive derives a key of the specified length with this algorithm, using the
+// supplied secret key, label and context. It implements Deriver. An error is
+// returned if the algorithm or any of the inputs are invalid.
+//
+// Feedback mode requires an IV, which can't be supplied to this function, so an
+// error is always returned for a feedback mode algorithm. Keys for these should
+// be derived by setting the IV in the parameters returned from Params instead.
+func (a *Algorithm) Derive(key, label, context []byte, bitLength uint32) ([]byte, error) {
+	if a.Mode == FeedbackMode {
+		return nil, newError(ErrBadIVLength, "algorithm %s requires an IV, which must be supplied with Params", a)
+	}
+	params, err := a.Params(key)
+	if err != nil {
+		return nil, err
+	}
+	params.Label = label
+	params.Context = context
+	params.BitLength = bitLength
+	return Derive(params)
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"errors"
+	"flag"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type algorithmSuite struct{}
+
+var _ = Suite(&algorithmSuite{})
+
+type testParseAlgorithmData struct {
+	id       string
+	expected *Algorithm
+	str      string
+}
+
+func (s *algorithmSuite) testParseAlgorithm(c *C, data *testParseAlgorithmData) {
+	a, err := ParseAlgorithm(data.id)
+	c.Assert(err, IsNil)
+	c.Check(a, DeepEquals, data.expected)
+	c.Check(a.String(), Equals, data.str)
+}
+
+func (s *algorithmSuite) TestParseAlgorithmCounter(c *C) {
+	s.testParseAlgorithm(c, &testParseAlgorithmData{
+		id:       "SP800-108-CTR-HMAC-SHA-256/R32/L32",
+		expected: &Algorithm{Mode: CounterMode, PRF: "HMAC-SHA-256", CounterWidth: 32, LengthWidth: 32},
+		str:      "SP800-108-CTR-HMAC-SHA-256/R32/L32"})
+}
+
+func (s *algorithmSuite) TestParseAlgorithmNonCanonicalPRF(c *C) {
+	s.testParseAlgorithm(c, &testParseAlgorithmData{
+		id:       "SP800-108-CTR-HMAC-SHA256/R32/L32",
+		expected: &Algorithm{Mode: CounterMode, PRF: "HMAC-SHA-256", CounterWidth: 32, LengthWidth: 32},
+		str:      "SP800-108-CTR-HMAC-SHA-256/R32/L32"})
+}
+
+func (s *algorithmSuite) TestParseAlgorithmPRFWithSlash(c *C) {
+	s.testParseAlgorithm(c, &testParseAlgorithmData{
+		id:       "SP800-108-CTR-HMAC-SHA-512/256/R8/AFTER_FIXED/L16",
+		expected: &Algorithm{Mode: CounterMode, PRF: "HMAC-SHA-512/256", CounterWidth: 8, CounterLocation: CounterAfterFixed, LengthWidth: 16},
+		str:      "SP800-108-CTR-HMAC-SHA-512/256/R8/AFTER_FIXED/L16"})
+}
+
+func (s *algorithmSuite) TestParseAlgorithmFeedback(c *C) {
+	s.testParseAlgorithm(c, &testParseAlgorithmData{
+		id:       "SP800-108-FB-CMAC-AES128/R0/L64/LBYTES/LFIRST",
+		expected: &Algorithm{Mode: FeedbackMode, PRF: "CMAC-AES128", LengthWidth: 64, LengthInBytes: true, LengthLocation: LengthBeforeLabel},
+		str:      "SP800-108-FB-CMAC-AES128/R0/L64/LBYTES/LFIRST"})
+}
+
+func (s *algorithmSuite) TestParseAlgorithmPipeline(c *C) {
+	s.testParseAlgorithm(c, &testParseAlgorithmData{
+		id:       "SP800-108-DP-HMAC-SHA-1/R16/BEFORE_ITER/L0",
+		expected: &Algorithm{Mode: PipelineMode, PRF: "HMAC-SHA-1", CounterWidth: 16, CounterLocation: CounterBeforeIter, LengthLocation: LengthOmitted},
+		str:      "SP800-108-DP-HMAC-SHA-1/R16/BEFORE_ITER/L0"})
+}
+
+func (s *algorithmSuite) TestAlgorithmStringDefaultLengthWidth(c *C) {
+	a := &Algorithm{Mode: CounterMode, PRF: "KMAC128", CounterWidth: 32}
+	c.Check(a.String(), Equals, "SP800-108-CTR-KMAC128/R32/L32")
+}
+
+func (s *algorithmSuite) TestParseAlgorithmErrors(c *C) {
+	for _, t := range []struct {
+		id  string
+		err string
+	}{
+		{"HMAC-SHA-256/R32/L32", `invalid algorithm "HMAC-SHA-256/R32/L32": missing "SP800-108-" prefix`},
+		{"SP800-108-CTR", `invalid algorithm "SP800-108-CTR": missing PRF`},
+		{"SP800-108-XX-HMAC-SHA-256/R32/L32", `invalid algorithm ".*": unknown mode "XX"`},
+		{"SP800-108-CTR-HMAC-SHA-256/R12/L32", `invalid algorithm ".*": invalid counter width "12"`},
+		{"SP800-108-CTR-HMAC-SHA-256/R32/Lx", `invalid algorithm ".*": invalid length width "x"`},
+		{"SP800-108-CTR-HMAC-SHA-256/L32", `invalid algorithm ".*": missing counter width`},
+		{"SP800-108-CTR-HMAC-SHA-256/R32", `invalid algorithm ".*": missing length width`},
+		{"SP800-108-CTR-HMAC-SHA-256/R32/L0/LBYTES", `invalid algorithm ".*": length options supplied without a length field`},
+		{"SP800-108-CTR-HMAC-MD4/R32/L32", `invalid algorithm ".*": unknown PRF "HMAC-MD4"`},
+	} {
+		_, err := ParseAlgorithm(t.id)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.id))
+	}
+}
+
+func (s *algorithmSuite) TestAlgorithmParams(c *C) {
+	a, err := ParseAlgorithm("SP800-108-CTR-HMAC-SHA-256/R32/L32")
+	c.Assert(err, IsNil)
+
+	params, err := a.Params([]byte("1234567890123456"))
+	c.Assert(err, IsNil)
+	params.Label = []byte("foo")
+	params.Context = []byte("bar")
+	params.BitLength = 256
+
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKey(NewHMACPRF(crypto.SHA256), params.Key, params.Label, params.Context, params.BitLength))
+}
+
+func (s *algorithmSuite) TestAlgorithmDeriveFeedback(c *C) {
+	a, err := ParseAlgorithm("SP800-108-FB-HMAC-SHA-256/R32/L32")
+	c.Assert(err, IsNil)
+
+	_, err = a.Derive([]byte("1234567890123456"), []byte("foo"), []byte("bar"), 256)
+	c.Check(err, ErrorMatches, `algorithm SP800-108-FB-HMAC-SHA-256/R32/L32 requires an IV, which must be supplied with Params`)
+	c.Check(errors.Is(err, ErrBadIVLength), Equals, true)
+
+	// The same algorithm works when the IV is supplied via Params.
+	params, err := a.Params([]byte("1234567890123456"))
+	c.Assert(err, IsNil)
+	params.Label = []byte("foo")
+	params.Context = []byte("bar")
+	params.IV = make([]byte, 32)
+	params.BitLength = 256
+
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, HasLen, 32)
+}
+
+func (s *algorithmSuite) TestAlgorithmParamsUnknownPRF(c *C) {
+	a := &Algorithm{PRF: "foo"}
+	_, err := a.Params(nil)
+	c.Check(err, ErrorMatches, `unknown PRF "foo"`)
+}
+
+func (s *algorithmSuite) TestAlgorithmFlag(c *C) {
+	var a Algorithm
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&a, "algorithm", "")
+
+	c.Check(fs.Parse([]string{"-algorithm", "SP800-108-FB-HMAC-SHA-256/R8/L16"}), IsNil)
+	c.Check(a, DeepEquals, Algorithm{Mode: FeedbackMode, PRF: "HMAC-SHA-256", CounterWidth: 8, LengthWidth: 16})
+
+	text, err := a.MarshalText()
+	c.Check(err, IsNil)
+	c.Check(string(text), Equals, "SP800-108-FB-HMAC-SHA-256/R8/L16")
+}
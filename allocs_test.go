@@ -0,0 +1,75 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"testing"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+// allocsSuite checks the number of allocations performed by the derivations
+// that are expected to be used in hot paths, which should only allocate the
+// derived key.
+type allocsSuite struct{}
+
+var _ = Suite(&allocsSuite{})
+
+func (s *allocsSuite) SetUpTest(c *C) {
+	if raceEnabled {
+		c.Skip("allocation counts are unreliable with the race detector")
+	}
+}
+
+func (s *allocsSuite) testKDFDerive(c *C, prf PRF, key []byte, bitLength uint32) {
+	kdf := NewKDF(prf, key)
+	context := make([]byte, 8)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		kdf.Derive([]byte("label"), context, bitLength)
+	})
+	c.Check(allocs <= 1, Equals, true, Commentf("%v, %d bits: %v allocations", prf, bitLength, allocs))
+}
+
+func (s *allocsSuite) TestKDFDeriveHMACSHA256(c *C) {
+	s.testKDFDerive(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 256)
+}
+
+func (s *allocsSuite) TestKDFDeriveHMACSHA256Truncated(c *C) {
+	s.testKDFDerive(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 100)
+}
+
+func (s *allocsSuite) TestKDFDeriveHMACSHA256MultipleBlocks(c *C) {
+	s.testKDFDerive(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 4096)
+}
+
+func (s *allocsSuite) TestKDFDeriveHMACSHA512(c *C) {
+	s.testKDFDerive(c, NewHMACPRF(crypto.SHA512), decodeHexString(c, hmacTestKey), 512)
+}
+
+func (s *allocsSuite) TestKDFDeriveCMACAES128(c *C) {
+	s.testKDFDerive(c, NewCMACAESPRF(16), decodeHexString(c, "000102030405060708090a0b0c0d0e0f"), 128)
+}
+
+func (s *allocsSuite) TestKDFDeriveCMACAES256MultipleBlocks(c *C) {
+	s.testKDFDerive(c, NewCMACAESPRF(32), decodeHexString(c, hmacTestKey), 1024)
+}
+
+func (s *allocsSuite) TestKDFDeriveMany(c *C) {
+	kdf := NewKDF(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+	reqs := []DeriveRequest{
+		{Label: []byte("foo"), Context: []byte("1"), BitLength: 256},
+		{Label: []byte("bar"), Context: []byte("2"), BitLength: 1000},
+		{Label: []byte("baz"), Context: []byte("3"), BitLength: 128}}
+
+	// DeriveMany allocates the buffer for the keys and the slice of keys.
+	allocs := testing.AllocsPerRun(100, func() {
+		kdf.DeriveMany(reqs)
+	})
+	c.Check(allocs <= 2, Equals, true, Commentf("%v allocations", allocs))
+}
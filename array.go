@@ -0,0 +1,39 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+// KeyArray is the set of fixed size array types that DeriveArray can derive
+// keys in to.
+type KeyArray interface {
+	[16]byte | [24]byte | [32]byte | [48]byte | [64]byte
+}
+
+// DeriveArray derives a key in the same way as Derive, directly in to a value of
+// the specified array type. The length of the derived key is the length of the
+// array, and the BitLength field of the supplied parameters is ignored.
+func DeriveArray[T KeyArray](params *Params) (T, error) {
+	var key T
+
+	var dst []byte
+	switch k := any(&key).(type) {
+	case *[16]byte:
+		dst = k[:]
+	case *[24]byte:
+		dst = k[:]
+	case *[32]byte:
+		dst = k[:]
+	case *[48]byte:
+		dst = k[:]
+	case *[64]byte:
+		dst = k[:]
+	}
+
+	p := *params
+	p.BitLength = uint32(len(dst) * 8)
+	if _, err := DeriveInto(dst, &p); err != nil {
+		return key, err
+	}
+	return key, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type arraySuite struct{}
+
+var _ = Suite(&arraySuite{})
+
+func (s *arraySuite) TestDeriveArray16(c *C) {
+	params := testParams()
+	key, err := DeriveArray[[16]byte](params)
+	c.Check(err, IsNil)
+	c.Check(key[:], DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 128))
+}
+
+func (s *arraySuite) TestDeriveArray48(c *C) {
+	params := testParams()
+	key, err := DeriveArray[[48]byte](params)
+	c.Check(err, IsNil)
+	c.Check(key[:], DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 384))
+}
+
+func (s *arraySuite) TestDeriveArrayInvalid(c *C) {
+	params := testParams()
+	params.CounterWidth = 0
+	key, err := DeriveArray[[32]byte](params)
+	c.Check(err, ErrorMatches, "counter mode requires a counter")
+	c.Check(key, Equals, [32]byte{})
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package ascon provides a PRF based on Ascon-Mac, for use with the key derivation
+functions in the parent package. Ascon is a lightweight design that performs well
+on constrained devices where software implementations of SHA-2 are slow.
+
+This implements Ascon-Mac as specified in version 1.2 of the Ascon submission,
+which produces a 128-bit tag using a 128-bit key. Ascon-Mac is not approved by
+NIST.
+*/
+package ascon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+const (
+	// KeySize is the size of an Ascon-Mac key in bytes.
+	KeySize = 16
+
+	// Size is the size of an Ascon-Mac tag in bytes.
+	Size = 16
+
+	// BlockSize is the input rate of Ascon-Mac in bytes.
+	BlockSize = 32
+
+	macIV = 0x80808c0000000080
+)
+
+type state [5]uint64
+
+// permute applies the Ascon permutation with the specified number of rounds.
+func (s *state) permute(rounds int) {
+	for r := 12 - rounds; r < 12; r++ {
+		// Round constant addition.
+		s[2] ^= uint64(0xf0 - r*0x0f)
+
+		// Substitution layer.
+		s[0] ^= s[4]
+		s[4] ^= s[3]
+		s[2] ^= s[1]
+		t0 := ^s[0] & s[1]
+		t1 := ^s[1] & s[2]
+		t2 := ^s[2] & s[3]
+		t3 := ^s[3] & s[4]
+		t4 := ^s[4] & s[0]
+		s[0] ^= t1
+		s[1] ^= t2
+		s[2] ^= t3
+		s[3] ^= t4
+		s[4] ^= t0
+		s[1] ^= s[0]
+		s[0] ^= s[4]
+		s[3] ^= s[2]
+		s[2] = ^s[2]
+
+		// Linear diffusion layer.
+		s[0] ^= bits.RotateLeft64(s[0], -19) ^ bits.RotateLeft64(s[0], -28)
+		s[1] ^= bits.RotateLeft64(s[1], -61) ^ bits.RotateLeft64(s[1], -39)
+		s[2] ^= bits.RotateLeft64(s[2], -1) ^ bits.RotateLeft64(s[2], -6)
+		s[3] ^= bits.RotateLeft64(s[3], -10) ^ bits.RotateLeft64(s[3], -17)
+		s[4] ^= bits.RotateLeft64(s[4], -7) ^ bits.RotateLeft64(s[4], -41)
+	}
+}
+
+// mac computes Ascon-Mac of msg with the supplied 16 byte key.
+func mac(key, msg []byte) []byte {
+	s := state{
+		macIV,
+		binary.BigEndian.Uint64(key[0:]),
+		binary.BigEndian.Uint64(key[8:]),
+		0, 0}
+	s.permute(12)
+
+	for ; len(msg) >= BlockSize; msg = msg[BlockSize:] {
+		for i := 0; i < 4; i++ {
+			s[i] ^= binary.BigEndian.Uint64(msg[i*8:])
+		}
+		s.permute(12)
+	}
+
+	var last [BlockSize]byte
+	copy(last[:], msg)
+	last[len(msg)] = 0x80
+	for i := 0; i < 4; i++ {
+		s[i] ^= binary.BigEndian.Uint64(last[i*8:])
+	}
+	s[4] ^= 1
+	s.permute(12)
+
+	var out [Size]byte
+	binary.BigEndian.PutUint64(out[0:], s[0])
+	binary.BigEndian.PutUint64(out[8:], s[1])
+	return out[:]
+}
+
+type macPRF struct{}
+
+func (macPRF) Len() uint32 {
+	return Size
+}
+
+func (macPRF) String() string {
+	return "Ascon-Mac"
+}
+
+func (macPRF) BlockSize() int {
+	return BlockSize
+}
+
+func (macPRF) KeySize() int {
+	return KeySize
+}
+
+func (p macPRF) CheckKeyLength(n int) error {
+	if n != KeySize {
+		return fmt.Errorf("invalid key length %d for %v (expected %d bytes)", n, p, KeySize)
+	}
+	return nil
+}
+
+func (p macPRF) Run(s, x []byte) []byte {
+	if err := p.CheckKeyLength(len(s)); err != nil {
+		panic(err)
+	}
+	return mac(s, x)
+}
+
+// NewMACPRF creates a new PRF based on Ascon-Mac. The PRF will panic if it is
+// supplied with a key that isn't 16 bytes long.
+func NewMACPRF() kdf.PRF {
+	return macPRF{}
+}
+
+func init() {
+	kdf.RegisterPRF("Ascon-Mac", NewMACPRF)
+}
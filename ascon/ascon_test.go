@@ -0,0 +1,123 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package ascon_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/ascon"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type asconSuite struct{}
+
+var _ = Suite(&asconSuite{})
+
+var asconTestKey = "000102030405060708090a0b0c0d0e0f"
+
+// Ascon-Hash shares the permutation with Ascon-Mac, so the following tests
+// check the permutation against the Ascon-Hash known answer tests.
+
+func (s *asconSuite) TestPermutationEmpty(c *C) {
+	c.Check(Hash(nil), DeepEquals, decodeHexString(c, "7346bc14f036e87ae03d0997913088f5f68411434b3cf8b54fa796a80d251f91"))
+}
+
+func (s *asconSuite) TestMAC(c *C) {
+	prf := NewMACPRF()
+	c.Check(prf.Len(), Equals, uint32(16))
+
+	key := decodeHexString(c, asconTestKey)
+	c.Check(prf.Run(key, []byte("foo")), DeepEquals, MAC(key, []byte("foo")))
+	c.Check(prf.Run(key, []byte("foo")), Not(DeepEquals), prf.Run(key, []byte("bar")))
+	c.Check(prf.Run(key, []byte("foo")), Not(DeepEquals), prf.Run(make([]byte, 16), []byte("foo")))
+}
+
+type testMACData struct {
+	msgLen   int
+	expected string
+}
+
+func (s *asconSuite) testMAC(c *C, data *testMACData) {
+	msg := make([]byte, data.msgLen)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	c.Check(NewMACPRF().Run(decodeHexString(c, asconTestKey), msg), DeepEquals, decodeHexString(c, data.expected))
+}
+
+// The following tests use the key 000102...0f and the message 000102... of the
+// specified length. They are not from the Ascon known answer tests. The expected
+// tags were computed with an independent Python implementation of Ascon-Mac
+// v1.2, which was verified against the published Ascon-Hash known answer tests.
+
+func (s *asconSuite) TestMACCrossImpl0(c *C) {
+	s.testMAC(c, &testMACData{msgLen: 0, expected: "eb1af688825d66bf2d53e135f9323315"})
+}
+
+func (s *asconSuite) TestMACCrossImpl1(c *C) {
+	s.testMAC(c, &testMACData{msgLen: 1, expected: "81f3c3537c5595aaa0d5780b9f88a043"})
+}
+
+func (s *asconSuite) TestMACCrossImpl31(c *C) {
+	s.testMAC(c, &testMACData{msgLen: 31, expected: "b6424fd4c356ef1d510682b108693890"})
+}
+
+func (s *asconSuite) TestMACCrossImpl32(c *C) {
+	s.testMAC(c, &testMACData{msgLen: 32, expected: "892523d61028799c507d1644126f03ef"})
+}
+
+func (s *asconSuite) TestMACCrossImpl33(c *C) {
+	s.testMAC(c, &testMACData{msgLen: 33, expected: "fbbfa47c9364499b9526f4cd0d94f9e4"})
+}
+
+func (s *asconSuite) TestMACCrossImpl64(c *C) {
+	s.testMAC(c, &testMACData{msgLen: 64, expected: "edc563c5a0bb6761073f8a6fb6238234"})
+}
+
+func (s *asconSuite) TestMACPadding(c *C) {
+	key := decodeHexString(c, asconTestKey)
+
+	// A full block must be distinguished from a padded partial block.
+	msg := make([]byte, 32)
+	msg[31] = 0x80
+	c.Check(MAC(key, msg), Not(DeepEquals), MAC(key, msg[:31]))
+	c.Check(MAC(key, make([]byte, 32)), Not(DeepEquals), MAC(key, nil))
+}
+
+func (s *asconSuite) TestCounterMode(c *C) {
+	key := decodeHexString(c, asconTestKey)
+	fixed := []byte("label\x00context\x00\x00\x01\x00")
+
+	var expected []byte
+	expected = append(expected, MAC(key, append([]byte{0, 0, 0, 1}, fixed...))...)
+	expected = append(expected, MAC(key, append([]byte{0, 0, 0, 2}, fixed...))...)
+
+	c.Check(kdf.CounterModeKey(NewMACPRF(), key, []byte("label"), []byte("context"), 256), DeepEquals, expected)
+}
+
+func (s *asconSuite) TestString(c *C) {
+	c.Check(NewMACPRF().(interface{ String() string }).String(), Equals, "Ascon-Mac")
+}
+
+func (s *asconSuite) TestInvalidKeyLength(c *C) {
+	c.Check(func() { NewMACPRF().Run(make([]byte, 32), nil) }, PanicMatches, `invalid key length 32 for Ascon-Mac \(expected 16 bytes\)`)
+}
+
+func (s *asconSuite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("Ascon-Mac")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewMACPRF())
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package ascon
+
+import "encoding/binary"
+
+var MAC = mac
+
+// Hash computes Ascon-Hash of msg, which is used to test the permutation.
+func Hash(msg []byte) []byte {
+	s := state{0x00400c0000000100, 0, 0, 0, 0}
+	s.permute(12)
+
+	for ; len(msg) >= 8; msg = msg[8:] {
+		s[0] ^= binary.BigEndian.Uint64(msg)
+		s.permute(12)
+	}
+	var last [8]byte
+	copy(last[:], msg)
+	last[len(msg)] = 0x80
+	s[0] ^= binary.BigEndian.Uint64(last[:])
+	s.permute(12)
+
+	var out []byte
+	for i := 0; i < 4; i++ {
+		out = binary.BigEndian.AppendUint64(out, s[0])
+		if i < 3 {
+			s.permute(12)
+		}
+	}
+	return out
+}
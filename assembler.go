@@ -0,0 +1,268 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// FixedDataAssembler assembles the input to the PRF for each iteration of a key
+// derivation. It provides a way to customize the layout of the PRF input in any
+// of the iteration modes.
+type FixedDataAssembler interface {
+	// Assemble returns the input to the PRF for the block with the specified
+	// zero-based index, where counter is the value of the iteration counter
+	// for that block. The chain argument is the iteration variable, which is
+	// K(i-1) in feedback mode and A(i) in double-pipeline mode. It is nil in
+	// counter mode.
+	Assemble(counter uint64, index uint32, chain []byte) []byte
+}
+
+// CounterLocation describes the location of the iteration counter in the input
+// to the PRF.
+type CounterLocation int
+
+const (
+	// CounterBeforeFixed places the counter immediately before the fixed input
+	// data, and after the iteration variable in feedback and double-pipeline
+	// mode. This is the layout used by the functions in this package that
+	// don't take a FixedDataAssembler, and corresponds to the BEFORE_FIXED
+	// location for counter mode and the AFTER_ITER location for feedback and
+	// double-pipeline mode in the NIST CAVP test vectors.
+	CounterBeforeFixed CounterLocation = iota
+
+	// CounterAfterFixed places the counter after the fixed input data. This
+	// corresponds to the AFTER_FIXED location in the NIST CAVP test vectors,
+	// and can be used in any of the iteration modes.
+	CounterAfterFixed
+
+	// CounterBeforeIter places the counter before the iteration variable in
+	// feedback and double-pipeline mode. This corresponds to the BEFORE_ITER
+	// location in the NIST CAVP test vectors. In counter mode, there is no
+	// iteration variable and this is equivalent to CounterBeforeFixed.
+	CounterBeforeIter
+
+	// CounterMiddleFixed places the counter in the middle of the fixed input
+	// data, which is split in to the data before the counter and the data
+	// after the counter. This corresponds to the MIDDLE_FIXED location in
+	// the NIST CAVP test vectors.
+	CounterMiddleFixed
+
+	// CounterAfterIter places the counter after the iteration variable in
+	// feedback and double-pipeline mode. It is an alias for CounterBeforeFixed.
+	CounterAfterIter = CounterBeforeFixed
+)
+
+// LayoutAssembler is a FixedDataAssembler that produces the PRF input layouts
+// described in NIST SP-800-108 and the variations of these that are covered by
+// the NIST CAVP test vectors. The iteration counter is encoded as a big-endian
+// integer by default.
+type LayoutAssembler struct {
+	// FixedData is the fixed input data. When CounterLocation is
+	// CounterMiddleFixed, this is the part of the fixed input data that
+	// precedes the counter.
+	FixedData []byte
+
+	// FixedDataAfterCounter is the part of the fixed input data that
+	// follows the counter when CounterLocation is CounterMiddleFixed. It is
+	// ignored for other locations.
+	FixedDataAfterCounter []byte
+
+	// OmitCounter indicates that the iteration counter should not be
+	// included. This must be false for counter mode.
+	OmitCounter bool
+
+	// CounterLocation is the location of the iteration counter.
+	CounterLocation CounterLocation
+
+	// CounterWidth is the length of the iteration counter in bits, which
+	// must be 8, 16, 24, 32 or 64. Zero selects a 32-bit counter. Assemble will panic
+	// if the counter value doesn't fit in the selected width, unless
+	// CounterWrap is set.
+	CounterWidth int
+
+	// CounterLittleEndian indicates that the iteration counter should be
+	// encoded as a little-endian integer, as required by some protocols.
+	CounterLittleEndian bool
+
+	// CounterOffset is added to the value of the iteration counter, which
+	// starts at 1 for the first block. Set this to -1 to match
+	// implementations that start the counter at zero, or to the number of
+	// blocks that have already been produced in order to resume a partially
+	// completed derivation. Assemble will panic if the resulting counter value
+	// is negative.
+	CounterOffset int64
+
+	// CounterEncoder is used to encode the iteration counter if it is set,
+	// in which case CounterWidth and CounterLittleEndian are ignored.
+	CounterEncoder IntegerEncoder
+
+	// CounterWrap indicates that the iteration counter should wrap back to
+	// the value used for the first block when it overflows, instead of
+	// CheckIterations returning an error and Assemble panicking. In counter
+	// mode, this produces output that repeats every time the counter wraps.
+	//
+	// This is INSECURE. It violates the limit on the number of iterations in
+	// NIST SP-800-108 and must never be used for key derivation. It only
+	// exists for producing non-cryptographic deterministic streams that are
+	// longer than the counter permits.
+	CounterWrap bool
+}
+
+// validCounterWidth indicates whether the supplied counter width in bits is
+// supported.
+func validCounterWidth(width int) bool {
+	switch width {
+	case 8, 16, 24, 32, 64:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *LayoutAssembler) counterWidth() int {
+	if a.CounterWidth == 0 {
+		return 32
+	}
+	return a.CounterWidth
+}
+
+func (a *LayoutAssembler) counterEncoder() IntegerEncoder {
+	if a.CounterEncoder != nil {
+		return a.CounterEncoder
+	}
+	width := a.counterWidth()
+	if !validCounterWidth(width) {
+		panic(fmt.Sprintf("invalid counter width %d", width))
+	}
+	return FixedWidthEncoder{Width: width, LittleEndian: a.CounterLittleEndian}
+}
+
+func (a *LayoutAssembler) encodeCounter(counter uint64) []byte {
+	enc := a.counterEncoder()
+	if a.CounterOffset < 0 && counter < uint64(-a.CounterOffset) {
+		panic(fmt.Sprintf("iteration counter %d with offset %d is negative", counter, a.CounterOffset))
+	}
+	counter += uint64(a.CounterOffset)
+	if counter > enc.MaxValue() && a.CounterWrap {
+		first := uint64(0)
+		if a.CounterOffset > -1 {
+			first = uint64(a.CounterOffset) + 1
+		}
+		if first <= enc.MaxValue() {
+			counter = first + (counter-first)%(enc.MaxValue()-first+1)
+		}
+	}
+	if counter > enc.MaxValue() {
+		if e, ok := enc.(FixedWidthEncoder); ok {
+			panic(fmt.Sprintf("iteration counter %d overflows %d-bit counter", counter, e.Width))
+		}
+		panic(fmt.Sprintf("iteration counter %d exceeds the maximum value of %d", counter, enc.MaxValue()))
+	}
+
+	var ctr [8]byte
+	return enc.AppendInteger(ctr[:0], counter)
+}
+
+// Validate returns an error if this assembler is not correctly configured, in
+// which case Assemble will panic.
+func (a *LayoutAssembler) Validate() error {
+	switch a.CounterLocation {
+	case CounterBeforeFixed, CounterAfterFixed, CounterBeforeIter, CounterMiddleFixed:
+	default:
+		return fmt.Errorf("invalid counter location %d", a.CounterLocation)
+	}
+	if a.OmitCounter || a.CounterEncoder != nil {
+		return nil
+	}
+	if width := a.counterWidth(); !validCounterWidth(width) {
+		return newError(ErrUnsupportedCounterWidth, "invalid counter width %d", width)
+	}
+	return nil
+}
+
+// CheckIterations returns an error if the iteration counter cannot represent
+// the counter value for every block of a derivation that requires the specified
+// number of iterations. NIST SP-800-108 limits the number of iterations to
+// 2^r-1, where r is the width of the counter in bits. If CounterWrap is set, any
+// number of iterations is permitted as long as the counter can represent the
+// value for the first block.
+func (a *LayoutAssembler) CheckIterations(n uint32) error {
+	if a.OmitCounter || n == 0 {
+		return nil
+	}
+
+	max := a.counterEncoder().MaxValue()
+	var allowed uint64
+	switch {
+	case a.CounterOffset < -1:
+		return fmt.Errorf("iteration counter 1 with offset %d is negative", a.CounterOffset)
+	case a.CounterWrap && (a.CounterOffset == -1 || uint64(a.CounterOffset) < max):
+		return nil
+	case a.CounterOffset == -1:
+		allowed = max + 1
+		if allowed == 0 {
+			allowed = max
+		}
+	case uint64(a.CounterOffset) <= max:
+		allowed = max - uint64(a.CounterOffset)
+	}
+
+	if uint64(n) > allowed {
+		return newError(ErrOutputTooLong, "%d iterations are required but the iteration counter only permits %d", n, allowed)
+	}
+	return nil
+}
+
+// writeInput writes the input to the PRF for the block with the specified
+// counter value to the supplied writer, one segment at a time.
+func (a *LayoutAssembler) writeInput(w io.Writer, counter uint64, chain []byte) {
+	var ctr []byte
+	if !a.OmitCounter {
+		ctr = a.encodeCounter(counter)
+	}
+
+	switch a.CounterLocation {
+	case CounterBeforeFixed:
+		w.Write(chain)
+		w.Write(ctr)
+		w.Write(a.FixedData)
+	case CounterAfterFixed:
+		w.Write(chain)
+		w.Write(a.FixedData)
+		w.Write(ctr)
+	case CounterBeforeIter:
+		w.Write(ctr)
+		w.Write(chain)
+		w.Write(a.FixedData)
+	case CounterMiddleFixed:
+		w.Write(chain)
+		w.Write(a.FixedData)
+		w.Write(ctr)
+		w.Write(a.FixedDataAfterCounter)
+	default:
+		panic("invalid counter location")
+	}
+}
+
+func (a *LayoutAssembler) Assemble(counter uint64, _ uint32, chain []byte) []byte {
+	var x bytes.Buffer
+	a.writeInput(&x, counter, chain)
+	return x.Bytes()
+}
+
+// NewStandardAssembler returns a FixedDataAssembler that produces the PRF input
+// layouts defined in NIST SP-800-108 for the supplied fixed input data. This is
+// [i]2 || FixedData in counter mode, K(i-1) || [i]2 || FixedData in feedback mode
+// and A(i) || [i]2 || FixedData in double-pipeline mode, where [i]2 is a 32-bit
+// big-endian counter.
+//
+// The useCounter argument specifies whether the iteration counter should be
+// included. It should always be true for counter mode.
+func NewStandardAssembler(fixedData []byte, useCounter bool) FixedDataAssembler {
+	return &LayoutAssembler{FixedData: fixedData, OmitCounter: !useCounter}
+}
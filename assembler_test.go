@@ -0,0 +1,345 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type assemblerSuite struct{}
+
+var _ = Suite(&assemblerSuite{})
+
+type testAssemblerCall struct {
+	counter uint64
+	index   uint32
+	chain   []byte
+}
+
+// counterAfterFixedAssembler places an 8-bit counter after the fixed data and
+// records the arguments it is called with.
+type counterAfterFixedAssembler struct {
+	fixed []byte
+	calls []testAssemblerCall
+}
+
+func (a *counterAfterFixedAssembler) Assemble(counter uint64, index uint32, chain []byte) []byte {
+	a.calls = append(a.calls, testAssemblerCall{counter: counter, index: index, chain: chain})
+	x := append([]byte{}, chain...)
+	x = append(x, a.fixed...)
+	return append(x, byte(counter))
+}
+
+func (s *assemblerSuite) TestStandardAssembler(c *C) {
+	a := NewStandardAssembler([]byte("foo"), true)
+	c.Check(a.Assemble(1, 0, nil), DeepEquals, []byte{0, 0, 0, 1, 'f', 'o', 'o'})
+	c.Check(a.Assemble(0x01020304, 5, []byte("bar")), DeepEquals, []byte{'b', 'a', 'r', 1, 2, 3, 4, 'f', 'o', 'o'})
+}
+
+func (s *assemblerSuite) TestStandardAssemblerNoCounter(c *C) {
+	a := NewStandardAssembler([]byte("foo"), false)
+	c.Check(a.Assemble(1, 0, []byte("bar")), DeepEquals, []byte("barfoo"))
+}
+
+func (s *assemblerSuite) TestCounterModeStandard(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 600)
+	c.Check(CounterModeKeyWithAssembler(prf, key, NewStandardAssembler(fixed, true), 600), DeepEquals,
+		CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 600))
+}
+
+func (s *assemblerSuite) TestFeedbackModeStandard(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	iv := make([]byte, 32)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 600)
+	c.Check(FeedbackModeKeyWithAssembler(prf, key, NewStandardAssembler(fixed, true), iv, 600), DeepEquals,
+		FeedbackModeKey(prf, key, []byte("foo"), []byte("bar"), iv, 600, true))
+}
+
+func (s *assemblerSuite) TestPipelineModeStandard(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 600)
+	c.Check(PipelineModeKeyWithAssembler(prf, key, NewStandardAssembler(fixed, false), fixed, 600), DeepEquals,
+		PipelineModeKey(prf, key, []byte("foo"), []byte("bar"), 600, false))
+}
+
+func (s *assemblerSuite) TestCounterModeCustom(c *C) {
+	a := &counterAfterFixedAssembler{fixed: []byte("foo\x00bar")}
+	key := CounterModeKeyWithAssembler(NewHMACPRF(crypto.SHA256), []byte("1234567890123456"), a, 640)
+	c.Check(key, DeepEquals, decodeHexString(c, "7ebefaa3a89282fa971a0a763b3b0712b5c29472e4434d748616ba3cf98ee76f35fb15a144dd05381df2acf83348149268639dbf80a8f02150ad41595302665c9151f817464a815a1e8288aeab39776b"))
+	c.Check(a.calls, DeepEquals, []testAssemblerCall{
+		{counter: 1, index: 0},
+		{counter: 2, index: 1},
+		{counter: 3, index: 2}})
+}
+
+func (s *assemblerSuite) TestFeedbackModeCustom(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	iv := []byte("iv")
+
+	a := &counterAfterFixedAssembler{fixed: []byte("foo")}
+	k := FeedbackModeKeyWithAssembler(prf, key, a, iv, 512)
+	c.Assert(a.calls, HasLen, 2)
+	c.Check(a.calls[0].chain, DeepEquals, iv)
+	c.Check(a.calls[1].chain, DeepEquals, k[:32])
+	c.Check(k[32:], DeepEquals, prf.Run(key, append(append(k[:32:32], []byte("foo")...), 2)))
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterLocations(c *C) {
+	for _, t := range []struct {
+		location CounterLocation
+		expected []byte
+	}{
+		{location: CounterBeforeFixed, expected: []byte{'b', 'a', 'r', 0, 0, 0, 2, 'f', 'o', 'o'}},
+		{location: CounterAfterIter, expected: []byte{'b', 'a', 'r', 0, 0, 0, 2, 'f', 'o', 'o'}},
+		{location: CounterAfterFixed, expected: []byte{'b', 'a', 'r', 'f', 'o', 'o', 0, 0, 0, 2}},
+		{location: CounterBeforeIter, expected: []byte{0, 0, 0, 2, 'b', 'a', 'r', 'f', 'o', 'o'}},
+		{location: CounterMiddleFixed, expected: []byte{'b', 'a', 'r', 'f', 'o', 'o', 0, 0, 0, 2, 'b', 'a', 'z'}},
+	} {
+		a := &LayoutAssembler{FixedData: []byte("foo"), FixedDataAfterCounter: []byte("baz"), CounterLocation: t.location}
+		c.Check(a.Assemble(2, 1, []byte("bar")), DeepEquals, t.expected)
+	}
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerOmitCounter(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), OmitCounter: true, CounterLocation: CounterBeforeIter}
+	c.Check(a.Assemble(2, 1, []byte("bar")), DeepEquals, []byte("barfoo"))
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterWidths(c *C) {
+	for _, t := range []struct {
+		width    int
+		expected []byte
+	}{
+		{width: 0, expected: []byte{0, 0, 0, 2, 'f', 'o', 'o'}},
+		{width: 8, expected: []byte{2, 'f', 'o', 'o'}},
+		{width: 16, expected: []byte{0, 2, 'f', 'o', 'o'}},
+		{width: 24, expected: []byte{0, 0, 2, 'f', 'o', 'o'}},
+		{width: 32, expected: []byte{0, 0, 0, 2, 'f', 'o', 'o'}},
+		{width: 64, expected: []byte{0, 0, 0, 0, 0, 0, 0, 2, 'f', 'o', 'o'}},
+	} {
+		a := &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: t.width}
+		c.Check(a.Assemble(2, 1, nil), DeepEquals, t.expected)
+	}
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterLittleEndian(c *C) {
+	for _, t := range []struct {
+		width    int
+		expected []byte
+	}{
+		{width: 0, expected: []byte{2, 1, 0, 0, 'f', 'o', 'o'}},
+		{width: 16, expected: []byte{2, 1, 'f', 'o', 'o'}},
+		{width: 24, expected: []byte{2, 1, 0, 'f', 'o', 'o'}},
+		{width: 64, expected: []byte{2, 1, 0, 0, 0, 0, 0, 0, 'f', 'o', 'o'}},
+	} {
+		a := &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: t.width, CounterLittleEndian: true}
+		c.Check(a.Assemble(0x0102, 0x0101, nil), DeepEquals, t.expected)
+	}
+}
+
+func (s *assemblerSuite) TestCounterModeLittleEndianCounter(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 512)
+
+	var expected []byte
+	for i := byte(1); i <= 2; i++ {
+		expected = append(expected, prf.Run(key, append([]byte{i, 0}, fixed...))...)
+	}
+	c.Check(CounterModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed, CounterWidth: 16, CounterLittleEndian: true}, 512), DeepEquals, expected)
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterOffset(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: 8, CounterOffset: -1}
+	c.Check(a.Assemble(1, 0, nil), DeepEquals, []byte{0, 'f', 'o', 'o'})
+	c.Check(a.Assemble(2, 1, nil), DeepEquals, []byte{1, 'f', 'o', 'o'})
+
+	a = &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: 8, CounterOffset: 5}
+	c.Check(a.Assemble(1, 0, nil), DeepEquals, []byte{6, 'f', 'o', 'o'})
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterOffsetNegative(c *C) {
+	a := &LayoutAssembler{CounterOffset: -2}
+	c.Check(func() { a.Assemble(1, 0, nil) }, PanicMatches, "iteration counter 1 with offset -2 is negative")
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterOffsetOverflow(c *C) {
+	a := &LayoutAssembler{CounterWidth: 8, CounterOffset: 255}
+	c.Check(func() { a.Assemble(1, 0, nil) }, PanicMatches, "iteration counter 256 overflows 8-bit counter")
+}
+
+func (s *assemblerSuite) TestCounterModeResume(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1024)
+
+	full := CounterModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed}, 1024)
+	resumed := CounterModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed, CounterOffset: 2}, 512)
+	c.Check(resumed, DeepEquals, full[64:])
+}
+
+func (s *assemblerSuite) TestFeedbackModeResume(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1024)
+
+	full := FeedbackModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed}, nil, 1024)
+	resumed := FeedbackModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed, CounterOffset: 2}, full[32:64], 512)
+	c.Check(resumed, DeepEquals, full[64:])
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterOverflow(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: 8}
+	c.Check(a.Assemble(255, 254, nil), DeepEquals, []byte{255, 'f', 'o', 'o'})
+	c.Check(func() { a.Assemble(256, 255, nil) }, PanicMatches, "iteration counter 256 overflows 8-bit counter")
+
+	a.CounterWidth = 16
+	c.Check(a.Assemble(256, 255, nil), DeepEquals, []byte{1, 0, 'f', 'o', 'o'})
+	c.Check(func() { a.Assemble(65536, 65535, nil) }, PanicMatches, "iteration counter 65536 overflows 16-bit counter")
+
+	a.CounterWidth = 24
+	c.Check(a.Assemble(65536, 65535, nil), DeepEquals, []byte{1, 0, 0, 'f', 'o', 'o'})
+	c.Check(func() { a.Assemble(1<<24, 1<<24-1, nil) }, PanicMatches, "iteration counter 16777216 overflows 24-bit counter")
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerInvalidCounterWidth(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: 12}
+	c.Check(func() { a.Assemble(1, 0, nil) }, PanicMatches, "invalid counter width 12")
+}
+
+func (s *assemblerSuite) TestCounterMode64BitCounter(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 512)
+
+	var expected []byte
+	for i := byte(1); i <= 2; i++ {
+		expected = append(expected, prf.Run(key, append([]byte{0, 0, 0, 0, 0, 0, 0, i}, fixed...))...)
+	}
+	c.Check(CounterModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed, CounterWidth: 64}, 512), DeepEquals, expected)
+}
+
+// decimalEncoder is an IntegerEncoder that encodes values as a 3 digit ASCII
+// decimal string.
+type decimalEncoder struct{}
+
+func (decimalEncoder) MaxValue() uint64 {
+	return 999
+}
+
+func (decimalEncoder) AppendInteger(dst []byte, v uint64) []byte {
+	return append(dst, fmt.Sprintf("%03d", v)...)
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCustomEncoder(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), CounterEncoder: decimalEncoder{}, CounterWidth: 12}
+	c.Check(a.Assemble(42, 41, nil), DeepEquals, []byte("042foo"))
+	c.Check(func() { a.Assemble(1000, 999, nil) }, PanicMatches, "iteration counter 1000 exceeds the maximum value of 999")
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerFixedWidthEncoder(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), CounterEncoder: FixedWidthEncoder{Width: 16, LittleEndian: true}}
+	c.Check(a.Assemble(0x0102, 0x0101, nil), DeepEquals, []byte{2, 1, 'f', 'o', 'o'})
+	c.Check(func() { a.Assemble(65536, 65535, nil) }, PanicMatches, "iteration counter 65536 overflows 16-bit counter")
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCheckIterations(c *C) {
+	for _, t := range []struct {
+		a   *LayoutAssembler
+		n   uint32
+		err string
+	}{
+		{a: &LayoutAssembler{CounterWidth: 8}, n: 255},
+		{a: &LayoutAssembler{CounterWidth: 8}, n: 256, err: "256 iterations are required but the iteration counter only permits 255"},
+		{a: &LayoutAssembler{CounterWidth: 16}, n: 65535},
+		{a: &LayoutAssembler{CounterWidth: 16}, n: 65536, err: "65536 iterations are required but the iteration counter only permits 65535"},
+		{a: &LayoutAssembler{}, n: 0xffffffff},
+		{a: &LayoutAssembler{CounterWidth: 64}, n: 0xffffffff},
+		{a: &LayoutAssembler{CounterWidth: 8, OmitCounter: true}, n: 1000},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterOffset: -1}, n: 256},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterOffset: -1}, n: 257, err: "257 iterations are required but the iteration counter only permits 256"},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterOffset: 250}, n: 6, err: "6 iterations are required but the iteration counter only permits 5"},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterOffset: 300}, n: 1, err: "1 iterations are required but the iteration counter only permits 0"},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterOffset: -2}, n: 1, err: "iteration counter 1 with offset -2 is negative"},
+		{a: &LayoutAssembler{CounterEncoder: decimalEncoder{}}, n: 1000, err: "1000 iterations are required but the iteration counter only permits 999"},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterWrap: true}, n: 0xffffffff},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterWrap: true, CounterOffset: -1}, n: 0xffffffff},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterWrap: true, CounterOffset: 254}, n: 2},
+		{a: &LayoutAssembler{CounterWidth: 8, CounterWrap: true, CounterOffset: 255}, n: 1, err: "1 iterations are required but the iteration counter only permits 0"},
+		{a: &LayoutAssembler{CounterEncoder: decimalEncoder{}, CounterWrap: true}, n: 1000},
+	} {
+		err := t.a.CheckIterations(t.n)
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *assemblerSuite) TestCounterModeTooManyIterations(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 256*256)
+
+	var sink SliceSink
+	c.Check(func() {
+		CounterModeKeyToSink(prf, []byte("1234567890123456"), &LayoutAssembler{FixedData: fixed, CounterWidth: 8}, 256*256, &sink)
+	}, PanicMatches, "256 iterations are required but the iteration counter only permits 255")
+	c.Check(sink.Bytes(), HasLen, 0)
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerCounterWrap(c *C) {
+	a := &LayoutAssembler{FixedData: []byte("foo"), CounterWidth: 8, CounterWrap: true}
+	c.Check(a.Assemble(255, 254, nil), DeepEquals, []byte{255, 'f', 'o', 'o'})
+	c.Check(a.Assemble(256, 255, nil), DeepEquals, []byte{1, 'f', 'o', 'o'})
+	c.Check(a.Assemble(257, 256, nil), DeepEquals, []byte{2, 'f', 'o', 'o'})
+	c.Check(a.Assemble(511, 510, nil), DeepEquals, []byte{1, 'f', 'o', 'o'})
+
+	a.CounterOffset = -1
+	c.Check(a.Assemble(256, 255, nil), DeepEquals, []byte{255, 'f', 'o', 'o'})
+	c.Check(a.Assemble(257, 256, nil), DeepEquals, []byte{0, 'f', 'o', 'o'})
+
+	a.CounterOffset = 250
+	c.Check(a.Assemble(5, 4, nil), DeepEquals, []byte{255, 'f', 'o', 'o'})
+	c.Check(a.Assemble(6, 5, nil), DeepEquals, []byte{251, 'f', 'o', 'o'})
+}
+
+func (s *assemblerSuite) TestCounterModeCounterWrap(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 300*32*8)
+
+	_, err := ModeKeyWithAssemblerE(CounterMode, prf, key, &LayoutAssembler{FixedData: fixed, CounterWidth: 8}, nil, 300*32*8)
+	c.Check(err, ErrorMatches, "300 iterations are required but the iteration counter only permits 255")
+	c.Check(errors.Is(err, ErrOutputTooLong), Equals, true)
+
+	out, err := ModeKeyWithAssemblerE(CounterMode, prf, key, &LayoutAssembler{FixedData: fixed, CounterWidth: 8, CounterWrap: true}, nil, 300*32*8)
+	c.Assert(err, IsNil)
+	c.Check(out, HasLen, 300*32)
+
+	// The keystream repeats once the counter wraps from 255 back to 1.
+	c.Check(out[255*32:], DeepEquals, out[:45*32])
+	c.Check(out, DeepEquals, CounterModeKeyWithAssembler(prf, key, &LayoutAssembler{FixedData: fixed, CounterWidth: 8, CounterWrap: true}, 300*32*8))
+}
+
+func (s *assemblerSuite) TestLayoutAssemblerValidate(c *C) {
+	c.Check((&LayoutAssembler{}).Validate(), IsNil)
+	c.Check((&LayoutAssembler{CounterWidth: 64, CounterLocation: CounterMiddleFixed}).Validate(), IsNil)
+	c.Check((&LayoutAssembler{CounterWidth: 12, OmitCounter: true}).Validate(), IsNil)
+	c.Check((&LayoutAssembler{CounterWidth: 12, CounterEncoder: decimalEncoder{}}).Validate(), IsNil)
+	c.Check((&LayoutAssembler{CounterWidth: 12}).Validate(), ErrorMatches, "invalid counter width 12")
+	c.Check((&LayoutAssembler{CounterLocation: CounterLocation(10)}).Validate(), ErrorMatches, "invalid counter location 10")
+}
@@ -0,0 +1,28 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+)
+
+// DeriveEd25519 derives a 32-byte seed in the same way as Derive, and returns the
+// Ed25519 private key generated from it as described in RFC 8032. This allows a
+// device identity to be re-derived deterministically from a key derivation key.
+// The seed is wiped before returning, and the BitLength field of the supplied
+// parameters is ignored.
+func DeriveEd25519(params *Params) (ed25519.PrivateKey, error) {
+	return deriveAndUse(params, ed25519.SeedSize, func(seed []byte) (ed25519.PrivateKey, error) {
+		return ed25519.NewKeyFromSeed(seed), nil
+	})
+}
+
+// DeriveX25519 derives a 32-byte scalar in the same way as Derive, and returns
+// the X25519 private key that uses it. The scalar is wiped before returning,
+// and the BitLength field of the supplied parameters is ignored.
+func DeriveX25519(params *Params) (*ecdh.PrivateKey, error) {
+	return deriveAndUse(params, 32, ecdh.X25519().NewPrivateKey)
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type asymmetricSuite struct{}
+
+var _ = Suite(&asymmetricSuite{})
+
+func (s *asymmetricSuite) TestDeriveEd25519(c *C) {
+	params := testParams()
+	key, err := DeriveEd25519(params)
+	c.Assert(err, IsNil)
+
+	seed := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 256)
+	c.Check(key, DeepEquals, ed25519.NewKeyFromSeed(seed))
+
+	sig := ed25519.Sign(key, []byte("foo"))
+	c.Check(ed25519.Verify(key.Public().(ed25519.PublicKey), []byte("foo"), sig), Equals, true)
+}
+
+func (s *asymmetricSuite) TestDeriveEd25519Deterministic(c *C) {
+	key1, err := DeriveEd25519(testParams())
+	c.Assert(err, IsNil)
+	key2, err := DeriveEd25519(testParams())
+	c.Assert(err, IsNil)
+	c.Check(key1.Equal(key2), Equals, true)
+}
+
+func (s *asymmetricSuite) TestDeriveX25519(c *C) {
+	params := testParams()
+	key, err := DeriveX25519(params)
+	c.Assert(err, IsNil)
+
+	expected, err := ecdh.X25519().NewPrivateKey(CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 256))
+	c.Assert(err, IsNil)
+	c.Check(key.Equal(expected), Equals, true)
+}
+
+func (s *asymmetricSuite) TestDeriveInvalidParams(c *C) {
+	params := testParams()
+	params.PRF = nil
+
+	_, err := DeriveEd25519(params)
+	c.Check(err, ErrorMatches, "no PRF supplied")
+	_, err = DeriveX25519(params)
+	c.Check(err, ErrorMatches, "no PRF supplied")
+}
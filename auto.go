@@ -0,0 +1,31 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+// AutoMode derives a key using a mode that is selected based on which fields of
+// the supplied parameters are populated. If an IV is supplied without a counter
+// width, the key is derived in feedback mode without a counter. If a counter
+// width is supplied without an IV, the key is derived in counter mode. The Mode
+// field is ignored, and the key is otherwise derived in the same way as Derive,
+// so the remaining parameters are validated and applied as they are there.
+//
+// An error is returned if the mode cannot be selected unambiguously, ie, if both
+// or neither of the IV and counter width are supplied.
+func AutoMode(params *Params) ([]byte, error) {
+	p := *params
+
+	switch {
+	case p.IV != nil && p.CounterWidth != 0:
+		return nil, newError(ErrAmbiguousParams, "ambiguous parameters: both IV and counter width are supplied")
+	case p.IV != nil:
+		p.Mode = FeedbackMode
+	case p.CounterWidth != 0:
+		p.Mode = CounterMode
+	default:
+		return nil, newError(ErrAmbiguousParams, "ambiguous parameters: neither IV nor counter width are supplied")
+	}
+
+	return Derive(&p)
+}
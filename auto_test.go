@@ -0,0 +1,198 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"errors"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type autoSuite struct{}
+
+var _ = Suite(&autoSuite{})
+
+func (s *autoSuite) params() *Params {
+	params := testParams()
+	params.CounterWidth = 0
+	return params
+}
+
+func (s *autoSuite) TestFeedbackMode(c *C) {
+	params := s.params()
+	params.IV = make([]byte, 32)
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, FeedbackModeKey(params.PRF, params.Key, params.Label, params.Context, params.IV, params.BitLength, false))
+}
+
+func (s *autoSuite) TestFeedbackModeBadIVLength(c *C) {
+	params := s.params()
+	params.IV = []byte{1}
+
+	_, err := AutoMode(params)
+	c.Check(err, ErrorMatches, "IV length 1 does not match the PRF output length 32")
+	c.Check(errors.Is(err, ErrBadIVLength), Equals, true)
+}
+
+func (s *autoSuite) TestFeedbackModeAllowAnyIVLength(c *C) {
+	params := s.params()
+	params.IV = []byte{1}
+	params.AllowAnyIVLength = true
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, FeedbackModeKey(params.PRF, params.Key, params.Label, params.Context, params.IV, params.BitLength, false))
+}
+
+func (s *autoSuite) TestFeedbackModeEmptyIV(c *C) {
+	params := s.params()
+	params.IV = []byte{}
+	params.AllowAnyIVLength = true
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, FeedbackModeKey(params.PRF, params.Key, params.Label, params.Context, nil, params.BitLength, false))
+}
+
+func (s *autoSuite) TestCounterMode(c *C) {
+	params := s.params()
+	params.CounterWidth = 32
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, params.Context, params.BitLength))
+}
+
+func (s *autoSuite) TestCounterMode8Bit(c *C) {
+	params := s.params()
+	params.CounterWidth = 8
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKeyWithAssembler(params.PRF, params.Key,
+		&LayoutAssembler{FixedData: FixedBytes(params.Label, params.Context, params.BitLength), CounterWidth: 8}, params.BitLength))
+}
+
+func (s *autoSuite) TestCounterMode16Bit(c *C) {
+	params := s.params()
+	params.CounterWidth = 16
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKeyWithAssembler(params.PRF, params.Key,
+		&LayoutAssembler{FixedData: FixedBytes(params.Label, params.Context, params.BitLength), CounterWidth: 16}, params.BitLength))
+}
+
+func (s *autoSuite) TestCounterMode24Bit(c *C) {
+	params := s.params()
+	params.CounterWidth = 24
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKeyWithAssembler(params.PRF, params.Key,
+		&LayoutAssembler{FixedData: FixedBytes(params.Label, params.Context, params.BitLength), CounterWidth: 24}, params.BitLength))
+}
+
+func (s *autoSuite) TestCounterMode64Bit(c *C) {
+	params := s.params()
+	params.CounterWidth = 64
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKeyWithAssembler(params.PRF, params.Key,
+		&LayoutAssembler{FixedData: FixedBytes(params.Label, params.Context, params.BitLength), CounterWidth: 64}, params.BitLength))
+}
+
+func (s *autoSuite) TestCounterModeLayout(c *C) {
+	params := s.params()
+	params.CounterWidth = 32
+	params.CounterLocation = CounterAfterFixed
+	params.LengthInBytes = true
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+
+	params.Mode = CounterMode
+	expected, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, expected)
+	c.Check(key, Not(DeepEquals), CounterModeKey(params.PRF, params.Key, params.Label, params.Context, params.BitLength))
+}
+
+func (s *autoSuite) TestCounterModeTooLong(c *C) {
+	params := s.params()
+	params.CounterWidth = 8
+	params.BitLength = 256 * uint32(params.PRF.Len()) * 8
+
+	_, err := AutoMode(params)
+	c.Check(err, ErrorMatches, "bit length 65536 requires too many iterations for the 8-bit counter")
+}
+
+func (s *autoSuite) TestNoPRF(c *C) {
+	params := s.params()
+	params.PRF = nil
+	params.CounterWidth = 32
+
+	_, err := AutoMode(params)
+	c.Check(err, Equals, ErrNoPRF)
+
+	params.CounterWidth = 0
+	params.IV = make([]byte, 32)
+	_, err = AutoMode(params)
+	c.Check(err, Equals, ErrNoPRF)
+}
+
+func (s *autoSuite) TestShortKey(c *C) {
+	params := s.params()
+	params.CounterWidth = 32
+	params.Key = []byte("k")
+
+	for _, conditioning := range []KeyConditioning{RejectKey, TruncateKey, HashKey} {
+		params.KeyConditioning = conditioning
+		_, err := AutoMode(params)
+		c.Check(err, ErrorMatches, "key length 1 is shorter than the minimum of 14 bytes")
+		c.Check(errors.Is(err, ErrInvalidKeyLength), Equals, true)
+	}
+}
+
+func (s *autoSuite) TestKeyConditioning(c *C) {
+	params := s.params()
+	params.PRF = NewCMACAESPRF(16)
+	params.CounterWidth = 32
+	params.Key = []byte("123456789012345678901234")
+	params.KeyConditioning = TruncateKey
+
+	key, err := AutoMode(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKey(params.PRF, params.Key[:16], params.Label, params.Context, params.BitLength))
+}
+
+func (s *autoSuite) TestAmbiguousBoth(c *C) {
+	params := s.params()
+	params.IV = make([]byte, 32)
+	params.CounterWidth = 32
+
+	_, err := AutoMode(params)
+	c.Check(err, ErrorMatches, "ambiguous parameters: both IV and counter width are supplied")
+	c.Check(errors.Is(err, ErrAmbiguousParams), Equals, true)
+}
+
+func (s *autoSuite) TestAmbiguousNeither(c *C) {
+	_, err := AutoMode(s.params())
+	c.Check(err, ErrorMatches, "ambiguous parameters: neither IV nor counter width are supplied")
+	c.Check(errors.Is(err, ErrAmbiguousParams), Equals, true)
+}
+
+func (s *autoSuite) TestUnsupportedCounterWidth(c *C) {
+	params := s.params()
+	params.CounterWidth = 12
+
+	_, err := AutoMode(params)
+	c.Check(err, ErrorMatches, "unsupported counter width 12")
+}
@@ -0,0 +1,486 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+const benchmarkNumKeys = 1000
+
+var (
+	benchmarkKey   = make([]byte, 32)
+	benchmarkLabel = []byte("benchmark")
+)
+
+func benchmarkContexts() [][]byte {
+	contexts := make([][]byte, benchmarkNumKeys)
+	for i := range contexts {
+		contexts[i] = make([]byte, 8)
+		binary.BigEndian.PutUint64(contexts[i], uint64(i))
+	}
+	return contexts
+}
+
+// BenchmarkDerive1000Single derives benchmarkNumKeys keys by calling
+// CounterModeKey in a loop.
+func BenchmarkDerive1000Single(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	contexts := benchmarkContexts()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, context := range contexts {
+			CounterModeKey(prf, benchmarkKey, benchmarkLabel, context, 256)
+		}
+	}
+}
+
+// BenchmarkDerive1000Parallel derives benchmarkNumKeys keys by splitting the
+// contexts between one goroutine per CPU, each of which calls CounterModeKey
+// in a loop.
+func BenchmarkDerive1000Parallel(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	contexts := benchmarkContexts()
+	n := runtime.GOMAXPROCS(0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < n; j++ {
+			wg.Add(1)
+			go func(contexts [][]byte) {
+				defer wg.Done()
+				for _, context := range contexts {
+					CounterModeKey(prf, benchmarkKey, benchmarkLabel, context, 256)
+				}
+			}(contexts[j*len(contexts)/n : (j+1)*len(contexts)/n])
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkDerive1000Pool derives benchmarkNumKeys keys by submitting them to a
+// DeriverPool with one worker per CPU.
+func BenchmarkDerive1000Pool(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	contexts := benchmarkContexts()
+
+	pool := NewDeriverPool(prf, runtime.GOMAXPROCS(0))
+	defer pool.Close()
+
+	jobs := make([]*Job, len(contexts))
+	for i, context := range contexts {
+		jobs[i] = &Job{
+			Key:       benchmarkKey,
+			FixedData: FixedBytes(benchmarkLabel, context, 256),
+			BitLength: 256}
+	}
+	results := make([]<-chan []byte, len(jobs))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j, job := range jobs {
+			results[j] = pool.Submit(job)
+		}
+		for _, result := range results {
+			<-result
+		}
+	}
+}
+
+// BenchmarkDerive1000Many derives benchmarkNumKeys keys with a single call to
+// DeriveMany.
+func BenchmarkDerive1000Many(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	contexts := benchmarkContexts()
+
+	reqs := make([]DeriveRequest, len(contexts))
+	for i, context := range contexts {
+		reqs[i] = DeriveRequest{Label: benchmarkLabel, Context: context, BitLength: 256}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		DeriveMany(prf, benchmarkKey, reqs)
+	}
+}
+
+// BenchmarkDerive1000ManyParallel derives benchmarkNumKeys keys by splitting the
+// requests between one goroutine per CPU, each of which calls DeriveMany on a
+// shared KDF.
+func BenchmarkDerive1000ManyParallel(b *testing.B) {
+	k := NewKDF(NewHMACPRF(crypto.SHA256), benchmarkKey)
+	contexts := benchmarkContexts()
+	n := runtime.GOMAXPROCS(0)
+
+	reqs := make([]DeriveRequest, len(contexts))
+	for i, context := range contexts {
+		reqs[i] = DeriveRequest{Label: benchmarkLabel, Context: context, BitLength: 256}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < n; j++ {
+			wg.Add(1)
+			go func(reqs []DeriveRequest) {
+				defer wg.Done()
+				k.DeriveMany(reqs)
+			}(reqs[j*len(reqs)/n : (j+1)*len(reqs)/n])
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkDerive1000Label derives benchmarkNumKeys keys with a LabelDeriver,
+// which caches the PRF state over the key and label.
+func BenchmarkDerive1000Label(b *testing.B) {
+	d := NewLabelDeriver(NewHMACPRF(crypto.SHA256), benchmarkKey, benchmarkLabel)
+	contexts := benchmarkContexts()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, context := range contexts {
+			d.Derive(context, 256)
+		}
+	}
+}
+
+func benchmarkCounterModeKeyParallel(b *testing.B, workers int) {
+	prf := NewHMACPRF(crypto.SHA256)
+
+	b.SetBytes(1 << 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		CounterModeKeyParallel(prf, benchmarkKey, benchmarkLabel, nil, 8<<20, workers)
+	}
+}
+
+// BenchmarkCounterMode1MiBSerial derives a 1MiB key on a single goroutine.
+func BenchmarkCounterMode1MiBSerial(b *testing.B) {
+	benchmarkCounterModeKeyParallel(b, 1)
+}
+
+// BenchmarkCounterMode1MiBParallel derives a 1MiB key with the number of
+// workers selected automatically.
+func BenchmarkCounterMode1MiBParallel(b *testing.B) {
+	benchmarkCounterModeKeyParallel(b, 0)
+}
+
+// BenchmarkKDFDerive derives a single 256-bit key from a KDF, which only
+// allocates the derived key once its pool of scratch state is populated.
+func BenchmarkKDFDerive(b *testing.B) {
+	kdf := NewKDF(NewHMACPRF(crypto.SHA256), benchmarkKey)
+	context := make([]byte, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		kdf.Derive(benchmarkLabel, context, 256)
+	}
+}
+
+// BenchmarkKDFDeriveLong derives a single 4096-bit key from a KDF, which
+// requires multiple PRF invocations but still only allocates the derived key.
+func BenchmarkKDFDeriveLong(b *testing.B) {
+	kdf := NewKDF(NewHMACPRF(crypto.SHA256), benchmarkKey)
+	context := make([]byte, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		kdf.Derive(benchmarkLabel, context, 4096)
+	}
+}
+
+// BenchmarkCounterModeKeySingleBlock derives a 256-bit key with
+// CounterModeKey and HMAC-SHA-256, which requires a single PRF invocation.
+func BenchmarkCounterModeKeySingleBlock(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	context := make([]byte, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		CounterModeKey(prf, benchmarkKey, benchmarkLabel, context, 256)
+	}
+}
+
+// BenchmarkCounterModeKeySingleBlockTruncated derives a 128-bit key with
+// CounterModeKey and HMAC-SHA-256, which requires the single PRF output to be
+// truncated.
+func BenchmarkCounterModeKeySingleBlockTruncated(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	context := make([]byte, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		CounterModeKey(prf, benchmarkKey, benchmarkLabel, context, 128)
+	}
+}
+
+// BenchmarkCounterModeKeyWithAssemblerSingleBlock derives a 256-bit key with
+// CounterModeKeyWithAssembler and HMAC-SHA-256, which requires a single PRF
+// invocation.
+func BenchmarkCounterModeKeyWithAssemblerSingleBlock(b *testing.B) {
+	prf := NewHMACPRF(crypto.SHA256)
+	assembler := &LayoutAssembler{
+		FixedData:       FixedBytes(benchmarkLabel, make([]byte, 8), 256),
+		CounterLocation: CounterAfterFixed}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		CounterModeKeyWithAssembler(prf, benchmarkKey, assembler, 256)
+	}
+}
+
+var benchmarkSizes = []struct {
+	name string
+	size int
+}{
+	{name: "16B", size: 16},
+	{name: "64B", size: 64},
+	{name: "4KiB", size: 4 << 10},
+	{name: "1MiB", size: 1 << 20},
+}
+
+func benchmarkPRFs() []PRF {
+	return []PRF{
+		NewHMACPRF(crypto.SHA256),
+		NewHMACPRF(crypto.SHA512),
+		NewCMACAESPRF(16),
+		NewKMACPRF(KMAC128, nil, 0),
+	}
+}
+
+// benchmarkPRFKey returns a key of the natural size for the supplied PRF.
+func benchmarkPRFKey(prf PRF) []byte {
+	return make([]byte, prf.(PRFKeyInfo).KeySize())
+}
+
+func benchmarkModeKey(mode Mode, prf PRF, size int) func() []byte {
+	key := benchmarkPRFKey(prf)
+	context := make([]byte, 8)
+	bitLength := uint32(size) * 8
+	switch mode {
+	case CounterMode:
+		return func() []byte {
+			return CounterModeKey(prf, key, benchmarkLabel, context, bitLength)
+		}
+	case FeedbackMode:
+		iv := make([]byte, prf.Len())
+		return func() []byte {
+			return FeedbackModeKey(prf, key, benchmarkLabel, context, iv, bitLength, true)
+		}
+	case PipelineMode:
+		return func() []byte {
+			return PipelineModeKey(prf, key, benchmarkLabel, context, bitLength, true)
+		}
+	default:
+		panic("invalid mode")
+	}
+}
+
+// BenchmarkModes derives keys of representative sizes with each combination of
+// iteration mode and PRF.
+func BenchmarkModes(b *testing.B) {
+	for _, mode := range []Mode{CounterMode, FeedbackMode, PipelineMode} {
+		for _, prf := range benchmarkPRFs() {
+			for _, size := range benchmarkSizes {
+				b.Run(fmt.Sprintf("%v/%v/%s", mode, prf, size.name), func(b *testing.B) {
+					fn := benchmarkModeKey(mode, prf, size.size)
+
+					b.SetBytes(int64(size.size))
+					b.ReportAllocs()
+					b.ResetTimer()
+
+					for i := 0; i < b.N; i++ {
+						fn()
+					}
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkModesParallel derives keys of representative sizes with each
+// combination of iteration mode and PRF, from one goroutine per CPU.
+func BenchmarkModesParallel(b *testing.B) {
+	for _, mode := range []Mode{CounterMode, FeedbackMode, PipelineMode} {
+		for _, prf := range benchmarkPRFs() {
+			for _, size := range benchmarkSizes {
+				b.Run(fmt.Sprintf("%v/%v/%s", mode, prf, size.name), func(b *testing.B) {
+					b.SetBytes(int64(size.size))
+					b.ReportAllocs()
+					b.ResetTimer()
+
+					b.RunParallel(func(pb *testing.PB) {
+						fn := benchmarkModeKey(mode, prf, size.size)
+						for pb.Next() {
+							fn()
+						}
+					})
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkKDFSizes derives keys of representative sizes from a KDF with each
+// PRF.
+func BenchmarkKDFSizes(b *testing.B) {
+	for _, prf := range benchmarkPRFs() {
+		for _, size := range benchmarkSizes {
+			b.Run(fmt.Sprintf("%v/%s", prf, size.name), func(b *testing.B) {
+				kdf := NewKDF(prf, benchmarkPRFKey(prf))
+				context := make([]byte, 8)
+
+				b.SetBytes(int64(size.size))
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					kdf.Derive(benchmarkLabel, context, uint32(size.size)*8)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkKDFSizesParallel derives keys of representative sizes from a KDF
+// that is shared between one goroutine per CPU.
+func BenchmarkKDFSizesParallel(b *testing.B) {
+	for _, prf := range benchmarkPRFs() {
+		for _, size := range benchmarkSizes {
+			b.Run(fmt.Sprintf("%v/%s", prf, size.name), func(b *testing.B) {
+				kdf := NewKDF(prf, benchmarkPRFKey(prf))
+
+				b.SetBytes(int64(size.size))
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				b.RunParallel(func(pb *testing.PB) {
+					context := make([]byte, 8)
+					for pb.Next() {
+						kdf.Derive(benchmarkLabel, context, uint32(size.size)*8)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkCMACKeyedPRF computes CMAC-AES128 with a KeyedPRF, which only
+// computes the AES key schedule and the CMAC subkeys once.
+func BenchmarkCMACKeyedPRF(b *testing.B) {
+	keyed := NewCMACAESPRF(16).(PRFKeyer).NewKeyedPRF(benchmarkKey[:16])
+	x := FixedBytes(benchmarkLabel, make([]byte, 8), 128)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		keyed.Run(x)
+	}
+}
+
+// BenchmarkCMACPRF computes CMAC-AES128 with the PRF directly, which computes
+// the AES key schedule and the CMAC subkeys on every invocation.
+func BenchmarkCMACPRF(b *testing.B) {
+	prf := NewCMACAESPRF(16)
+	x := FixedBytes(benchmarkLabel, make([]byte, 8), 128)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		prf.Run(benchmarkKey[:16], x)
+	}
+}
+
+// BenchmarkKDFDeriveCMAC derives a single 128-bit key from a KDF that uses
+// CMAC-AES128.
+func BenchmarkKDFDeriveCMAC(b *testing.B) {
+	kdf := NewKDF(NewCMACAESPRF(16), benchmarkKey[:16])
+	context := make([]byte, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		kdf.Derive(benchmarkLabel, context, 128)
+	}
+}
+
+// BenchmarkTemplateDerive derives a single 256-bit key from a Template.
+func BenchmarkTemplateDerive(b *testing.B) {
+	t, err := NewTemplate(&Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         CounterMode,
+		Key:          benchmarkKey,
+		Label:        benchmarkLabel,
+		CounterWidth: 32,
+		BitLength:    256})
+	if err != nil {
+		b.Fatal(err)
+	}
+	context := make([]byte, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		t.Derive(context)
+	}
+}
+
+// BenchmarkDeriveParams derives a single 256-bit key with Derive, using the
+// same parameters as BenchmarkTemplateDerive.
+func BenchmarkDeriveParams(b *testing.B) {
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         CounterMode,
+		Key:          benchmarkKey,
+		Label:        benchmarkLabel,
+		Context:      make([]byte, 8),
+		CounterWidth: 32,
+		BitLength:    256}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Derive(params)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type bitLengthSuite struct{}
+
+var _ = Suite(&bitLengthSuite{})
+
+// The following tests were generated with Python's hmac module, using a label
+// of "label" and a context of "context".
+
+func (s *bitLengthSuite) TestCounterModeSingleBlock(c *C) {
+	c.Check(CounterModeKey(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), 253), DeepEquals,
+		decodeHexString(c, "0560ea4167018b474253c50e546f7168a80a56e0e1ead25242193b45752e8140"))
+}
+
+func (s *bitLengthSuite) TestCounterModeMultipleBlocks(c *C) {
+	c.Check(CounterModeKey(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), 259), DeepEquals,
+		decodeHexString(c, "8c5b4a02095367735b70a73f7009275b209ee1bb86daf87764ee5aaa0db8854340"))
+}
+
+func (s *bitLengthSuite) TestFeedbackMode(c *C) {
+	c.Check(FeedbackModeKey(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), make([]byte, 32), 500, true), DeepEquals,
+		decodeHexString(c, "908fbc287ac411f659d55cdf4bf20a564111a4c121634e59335585b9475bcfb51a05a9c42ab0a34a3a02b78483cab80add4ee5a551baf0392409b9498bb1a0"))
+}
+
+func (s *bitLengthSuite) TestPipelineModeMatchesByteAligned(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("label"), []byte("context"), 300)
+
+	expected := PipelineModeKeyWithFixedData(prf, key, fixed, 304, true)
+	expected[37] &= 0xf0
+	c.Check(PipelineModeKeyWithFixedData(prf, key, fixed, 300, true), DeepEquals, expected)
+}
+
+func (s *bitLengthSuite) TestCounterModeSingleBlockTruncated(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+
+	k := CounterModeKey(prf, key, []byte("label"), []byte("context"), 100)
+	c.Check(k, DeepEquals, decodeHexString(c, "3293f4017a2a47f7b7ccbee840"))
+	c.Check(cap(k), Equals, len(k))
+
+	assembler := NewStandardAssembler(FixedBytes([]byte("label"), []byte("context"), 100), true)
+	c.Check(CounterModeKeyWithAssembler(prf, key, assembler, 100), DeepEquals, k)
+}
+
+// The following KMAC tests were generated with a reference implementation of
+// KMAC from NIST SP-800-185, using a label of "label" and a context of "context".
+
+func (s *bitLengthSuite) TestKMAC128(c *C) {
+	c.Check(KMACKey(KMAC128, decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), 100), DeepEquals,
+		decodeHexString(c, "e770ce7a62ecb194ee2f0b5f40"))
+}
+
+func (s *bitLengthSuite) TestKMAC256(c *C) {
+	c.Check(KMACKey(KMAC256, decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), 252), DeepEquals,
+		decodeHexString(c, "c42e62317d8bec5df6f3d039a2cb8ac5dddfe42176a37e145406448ba50bcfe0"))
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package blake2 provides PRFs based on keyed BLAKE2b and BLAKE2s, for use with the
+key derivation functions in the parent package. These use the native keying mode
+of BLAKE2 rather than HMAC.
+
+These PRFs are not approved by NIST, and should only be used where FIPS
+compliance is not required.
+*/
+package blake2
+
+import (
+	"fmt"
+	"hash"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+)
+
+type blake2PRF struct {
+	name       string
+	size       int
+	blockSize  int
+	maxKeySize int
+	new        func(key []byte) (hash.Hash, error)
+}
+
+func (p *blake2PRF) BlockSize() int {
+	return p.blockSize
+}
+
+func (p *blake2PRF) KeySize() int {
+	return p.size
+}
+
+func (p *blake2PRF) CheckKeyLength(n int) error {
+	if n < 1 || n > p.maxKeySize {
+		return fmt.Errorf("invalid key length %d for %s (expected between 1 and %d bytes)", n, p.name, p.maxKeySize)
+	}
+	return nil
+}
+
+func (p *blake2PRF) Len() uint32 {
+	return uint32(p.size)
+}
+
+func (p *blake2PRF) String() string {
+	return p.name
+}
+
+func (p *blake2PRF) Run(s, x []byte) []byte {
+	h, err := p.new(s)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create %s: %v", p.name, err))
+	}
+	h.Write(x)
+	return h.Sum(nil)
+}
+
+// NewBLAKE2bPRF creates a new PRF based on keyed BLAKE2b with the specified
+// digest size in bytes, which must be between 1 and 64. The PRF will panic if it
+// is supplied with a key that is longer than 64 bytes.
+func NewBLAKE2bPRF(size int) kdf.PRF {
+	if size < 1 || size > blake2b.Size {
+		panic(fmt.Sprintf("invalid BLAKE2b digest size %d", size))
+	}
+	return &blake2PRF{
+		name:       fmt.Sprintf("BLAKE2b-%d", size*8),
+		size:       size,
+		blockSize:  blake2b.BlockSize,
+		maxKeySize: blake2b.Size,
+		new: func(key []byte) (hash.Hash, error) {
+			return blake2b.New(size, key)
+		}}
+}
+
+// NewBLAKE2sPRF creates a new PRF based on keyed BLAKE2s with the specified
+// digest size in bytes, which must be 16 or 32. The PRF will panic if it is
+// supplied with a key that is longer than 32 bytes, or an empty key when the
+// digest size is 16 bytes.
+func NewBLAKE2sPRF(size int) kdf.PRF {
+	var fn func([]byte) (hash.Hash, error)
+	switch size {
+	case blake2s.Size128:
+		fn = blake2s.New128
+	case blake2s.Size:
+		fn = blake2s.New256
+	default:
+		panic(fmt.Sprintf("invalid BLAKE2s digest size %d", size))
+	}
+	return &blake2PRF{
+		name:       fmt.Sprintf("BLAKE2s-%d", size*8),
+		size:       size,
+		blockSize:  blake2s.BlockSize,
+		maxKeySize: blake2s.Size,
+		new:        fn}
+}
+
+func init() {
+	kdf.RegisterPRF("BLAKE2b-256", func() kdf.PRF { return NewBLAKE2bPRF(32) })
+	kdf.RegisterPRF("BLAKE2b-384", func() kdf.PRF { return NewBLAKE2bPRF(48) })
+	kdf.RegisterPRF("BLAKE2b-512", func() kdf.PRF { return NewBLAKE2bPRF(64) })
+	kdf.RegisterPRF("BLAKE2s-128", func() kdf.PRF { return NewBLAKE2sPRF(16) })
+	kdf.RegisterPRF("BLAKE2s-256", func() kdf.PRF { return NewBLAKE2sPRF(32) })
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package blake2_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/blake2"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type blake2Suite struct{}
+
+var _ = Suite(&blake2Suite{})
+
+func (s *blake2Suite) key() []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+// The expected values in the following tests were computed with the reference
+// BLAKE2 implementation in the Python hashlib module, using a label of "label"
+// and a context of "context".
+
+func (s *blake2Suite) testCounterMode(c *C, prf kdf.PRF, expected string) {
+	c.Check(kdf.CounterModeKey(prf, s.key(), []byte("label"), []byte("context"), 640), DeepEquals, decodeHexString(c, expected))
+}
+
+func (s *blake2Suite) TestRunBLAKE2b512(c *C) {
+	prf := NewBLAKE2bPRF(64)
+	c.Check(prf.Len(), Equals, uint32(64))
+	c.Check(prf.Run(s.key(), []byte("abc")), DeepEquals, decodeHexString(c, "9af0244b7da7fe29d90a89727e06a0c93977ce1ad7edcb76ac0b24142194ea00c77be4a1d3fededd31d5a593625a508e742fc90d708f8b48a5c246e4e8e42d94"))
+}
+
+func (s *blake2Suite) TestCounterModeBLAKE2b512(c *C) {
+	s.testCounterMode(c, NewBLAKE2bPRF(64), "10b412f67fba9a41b0fa025c99ddf76d6066e94fe597e223fe7a7eca0ac5458438d3b002c83a4ba2c8edd4cb1daff37a948b197b9abac4aeaa6a7f677eea80a131e1284103e05a1df6c2178cf06b0ac1")
+}
+
+func (s *blake2Suite) TestCounterModeBLAKE2b256(c *C) {
+	s.testCounterMode(c, NewBLAKE2bPRF(32), "54bc4d991d1828c0897a115bcecce423c46e4b31ba226d704905dd14fe41a35c32e25a2f85e3ea43c8865654196b9c74e55ccd639605475a98bb48a3a6e152a6075df00e07deb410286940bbc1f678a8")
+}
+
+func (s *blake2Suite) TestCounterModeBLAKE2s256(c *C) {
+	s.testCounterMode(c, NewBLAKE2sPRF(32), "3d08ba3d66a557af13cfff64c03e7bf27fcff5c4bf3b62a7cbfc7b5549231750137842de6ac4d5782eb0da8f1fa9777cfbca257c34a66e0fc2ea744608d6d5eb8944912ff45c0ee489e90e484b495e50")
+}
+
+func (s *blake2Suite) TestCounterModeBLAKE2s128(c *C) {
+	s.testCounterMode(c, NewBLAKE2sPRF(16), "d2737e06668145cf9dc228df067ac640cf8dfee4e9ede3c130b5eeefdd5f8d75e5bee21bdd36403ea099d01088119bd09d10b6be3d48fcc2b72a5014d3203e31df9dabe13e562327562d89d308de5877")
+}
+
+func (s *blake2Suite) TestFeedbackModeBLAKE2b512(c *C) {
+	prf := NewBLAKE2bPRF(64)
+	k := kdf.FeedbackModeKey(prf, s.key(), []byte("label"), []byte("context"), nil, 1024, true)
+	fixed := append([]byte("label\x00context"), 0, 0, 4, 0)
+	k1 := prf.Run(s.key(), append([]byte{0, 0, 0, 1}, fixed...))
+	k2 := prf.Run(s.key(), append(append(k1, 0, 0, 0, 2), fixed...))
+	c.Check(k, DeepEquals, append(k1[:64:64], k2...))
+}
+
+func (s *blake2Suite) TestInvalidSize(c *C) {
+	c.Check(func() { NewBLAKE2bPRF(65) }, PanicMatches, "invalid BLAKE2b digest size 65")
+	c.Check(func() { NewBLAKE2sPRF(20) }, PanicMatches, "invalid BLAKE2s digest size 20")
+}
+
+func (s *blake2Suite) TestInvalidKey(c *C) {
+	c.Check(func() { NewBLAKE2bPRF(64).Run(make([]byte, 65), nil) }, PanicMatches, "cannot create BLAKE2b-512: .*")
+}
+
+func (s *blake2Suite) TestRegistered(c *C) {
+	for _, name := range []string{"BLAKE2b-256", "BLAKE2b-384", "BLAKE2b-512", "BLAKE2s-128", "BLAKE2s-256"} {
+		prf, err := kdf.NewPRFByName(name)
+		c.Assert(err, IsNil)
+		c.Check(prf.(interface{ String() string }).String(), Equals, name)
+	}
+}
+
+func (s *blake2Suite) TestKeyInfo(c *C) {
+	info := NewBLAKE2bPRF(32).(kdf.PRFKeyInfo)
+	c.Check(info.BlockSize(), Equals, 128)
+	c.Check(info.KeySize(), Equals, 32)
+	c.Check(info.CheckKeyLength(64), IsNil)
+	c.Check(info.CheckKeyLength(65), ErrorMatches, `invalid key length 65 for BLAKE2b-256 \(expected between 1 and 64 bytes\)`)
+
+	info = NewBLAKE2sPRF(16).(kdf.PRFKeyInfo)
+	c.Check(info.BlockSize(), Equals, 64)
+	c.Check(info.CheckKeyLength(0), ErrorMatches, `invalid key length 0 for BLAKE2s-128 \(expected between 1 and 32 bytes\)`)
+}
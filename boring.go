@@ -0,0 +1,37 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// BoringEnabled indicates whether cryptographic operations are being performed
+// by the BoringCrypto module, which is the case when the binary is built with
+// GOEXPERIMENT=boringcrypto.
+func BoringEnabled() bool {
+	return boringEnabled()
+}
+
+// NewBoringHMACPRF creates a new HMAC based PRF using the supplied digest
+// algorithm, which is computed by the BoringCrypto module when it is enabled. In
+// this case, an error is returned if the digest algorithm is not one of SHA-1,
+// SHA-224, SHA-256, SHA-384 or SHA-512, as HMAC would otherwise be computed
+// outside of the module.
+//
+// When BoringCrypto is not enabled, this behaves like NewHMACPRF, so code that
+// uses it still works in builds without BoringCrypto. Use BoringEnabled to
+// determine whether the validated module is in use.
+func NewBoringHMACPRF(h crypto.Hash) (PRF, error) {
+	if boringEnabled() {
+		switch h {
+		case crypto.SHA1, crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512:
+		default:
+			return nil, fmt.Errorf("HMAC-%v is not supported by BoringCrypto", h)
+		}
+	}
+	return NewHMACPRF(h), nil
+}
@@ -0,0 +1,11 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build !boringcrypto
+
+package kdf
+
+func boringEnabled() bool {
+	return false
+}
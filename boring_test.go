@@ -0,0 +1,35 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha3"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type boringSuite struct{}
+
+var _ = Suite(&boringSuite{})
+
+func (s *boringSuite) TestSHA256(c *C) {
+	prf, err := NewBoringHMACPRF(crypto.SHA256)
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewHMACPRF(crypto.SHA256))
+}
+
+func (s *boringSuite) TestSHA3(c *C) {
+	prf, err := NewBoringHMACPRF(crypto.SHA3_256)
+	if BoringEnabled() {
+		c.Check(err, ErrorMatches, "HMAC-SHA3-256 is not supported by BoringCrypto")
+	} else {
+		c.Check(err, IsNil)
+		c.Check(prf, DeepEquals, NewHMACPRF(crypto.SHA3_256))
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import "context"
+
+// ModeKeyWithAssemblerContext derives a key in the same way as
+// ModeKeyWithAssembler, but checks the supplied context before computing each
+// block. If the context is cancelled or its deadline expires, the derivation is
+// abandoned and the context's error is returned.
+func ModeKeyWithAssemblerContext(ctx context.Context, mode IterationMode, prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32) ([]byte, error) {
+	var sink SliceSink
+	if err := modeKeyToSink(mode, prf, key, assembler, iv, bitLength, &sink, ctx.Err); err != nil {
+		return nil, err
+	}
+	return sink.Bytes(), nil
+}
+
+// DeriveContext derives a key in the same way as Derive, but checks the supplied
+// context before computing each block. If the context is cancelled or its
+// deadline expires, the derivation is abandoned and the context's error is
+// returned.
+func DeriveContext(ctx context.Context, params *Params) ([]byte, error) {
+	var sink SliceSink
+	if err := deriveToSinkWithCheck(params, &sink, ctx.Err); err != nil {
+		return nil, err
+	}
+	return sink.Bytes(), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"context"
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type cancelSuite struct{}
+
+var _ = Suite(&cancelSuite{})
+
+// cancellingPRF is a PRF that cancels a context after a number of invocations.
+type cancellingPRF struct {
+	PRF
+	cancel    context.CancelFunc
+	remaining int
+	calls     int
+}
+
+func (p *cancellingPRF) Run(s, x []byte) []byte {
+	p.calls++
+	p.remaining--
+	if p.remaining == 0 {
+		p.cancel()
+	}
+	return p.PRF.Run(s, x)
+}
+
+func (s *cancelSuite) TestModeKeyWithAssemblerContext(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 640)
+
+	k, err := ModeKeyWithAssemblerContext(context.Background(), FeedbackMode, prf, key, NewStandardAssembler(fixed, true), nil, 640)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, FeedbackModeKeyWithFixedData(prf, key, fixed, nil, 640, true))
+}
+
+func (s *cancelSuite) TestModeKeyWithAssemblerContextCancelled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	prf := &cancellingPRF{PRF: NewHMACPRF(crypto.SHA256), cancel: cancel, remaining: 2}
+
+	k, err := ModeKeyWithAssemblerContext(ctx, CounterMode, prf, decodeHexString(c, hmacTestKey), NewStandardAssembler(nil, true), nil, 32*8*10)
+	c.Check(err, Equals, context.Canceled)
+	c.Check(k, IsNil)
+	c.Check(prf.calls, Equals, 2)
+}
+
+func (s *cancelSuite) TestDeriveContext(c *C) {
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Key:          decodeHexString(c, hmacTestKey),
+		Label:        []byte("foo"),
+		Context:      []byte("bar"),
+		CounterWidth: 32,
+		BitLength:    640}
+
+	k, err := DeriveContext(context.Background(), params)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, params.Context, params.BitLength))
+}
+
+func (s *cancelSuite) TestDeriveContextCancelled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Key:          decodeHexString(c, hmacTestKey),
+		CounterWidth: 32,
+		BitLength:    640}
+
+	_, err := DeriveContext(ctx, params)
+	c.Check(err, Equals, context.Canceled)
+}
+
+func (s *cancelSuite) TestDeriveContextInvalid(c *C) {
+	_, err := DeriveContext(context.Background(), &Params{})
+	c.Check(err, ErrorMatches, "no PRF supplied")
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// ErrCounterCorrupted is returned from CounterModeKeyWithCheckedCounter if the
+// iteration counter fails its integrity check.
+var ErrCounterCorrupted = errors.New("iteration counter is corrupted")
+
+// checkedCounter is an iteration counter stored alongside a checksum of its value.
+type checkedCounter struct {
+	value    uint32
+	checksum uint32
+}
+
+func counterChecksum(v uint32) uint32 {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return crc32.ChecksumIEEE(b[:])
+}
+
+func (c *checkedCounter) set(v uint32) {
+	c.value = v
+	c.checksum = counterChecksum(v)
+}
+
+func (c *checkedCounter) get() (uint32, error) {
+	if counterChecksum(c.value) != c.checksum {
+		return 0, ErrCounterCorrupted
+	}
+	return c.value, nil
+}
+
+// checkedCounterHook is called before the counter is read on each iteration.
+var checkedCounterHook = func(*checkedCounter) {}
+
+// CounterModeKeyWithCheckedCounter derives a key in the same way as
+// CounterModeKey, but stores the iteration counter alongside a checksum which is
+// verified on each iteration. This is intended to detect corruption of the
+// counter in environments where memory is unreliable, such as when generating long
+// keystreams on embedded devices exposed to radiation.
+//
+// If the counter fails its integrity check, ErrCounterCorrupted is returned and no
+// key material is returned.
+func CounterModeKeyWithCheckedCounter(prf PRF, key, label, context []byte, bitLength uint32) ([]byte, error) {
+	fixed := fixedBytes(label, context, bitLength)
+
+	var ctr checkedCounter
+	ctr.set(1)
+
+	return commonKDF(prf, bitLength, func(uint32) ([]byte, error) {
+		checkedCounterHook(&ctr)
+		i, err := ctr.get()
+		if err != nil {
+			return nil, err
+		}
+
+		var x bytes.Buffer
+		binary.Write(&x, binary.BigEndian, i)
+		x.Write(fixed)
+		k := prf.Run(key, x.Bytes())
+
+		ctr.set(i + 1)
+		return k, nil
+	})
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type checkedSuite struct{}
+
+var _ = Suite(&checkedSuite{})
+
+func (s *checkedSuite) TestDerive(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+
+	k, err := CounterModeKeyWithCheckedCounter(prf, key, []byte("foo"), []byte("bar"), 1000)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 1000))
+}
+
+func (s *checkedSuite) TestCorruptedCounter(c *C) {
+	var n int
+	restore := MockCheckedCounterHook(func(value *uint32) {
+		n++
+		if n == 3 {
+			*value ^= 0x10
+		}
+	})
+	defer restore()
+
+	k, err := CounterModeKeyWithCheckedCounter(NewHMACPRF(crypto.SHA256), []byte("1234567890123456"), []byte("foo"), []byte("bar"), 1000)
+	c.Check(err, Equals, ErrCounterCorrupted)
+	c.Check(k, IsNil)
+	c.Check(n, Equals, 3)
+}
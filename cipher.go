@@ -0,0 +1,161 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// deriveAndUse derives a key of the specified size in bytes in the same way as
+// Derive, passes it to the supplied function and then wipes it.
+func deriveAndUse[T any](params *Params, size int, fn func(key []byte) (T, error)) (T, error) {
+	var buf [64]byte
+	key := buf[:]
+	if size > len(buf) {
+		key = make([]byte, size)
+	}
+	key = key[:size]
+	defer clear(key)
+
+	p := *params
+	p.BitLength = uint32(size * 8)
+	if _, err := DeriveInto(key, &p); err != nil {
+		var zero T
+		return zero, err
+	}
+	return fn(key)
+}
+
+// DeriveCipher derives an AES key of the specified size in bytes in the same way
+// as Derive, and returns a cipher.Block that is initialized with it. The size
+// must be 16, 24 or 32 for AES-128, AES-192 and AES-256 respectively. The derived
+// key material is wiped before returning, and the BitLength field of the supplied
+// parameters is ignored.
+func DeriveCipher(params *Params, keySize int) (cipher.Block, error) {
+	switch keySize {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("invalid AES key size %d", keySize)
+	}
+	return deriveAndUse(params, keySize, aes.NewCipher)
+}
+
+// AEADAlgorithm selects an AEAD construction for DeriveAEAD.
+type AEADAlgorithm int
+
+const (
+	// AES128GCM selects AES-128 in GCM mode.
+	AES128GCM AEADAlgorithm = iota
+
+	// AES256GCM selects AES-256 in GCM mode.
+	AES256GCM
+
+	// ChaCha20Poly1305 selects ChaCha20-Poly1305 as defined in RFC 8439.
+	ChaCha20Poly1305
+)
+
+// aeadNonceSize is the nonce size of all of the supported AEAD algorithms.
+const aeadNonceSize = 12
+
+func (a AEADAlgorithm) String() string {
+	switch a {
+	case AES128GCM:
+		return "AES-128-GCM"
+	case AES256GCM:
+		return "AES-256-GCM"
+	case ChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	default:
+		return fmt.Sprintf("AEADAlgorithm(%d)", int(a))
+	}
+}
+
+func (a AEADAlgorithm) keySize() int {
+	switch a {
+	case AES128GCM:
+		return 16
+	case AES256GCM:
+		return 32
+	case ChaCha20Poly1305:
+		return chacha20poly1305.KeySize
+	default:
+		return 0
+	}
+}
+
+func (a AEADAlgorithm) new(key []byte) (cipher.AEAD, error) {
+	switch a {
+	case AES128GCM, AES256GCM:
+		c, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(c)
+	default:
+		return chacha20poly1305.New(key)
+	}
+}
+
+// DeriveAEAD derives a key of the size required by the specified algorithm in
+// the same way as Derive, and returns a cipher.AEAD that is initialized with it.
+// The derived key material is wiped before returning, and the BitLength field of
+// the supplied parameters is ignored.
+func DeriveAEAD(params *Params, alg AEADAlgorithm) (cipher.AEAD, error) {
+	aead, _, err := DeriveAEADWithNoncePrefix(params, alg, 0)
+	return aead, err
+}
+
+// DeriveAEADWithNoncePrefix derives a key for the specified algorithm in the
+// same way as DeriveAEAD, along with a nonce prefix of the specified length in
+// bytes from the same derivation. The key is taken from the start of the derived
+// key material and the nonce prefix from the end. This is useful for protocols
+// where each party constructs nonces from a derived prefix and a message
+// counter. The prefix length must not be larger than the nonce size of the
+// algorithm.
+func DeriveAEADWithNoncePrefix(params *Params, alg AEADAlgorithm, prefixLen int) (cipher.AEAD, []byte, error) {
+	keySize := alg.keySize()
+	if keySize == 0 {
+		return nil, nil, fmt.Errorf("invalid AEAD algorithm %d", alg)
+	}
+	if prefixLen < 0 || prefixLen > aeadNonceSize {
+		return nil, nil, fmt.Errorf("invalid nonce prefix length %d", prefixLen)
+	}
+
+	var prefix []byte
+	aead, err := deriveAndUse(params, keySize+prefixLen, func(key []byte) (cipher.AEAD, error) {
+		prefix = append([]byte(nil), key[keySize:]...)
+		return alg.new(key[:keySize])
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, prefix, nil
+}
+
+// DeriveMAC derives a key for the supplied MAC in the same way as Derive, and
+// returns a hash.Hash that computes the MAC with it, so that the caller never
+// handles the MAC key. The MAC must implement PRFHasher, which the HMAC and CMAC
+// based PRFs provided by this package do. The length of the derived key is the
+// natural key size of the MAC if it implements PRFKeyInfo, or its output length
+// otherwise. The derived key material is wiped before returning, and the
+// BitLength field of the supplied parameters is ignored.
+func DeriveMAC(params *Params, mac PRF) (hash.Hash, error) {
+	hasher, ok := mac.(PRFHasher)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot be used as a MAC", prfName(mac))
+	}
+	size := int(mac.Len())
+	if info, ok := mac.(PRFKeyInfo); ok {
+		size = info.KeySize()
+	}
+	return deriveAndUse(params, size, func(key []byte) (hash.Hash, error) {
+		return hasher.NewHash(key), nil
+	})
+}
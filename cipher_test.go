@@ -0,0 +1,162 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha512"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type cipherSuite struct{}
+
+var _ = Suite(&cipherSuite{})
+
+func (s *cipherSuite) testDeriveCipher(c *C, keySize int) {
+	params := testParams()
+	block, err := DeriveCipher(params, keySize)
+	c.Assert(err, IsNil)
+
+	expected, err := aes.NewCipher(CounterModeKey(params.PRF, params.Key, params.Label, params.Context, uint32(keySize*8)))
+	c.Assert(err, IsNil)
+
+	src := []byte("0123456789abcdef")
+	dst1 := make([]byte, 16)
+	dst2 := make([]byte, 16)
+	block.Encrypt(dst1, src)
+	expected.Encrypt(dst2, src)
+	c.Check(dst1, DeepEquals, dst2)
+}
+
+func (s *cipherSuite) TestDeriveCipherAES128(c *C) {
+	s.testDeriveCipher(c, 16)
+}
+
+func (s *cipherSuite) TestDeriveCipherAES192(c *C) {
+	s.testDeriveCipher(c, 24)
+}
+
+func (s *cipherSuite) TestDeriveCipherAES256(c *C) {
+	s.testDeriveCipher(c, 32)
+}
+
+func (s *cipherSuite) TestDeriveCipherInvalidKeySize(c *C) {
+	_, err := DeriveCipher(testParams(), 20)
+	c.Check(err, ErrorMatches, "invalid AES key size 20")
+}
+
+func (s *cipherSuite) TestDeriveCipherInvalidParams(c *C) {
+	params := testParams()
+	params.PRF = nil
+	_, err := DeriveCipher(params, 16)
+	c.Check(err, ErrorMatches, "no PRF supplied")
+}
+
+func (s *cipherSuite) testDeriveAEAD(c *C, alg AEADAlgorithm, keySize int, newAEAD func(key []byte) (cipher.AEAD, error)) {
+	params := testParams()
+	aead, err := DeriveAEAD(params, alg)
+	c.Assert(err, IsNil)
+
+	expected, err := newAEAD(CounterModeKey(params.PRF, params.Key, params.Label, params.Context, uint32(keySize*8)))
+	c.Assert(err, IsNil)
+
+	nonce := make([]byte, aead.NonceSize())
+	c.Check(aead.Seal(nil, nonce, []byte("foo"), []byte("bar")), DeepEquals, expected.Seal(nil, nonce, []byte("foo"), []byte("bar")))
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
+
+func (s *cipherSuite) TestDeriveAEADAES128GCM(c *C) {
+	s.testDeriveAEAD(c, AES128GCM, 16, newAESGCM)
+}
+
+func (s *cipherSuite) TestDeriveAEADAES256GCM(c *C) {
+	s.testDeriveAEAD(c, AES256GCM, 32, newAESGCM)
+}
+
+func (s *cipherSuite) TestDeriveAEADChaCha20Poly1305(c *C) {
+	s.testDeriveAEAD(c, ChaCha20Poly1305, 32, chacha20poly1305.New)
+}
+
+func (s *cipherSuite) TestDeriveAEADWithNoncePrefix(c *C) {
+	params := testParams()
+	aead, prefix, err := DeriveAEADWithNoncePrefix(params, AES128GCM, 4)
+	c.Assert(err, IsNil)
+
+	material := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 160)
+	c.Check(prefix, DeepEquals, material[16:])
+
+	expected, err := newAESGCM(material[:16])
+	c.Assert(err, IsNil)
+	nonce := append(prefix, 0, 0, 0, 0, 0, 0, 0, 1)
+	c.Check(aead.Seal(nil, nonce, []byte("foo"), nil), DeepEquals, expected.Seal(nil, nonce, []byte("foo"), nil))
+}
+
+func (s *cipherSuite) TestDeriveAEADInvalidAlgorithm(c *C) {
+	_, err := DeriveAEAD(testParams(), AEADAlgorithm(5))
+	c.Check(err, ErrorMatches, "invalid AEAD algorithm 5")
+}
+
+func (s *cipherSuite) TestDeriveAEADInvalidNoncePrefixLength(c *C) {
+	_, _, err := DeriveAEADWithNoncePrefix(testParams(), ChaCha20Poly1305, 13)
+	c.Check(err, ErrorMatches, "invalid nonce prefix length 13")
+}
+
+func (s *cipherSuite) TestAEADAlgorithmString(c *C) {
+	c.Check(AES128GCM.String(), Equals, "AES-128-GCM")
+	c.Check(AES256GCM.String(), Equals, "AES-256-GCM")
+	c.Check(ChaCha20Poly1305.String(), Equals, "ChaCha20-Poly1305")
+	c.Check(AEADAlgorithm(5).String(), Equals, "AEADAlgorithm(5)")
+}
+
+func (s *cipherSuite) TestDeriveMACHMAC(c *C) {
+	params := testParams()
+	h, err := DeriveMAC(params, NewHMACPRF(crypto.SHA512))
+	c.Assert(err, IsNil)
+
+	key := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 512)
+	expected := hmac.New(sha512.New, key)
+
+	h.Write([]byte("foo"))
+	expected.Write([]byte("foo"))
+	c.Check(h.Sum(nil), DeepEquals, expected.Sum(nil))
+}
+
+func (s *cipherSuite) TestDeriveMACCMAC(c *C) {
+	params := testParams()
+	h, err := DeriveMAC(params, NewCMACAESPRF(32))
+	c.Assert(err, IsNil)
+
+	key := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 256)
+
+	h.Write([]byte("foo"))
+	c.Check(h.Sum(nil), DeepEquals, NewCMACAESPRF(32).Run(key, []byte("foo")))
+}
+
+func (s *cipherSuite) TestDeriveMACUnsupported(c *C) {
+	_, err := DeriveMAC(testParams(), NewKMACPRF(KMAC128, nil, 0))
+	c.Check(err, ErrorMatches, "KMAC128 cannot be used as a MAC")
+}
+
+func (s *cipherSuite) TestDeriveMACInvalidParams(c *C) {
+	params := testParams()
+	params.PRF = nil
+	_, err := DeriveMAC(params, NewHMACPRF(crypto.SHA256))
+	c.Check(err, ErrorMatches, "no PRF supplied")
+}
@@ -0,0 +1,282 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"fmt"
+	"hash"
+)
+
+// cmac implements the CMAC algorithm defined in NIST SP-800-38B.
+type cmac struct {
+	c      cipher.Block
+	k1, k2 []byte
+	x      []byte // The current chaining value
+	buf    []byte // Unprocessed input, which may be a complete final block
+	last   []byte // Scratch space for the final block in Sum
+}
+
+// shiftLeft sets dst to src shifted left by one bit, with the final byte XORed
+// with rb if the most significant bit of src was set.
+func shiftLeft(dst, src []byte, rb byte) {
+	msb := src[0] >> 7
+	for i := 0; i < len(src)-1; i++ {
+		dst[i] = src[i]<<1 | src[i+1]>>7
+	}
+	dst[len(src)-1] = src[len(src)-1] << 1
+	dst[len(src)-1] ^= rb * msb
+}
+
+func newCMAC(c cipher.Block) *cmac {
+	bs := c.BlockSize()
+
+	var rb byte
+	switch bs {
+	case 8:
+		rb = 0x1b
+	case 16:
+		rb = 0x87
+	default:
+		panic(fmt.Sprintf("unsupported cipher block size %d", bs))
+	}
+
+	m := &cmac{
+		c:   c,
+		k1:  make([]byte, bs),
+		k2:  make([]byte, bs),
+		x:   make([]byte, bs),
+		buf: make([]byte, 0, bs)}
+
+	l := make([]byte, bs)
+	c.Encrypt(l, l)
+	shiftLeft(m.k1, l, rb)
+	shiftLeft(m.k2, m.k1, rb)
+
+	return m
+}
+
+func (m *cmac) Write(p []byte) (int, error) {
+	n := len(p)
+	bs := len(m.x)
+
+	for len(p) > 0 {
+		if len(m.buf) == bs {
+			// There is more input, so the buffered block isn't the
+			// final one.
+			for i := range m.x {
+				m.x[i] ^= m.buf[i]
+			}
+			m.c.Encrypt(m.x, m.x)
+			m.buf = m.buf[:0]
+		}
+
+		c := copy(m.buf[len(m.buf):bs], p)
+		m.buf = m.buf[:len(m.buf)+c]
+		p = p[c:]
+	}
+
+	return n, nil
+}
+
+func (m *cmac) Sum(b []byte) []byte {
+	bs := len(m.x)
+
+	if m.last == nil {
+		m.last = make([]byte, bs)
+	}
+	last := m.last
+	clear(last)
+	copy(last, m.buf)
+	k := m.k1
+	if len(m.buf) < bs {
+		last[len(m.buf)] = 0x80
+		k = m.k2
+	}
+	for i := range last {
+		last[i] ^= m.x[i] ^ k[i]
+	}
+	m.c.Encrypt(last, last)
+
+	return append(b, last...)
+}
+
+func (m *cmac) Reset() {
+	for i := range m.x {
+		m.x[i] = 0
+	}
+	m.buf = m.buf[:0]
+}
+
+// Clone implements hash.Cloner. The cipher and subkeys are shared with the
+// clone, as they are never modified.
+func (m *cmac) Clone() (hash.Cloner, error) {
+	bs := len(m.x)
+	c := &cmac{
+		c:   m.c,
+		k1:  m.k1,
+		k2:  m.k2,
+		x:   append(make([]byte, 0, bs), m.x...),
+		buf: append(make([]byte, 0, bs), m.buf...)}
+	return c, nil
+}
+
+func (m *cmac) Size() int {
+	return len(m.x)
+}
+
+func (m *cmac) BlockSize() int {
+	return len(m.x)
+}
+
+type cmacPRF struct {
+	name      string
+	keySize   int
+	blockSize int
+	newCipher func(key []byte) (cipher.Block, error)
+}
+
+func (p *cmacPRF) Len() uint32 {
+	return uint32(p.blockSize)
+}
+
+func (p *cmacPRF) String() string {
+	return p.name
+}
+
+func (p *cmacPRF) BlockSize() int {
+	return p.blockSize
+}
+
+func (p *cmacPRF) KeySize() int {
+	return p.keySize
+}
+
+func (p *cmacPRF) CheckKeyLength(n int) error {
+	if n != p.keySize {
+		return newError(ErrInvalidKeyLength, "invalid key length %d for %v (expected %d bytes)", n, p, p.keySize)
+	}
+	return nil
+}
+
+func (p *cmacPRF) NewHash(key []byte) hash.Hash {
+	if err := p.CheckKeyLength(len(key)); err != nil {
+		panic(err)
+	}
+	c, err := p.newCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	return newCMAC(c)
+}
+
+func (p *cmacPRF) Run(s, x []byte) []byte {
+	m := p.NewHash(s)
+	m.Write(x)
+	return m.Sum(nil)
+}
+
+func (p *cmacPRF) NewKeyedPRF(key []byte) KeyedPRF {
+	return &keyedCMAC{m: p.NewHash(key).(*cmac)}
+}
+
+// keyedCMAC is a KeyedPRF that reuses a single CMAC instance, so that the key
+// schedule of the cipher and the K1 and K2 subkeys are only computed once.
+type keyedCMAC struct {
+	m *cmac
+}
+
+func (p *keyedCMAC) Len() uint32 {
+	return uint32(p.m.Size())
+}
+
+func (p *keyedCMAC) Run(x []byte) []byte {
+	return p.AppendRun(nil, x)
+}
+
+func (p *keyedCMAC) AppendRun(dst, x []byte) []byte {
+	p.m.Reset()
+	p.m.Write(x)
+	return p.m.Sum(dst)
+}
+
+func (p *keyedCMAC) keyedHash() hash.Hash {
+	return p.m
+}
+
+func (p *keyedCMAC) cloneKeyed() KeyedPRF {
+	m, _ := p.m.Clone()
+	return &keyedCMAC{m: m.(*cmac)}
+}
+
+// NewCMACPRF creates a new CMAC based PRF using the block cipher created by the
+// supplied function, which is called with the key for each invocation of the
+// PRF. This allows CMAC to be used with block ciphers that aren't otherwise
+// supported by this package, such as Camellia or ARIA. The key size is
+// specified in bytes, and the PRF will panic if it is supplied with a key of
+// a different length or if newCipher returns an error.
+//
+// The block size of the cipher must be 8 or 16 bytes, else this function will
+// panic.
+func NewCMACPRF(newCipher func(key []byte) (cipher.Block, error), keySize int) PRF {
+	c, err := newCipher(make([]byte, keySize))
+	if err != nil {
+		panic(err)
+	}
+	switch c.BlockSize() {
+	case 8, 16:
+	default:
+		panic(fmt.Sprintf("unsupported cipher block size %d", c.BlockSize()))
+	}
+	return &cmacPRF{
+		name:      "CMAC",
+		keySize:   keySize,
+		blockSize: c.BlockSize(),
+		newCipher: newCipher}
+}
+
+// NewCMACAESPRF creates a new CMAC based PRF using AES with the specified key
+// size in bytes, which must be 16, 24 or 32 for AES-128, AES-192 and AES-256
+// respectively. The PRF will panic if it is supplied with a key of a different
+// length.
+func NewCMACAESPRF(keySize int) PRF {
+	switch keySize {
+	case 16, 24, 32:
+	default:
+		panic(fmt.Sprintf("invalid AES key size %d", keySize))
+	}
+	return &cmacPRF{
+		name:      fmt.Sprintf("CMAC-AES%d", keySize*8),
+		keySize:   keySize,
+		blockSize: aes.BlockSize,
+		newCipher: aes.NewCipher}
+}
+
+// NewCMACTDEAPRF creates a new CMAC based PRF using TDEA (triple DES) with the
+// specified key size in bytes. This must be 16 for 2-key TDEA, where the key is
+// K1 || K2 and K3 = K1, or 24 for 3-key TDEA. The PRF will panic if it is
+// supplied with a key of a different length.
+//
+// TDEA is only provided for interoperability with legacy systems.
+func NewCMACTDEAPRF(keySize int) PRF {
+	var newCipher func([]byte) (cipher.Block, error)
+	switch keySize {
+	case 16:
+		newCipher = func(key []byte) (cipher.Block, error) {
+			return des.NewTripleDESCipher(append(key[:16:16], key[:8]...))
+		}
+	case 24:
+		newCipher = des.NewTripleDESCipher
+	default:
+		panic(fmt.Sprintf("invalid TDEA key size %d", keySize))
+	}
+	return &cmacPRF{
+		name:      fmt.Sprintf("CMAC-TDEA%d", keySize/8),
+		keySize:   keySize,
+		blockSize: des.BlockSize,
+		newCipher: newCipher}
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type cmacSuite struct{}
+
+var _ = Suite(&cmacSuite{})
+
+const cmacTestMessage = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+// The following tests correspond to the examples in RFC 4493 and NIST SP-800-38B.
+
+func (s *cmacSuite) testCMACAES(c *C, keySize int, key string, msgLen int, expected string) {
+	prf := NewCMACAESPRF(keySize)
+	c.Check(prf.Len(), Equals, uint32(16))
+	c.Check(prf.Run(decodeHexString(c, key), decodeHexString(c, cmacTestMessage)[:msgLen]), DeepEquals, decodeHexString(c, expected))
+}
+
+func (s *cmacSuite) TestAES128Empty(c *C) {
+	s.testCMACAES(c, 16, "2b7e151628aed2a6abf7158809cf4f3c", 0, "bb1d6929e95937287fa37d129b756746")
+}
+
+func (s *cmacSuite) TestAES128OneBlock(c *C) {
+	s.testCMACAES(c, 16, "2b7e151628aed2a6abf7158809cf4f3c", 16, "070a16b46b4d4144f79bdd9dd04a287c")
+}
+
+func (s *cmacSuite) TestAES128PartialBlock(c *C) {
+	s.testCMACAES(c, 16, "2b7e151628aed2a6abf7158809cf4f3c", 40, "dfa66747de9ae63030ca32611497c827")
+}
+
+func (s *cmacSuite) TestAES128FourBlocks(c *C) {
+	s.testCMACAES(c, 16, "2b7e151628aed2a6abf7158809cf4f3c", 64, "51f0bebf7e3b9d92fc49741779363cfe")
+}
+
+func (s *cmacSuite) TestAES192(c *C) {
+	s.testCMACAES(c, 24, "8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", 40, "8a1de5be2eb31aad089a82e6ee908b0e")
+}
+
+func (s *cmacSuite) TestAES256(c *C) {
+	s.testCMACAES(c, 32, "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 64, "e1992190549f6ed5696a2c056c315410")
+}
+
+func (s *cmacSuite) TestInvalidKeySize(c *C) {
+	c.Check(func() { NewCMACAESPRF(20) }, PanicMatches, "invalid AES key size 20")
+}
+
+func (s *cmacSuite) TestInvalidKeyLength(c *C) {
+	prf := NewCMACAESPRF(16)
+	c.Check(func() { prf.Run(make([]byte, 32), nil) }, PanicMatches, `invalid key length 32 for CMAC-AES128 \(expected 16 bytes\)`)
+}
+
+func (s *cmacSuite) testCMACTDEA(c *C, keySize int, key string, msgLen int, expected string) {
+	prf := NewCMACTDEAPRF(keySize)
+	c.Check(prf.Len(), Equals, uint32(8))
+	c.Check(prf.Run(decodeHexString(c, key), decodeHexString(c, cmacTestMessage)[:msgLen]), DeepEquals, decodeHexString(c, expected))
+}
+
+func (s *cmacSuite) TestTDEA3Empty(c *C) {
+	s.testCMACTDEA(c, 24, "8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5", 0, "b7a688e122ffaf95")
+}
+
+func (s *cmacSuite) TestTDEA3PartialBlock(c *C) {
+	s.testCMACTDEA(c, 24, "8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5", 20, "743ddbe0ce2dc2ed")
+}
+
+func (s *cmacSuite) TestTDEA3ThreeBlocks(c *C) {
+	s.testCMACTDEA(c, 24, "8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5", 24, "68d251199804d139")
+}
+
+func (s *cmacSuite) TestTDEA2PartialBlock(c *C) {
+	s.testCMACTDEA(c, 16, "4cf15134a2850dd58a3d10ba80570d38", 20, "62dd1b471902bd4e")
+}
+
+func (s *cmacSuite) TestTDEA2DoesntModifyKey(c *C) {
+	key := make([]byte, 24)
+	copy(key, decodeHexString(c, "4cf15134a2850dd58a3d10ba80570d38"))
+	NewCMACTDEAPRF(16).Run(key[:16], nil)
+	c.Check(key[16:], DeepEquals, make([]byte, 8))
+}
+
+func (s *cmacSuite) TestInvalidTDEAKeySize(c *C) {
+	c.Check(func() { NewCMACTDEAPRF(8) }, PanicMatches, "invalid TDEA key size 8")
+}
+
+func (s *cmacSuite) TestGenericAES128(c *C) {
+	prf := NewCMACPRF(aes.NewCipher, 16)
+	c.Check(prf.Len(), Equals, uint32(16))
+	c.Check(prf.Run(decodeHexString(c, "2b7e151628aed2a6abf7158809cf4f3c"), decodeHexString(c, cmacTestMessage)[:40]), DeepEquals, decodeHexString(c, "dfa66747de9ae63030ca32611497c827"))
+}
+
+func (s *cmacSuite) TestGenericTDEA3(c *C) {
+	prf := NewCMACPRF(des.NewTripleDESCipher, 24)
+	c.Check(prf.Len(), Equals, uint32(8))
+	c.Check(prf.Run(decodeHexString(c, "8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5"), decodeHexString(c, cmacTestMessage)[:20]), DeepEquals, decodeHexString(c, "743ddbe0ce2dc2ed"))
+}
+
+func (s *cmacSuite) TestGenericMatchesAES(c *C) {
+	key := decodeHexString(c, "2b7e151628aed2a6abf7158809cf4f3c")
+	c.Check(CounterModeKey(NewCMACPRF(aes.NewCipher, 16), key, []byte("foo"), []byte("bar"), 256), DeepEquals,
+		CounterModeKey(NewCMACAESPRF(16), key, []byte("foo"), []byte("bar"), 256))
+}
+
+func (s *cmacSuite) TestGenericInvalidKeyLength(c *C) {
+	prf := NewCMACPRF(aes.NewCipher, 16)
+	c.Check(func() { prf.Run(make([]byte, 32), nil) }, PanicMatches, `invalid key length 32 for CMAC \(expected 16 bytes\)`)
+}
+
+func (s *cmacSuite) TestGenericInvalidKeySize(c *C) {
+	c.Check(func() { NewCMACPRF(aes.NewCipher, 8) }, PanicMatches, "crypto/aes: invalid key size 8")
+}
+
+type testBlock32 struct{}
+
+func (testBlock32) BlockSize() int          { return 32 }
+func (testBlock32) Encrypt(dst, src []byte) {}
+func (testBlock32) Decrypt(dst, src []byte) {}
+
+func (s *cmacSuite) TestGenericUnsupportedBlockSize(c *C) {
+	c.Check(func() {
+		NewCMACPRF(func([]byte) (cipher.Block, error) { return testBlock32{}, nil }, 16)
+	}, PanicMatches, "unsupported cipher block size 32")
+}
+
+func (s *cmacSuite) TestKeyedCMAC(c *C) {
+	prf := NewCMACAESPRF(16)
+	key := decodeHexString(c, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := decodeHexString(c, cmacTestMessage)
+
+	k := prf.(PRFKeyer).NewKeyedPRF(key)
+	c.Check(k.Len(), Equals, uint32(16))
+	for _, n := range []int{0, 16, 40, 64, 16} {
+		c.Check(k.Run(msg[:n]), DeepEquals, prf.Run(key, msg[:n]), Commentf("%d", n))
+	}
+}
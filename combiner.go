@@ -0,0 +1,81 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import "fmt"
+
+type xorPRF struct {
+	a, b     PRF
+	keySplit int
+}
+
+func (p *xorPRF) Len() uint32 {
+	if p.b.Len() < p.a.Len() {
+		return p.b.Len()
+	}
+	return p.a.Len()
+}
+
+func (p *xorPRF) String() string {
+	return fmt.Sprintf("XOR(%s,%s)", prfName(p.a), prfName(p.b))
+}
+
+func (p *xorPRF) BlockSize() int {
+	var res int
+	for _, prf := range []PRF{p.a, p.b} {
+		if info, ok := prf.(PRFKeyInfo); ok && info.BlockSize() > res {
+			res = info.BlockSize()
+		}
+	}
+	return res
+}
+
+func (p *xorPRF) KeySize() int {
+	res := p.keySplit
+	if info, ok := p.b.(PRFKeyInfo); ok {
+		res += info.KeySize()
+	}
+	return res
+}
+
+func (p *xorPRF) CheckKeyLength(n int) error {
+	if n < p.keySplit {
+		return newError(ErrInvalidKeyLength, "key length %d is shorter than the minimum of %d bytes", n, p.keySplit)
+	}
+	if err := CheckKeyLength(p.a, p.keySplit); err != nil {
+		return fmt.Errorf("first PRF: %w", err)
+	}
+	if err := CheckKeyLength(p.b, n-p.keySplit); err != nil {
+		return fmt.Errorf("second PRF: %w", err)
+	}
+	return nil
+}
+
+func (p *xorPRF) Run(s, x []byte) []byte {
+	if len(s) < p.keySplit {
+		panic(fmt.Sprintf("invalid key length %d for %v (expected at least %d bytes)", len(s), p, p.keySplit))
+	}
+	n := p.Len()
+	res := p.a.Run(s[:p.keySplit], x)[:n]
+	y := p.b.Run(s[p.keySplit:], x)
+	for i := range res {
+		res[i] ^= y[i]
+	}
+	return res
+}
+
+// NewXORCombinerPRF creates a new PRF that combines two PRFs by XORing their
+// outputs, so that the result remains a PRF as long as either of the underlying
+// PRFs is secure. The key supplied to the combined PRF is split, with the first
+// keySplit bytes used as the key for a and the remaining bytes used as the key
+// for b. The output length is the shorter of the output lengths of a and b.
+//
+// The combined PRF can be used with any of the iteration modes.
+func NewXORCombinerPRF(a, b PRF, keySplit int) PRF {
+	if keySplit < 0 {
+		panic(fmt.Sprintf("invalid key split %d", keySplit))
+	}
+	return &xorPRF{a: a, b: b, keySplit: keySplit}
+}
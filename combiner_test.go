@@ -0,0 +1,97 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type combinerSuite struct{}
+
+var _ = Suite(&combinerSuite{})
+
+func (s *combinerSuite) key(c *C) []byte {
+	return decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f")
+}
+
+func (s *combinerSuite) xor(a, b []byte) []byte {
+	res := make([]byte, len(a))
+	for i := range res {
+		res[i] = a[i] ^ b[i]
+	}
+	return res
+}
+
+func (s *combinerSuite) TestRun(c *C) {
+	a := NewHMACPRF(crypto.SHA256)
+	b := NewHMACPRF(crypto.SHA512)
+	prf := NewXORCombinerPRF(a, b, 32)
+	c.Check(prf.Len(), Equals, uint32(32))
+
+	key := s.key(c)
+	c.Check(prf.Run(key, []byte("foo")), DeepEquals, s.xor(a.Run(key[:32], []byte("foo")), b.Run(key[32:], []byte("foo"))[:32]))
+}
+
+func (s *combinerSuite) TestRunDifferentLengths(c *C) {
+	a := NewHMACPRF(crypto.SHA256)
+	b := NewCMACAESPRF(16)
+	prf := NewXORCombinerPRF(a, b, 32)
+	c.Check(prf.Len(), Equals, uint32(16))
+
+	key := s.key(c)
+	c.Check(prf.Run(key, []byte("foo")), DeepEquals, s.xor(a.Run(key[:32], []byte("foo"))[:16], b.Run(key[32:], []byte("foo"))))
+}
+
+func (s *combinerSuite) TestCounterMode(c *C) {
+	a := NewHMACPRF(crypto.SHA256)
+	b := NewCMACAESPRF(16)
+	prf := NewXORCombinerPRF(a, b, 32)
+	key := s.key(c)
+	fixed := FixedBytes([]byte("label"), []byte("context"), 256)
+
+	var expected []byte
+	for _, i := range []byte{1, 2} {
+		x := append([]byte{0, 0, 0, i}, fixed...)
+		expected = append(expected, s.xor(a.Run(key[:32], x)[:16], b.Run(key[32:], x))...)
+	}
+	c.Check(CounterModeKey(prf, key, []byte("label"), []byte("context"), 256), DeepEquals, expected)
+}
+
+func (s *combinerSuite) TestFeedbackAndPipelineModes(c *C) {
+	prf := NewXORCombinerPRF(NewHMACPRF(crypto.SHA256), NewCMACAESPRF(16), 32)
+	c.Check(FeedbackModeKey(prf, s.key(c), []byte("label"), []byte("context"), nil, 384, true), HasLen, 48)
+	c.Check(PipelineModeKey(prf, s.key(c), []byte("label"), []byte("context"), 384, true), HasLen, 48)
+}
+
+func (s *combinerSuite) TestString(c *C) {
+	prf := NewXORCombinerPRF(NewHMACPRF(crypto.SHA256), NewCMACAESPRF(16), 32)
+	c.Check(prf.(interface{ String() string }).String(), Equals, "XOR(HMAC-SHA-256,CMAC-AES128)")
+}
+
+func (s *combinerSuite) TestCheckKeyLength(c *C) {
+	prf := NewXORCombinerPRF(NewHMACPRF(crypto.SHA256), NewCMACAESPRF(16), 32)
+	c.Check(prf.(PRFKeyInfo).KeySize(), Equals, 48)
+	c.Check(prf.(PRFKeyInfo).BlockSize(), Equals, 64)
+	c.Check(CheckKeyLength(prf, 48), IsNil)
+	c.Check(CheckKeyLength(prf, 16), ErrorMatches, "key length 16 is shorter than the minimum of 32 bytes")
+	c.Check(CheckKeyLength(prf, 64), ErrorMatches, `second PRF: invalid key length 32 for CMAC-AES128 \(expected 16 bytes\)`)
+
+	prf = NewXORCombinerPRF(NewHMACPRF(crypto.SHA256), NewCMACAESPRF(16), 8)
+	c.Check(CheckKeyLength(prf, 24), ErrorMatches, "first PRF: key length 8 is shorter than the minimum of 14 bytes")
+}
+
+func (s *combinerSuite) TestShortKey(c *C) {
+	prf := NewXORCombinerPRF(NewHMACPRF(crypto.SHA256), NewCMACAESPRF(16), 32)
+	c.Check(func() { prf.Run(make([]byte, 16), nil) }, PanicMatches, `invalid key length 16 for XOR\(HMAC-SHA-256,CMAC-AES128\) \(expected at least 32 bytes\)`)
+}
+
+func (s *combinerSuite) TestInvalidKeySplit(c *C) {
+	c.Check(func() { NewXORCombinerPRF(NewCMACAESPRF(16), NewCMACAESPRF(16), -1) }, PanicMatches, "invalid key split -1")
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"math"
+)
+
+// commonCounterModeKey derives a key of the specified length in bytes in
+// counter mode with the standard layout.
+func commonCounterModeKey(prf PRF, key, label, context []byte, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid length %d", length)
+	}
+	if uint64(length)*8 > math.MaxUint32 {
+		return nil, newError(ErrOutputTooLong, "length %d is too large", length)
+	}
+	return CounterModeKeyE(prf, key, label, context, uint32(length)*8)
+}
+
+// CounterHMACSHA256 derives a key of the specified length in bytes using the
+// counter mode function with HMAC-SHA-256, a 32-bit counter and the fixed input
+// data encoded as Label || 0x00 || Context || [L]2. This is equivalent to
+// CounterModeKey with NewHMACPRF(crypto.SHA256), and to the "cng-default" suite.
+// An error is returned if the key is too short or the length is invalid.
+func CounterHMACSHA256(key, label, context []byte, length int) ([]byte, error) {
+	return commonCounterModeKey(NewHMACPRF(crypto.SHA256), key, label, context, length)
+}
+
+// CounterHMACSHA384 derives a key in the same way as CounterHMACSHA256, but
+// using HMAC-SHA-384.
+func CounterHMACSHA384(key, label, context []byte, length int) ([]byte, error) {
+	return commonCounterModeKey(NewHMACPRF(crypto.SHA384), key, label, context, length)
+}
+
+// CounterHMACSHA512 derives a key in the same way as CounterHMACSHA256, but
+// using HMAC-SHA-512.
+func CounterHMACSHA512(key, label, context []byte, length int) ([]byte, error) {
+	return commonCounterModeKey(NewHMACPRF(crypto.SHA512), key, label, context, length)
+}
+
+// CounterCMACAES derives a key in the same way as CounterHMACSHA256, but using
+// CMAC-AES. The AES key size is selected from the length of the supplied key,
+// which must be 16, 24 or 32 bytes.
+func CounterCMACAES(key, label, context []byte, length int) ([]byte, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, newError(ErrInvalidKeyLength, "invalid AES key length %d", len(key))
+	}
+	return commonCounterModeKey(NewCMACAESPRF(len(key)), key, label, context, length)
+}
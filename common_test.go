@@ -0,0 +1,68 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"errors"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type commonSuite struct{}
+
+var _ = Suite(&commonSuite{})
+
+func (s *commonSuite) TestCounterHMACSHA256(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+	k, err := CounterHMACSHA256(key, []byte("foo"), []byte("bar"), 42)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), 336))
+}
+
+func (s *commonSuite) TestCounterHMACSHA384(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+	k, err := CounterHMACSHA384(key, []byte("foo"), []byte("bar"), 32)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(NewHMACPRF(crypto.SHA384), key, []byte("foo"), []byte("bar"), 256))
+}
+
+func (s *commonSuite) TestCounterHMACSHA512(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+	k, err := CounterHMACSHA512(key, []byte("foo"), []byte("bar"), 100)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(NewHMACPRF(crypto.SHA512), key, []byte("foo"), []byte("bar"), 800))
+}
+
+func (s *commonSuite) TestCounterCMACAES(c *C) {
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f1011121314151617")
+	k, err := CounterCMACAES(key, []byte("foo"), []byte("bar"), 24)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(NewCMACAESPRF(24), key, []byte("foo"), []byte("bar"), 192))
+}
+
+func (s *commonSuite) TestCounterCMACAESInvalidKeyLength(c *C) {
+	_, err := CounterCMACAES(make([]byte, 20), nil, nil, 16)
+	c.Check(err, ErrorMatches, "invalid AES key length 20")
+	c.Check(errors.Is(err, ErrInvalidKeyLength), Equals, true)
+}
+
+func (s *commonSuite) TestShortKey(c *C) {
+	_, err := CounterHMACSHA256([]byte("foo"), nil, nil, 16)
+	c.Check(err, ErrorMatches, "key length 3 is shorter than the minimum of 14 bytes")
+}
+
+func (s *commonSuite) TestNegativeLength(c *C) {
+	_, err := CounterHMACSHA256(decodeHexString(c, hmacTestKey), nil, nil, -1)
+	c.Check(err, ErrorMatches, "invalid length -1")
+}
+
+func (s *commonSuite) TestLengthTooLarge(c *C) {
+	_, err := CounterHMACSHA256(decodeHexString(c, hmacTestKey), nil, nil, 1<<29)
+	c.Check(err, ErrorMatches, "length 536870912 is too large")
+	c.Check(errors.Is(err, ErrOutputTooLong), Equals, true)
+}
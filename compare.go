@@ -0,0 +1,31 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import "crypto/subtle"
+
+// KeysEqual compares the supplied derived key against an expected value in
+// constant time, for use in known-answer checks and key confirmation. The time
+// taken depends only on the length of the derived key, so a length mismatch does
+// not return early and doesn't reveal how much of the keys match.
+func KeysEqual(key, expected []byte) bool {
+	if len(key) != len(expected) {
+		// Perform a comparison of the same length so that the time taken
+		// is independent of the expected value.
+		subtle.ConstantTimeCompare(key, key)
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// Equal compares this key against an expected value in constant time, in the
+// same way as KeysEqual. A key that has been zeroized is never equal to any
+// value.
+func (k *Key) Equal(expected []byte) bool {
+	if k.b == nil {
+		return false
+	}
+	return KeysEqual(k.b, expected)
+}
@@ -0,0 +1,38 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type compareSuite struct{}
+
+var _ = Suite(&compareSuite{})
+
+func (s *compareSuite) TestKeysEqual(c *C) {
+	c.Check(KeysEqual([]byte{1, 2, 3}, []byte{1, 2, 3}), Equals, true)
+	c.Check(KeysEqual(nil, []byte{}), Equals, true)
+}
+
+func (s *compareSuite) TestKeysNotEqual(c *C) {
+	c.Check(KeysEqual([]byte{1, 2, 3}, []byte{1, 2, 4}), Equals, false)
+}
+
+func (s *compareSuite) TestKeysEqualLengthMismatch(c *C) {
+	c.Check(KeysEqual([]byte{1, 2, 3}, []byte{1, 2}), Equals, false)
+	c.Check(KeysEqual([]byte{1, 2}, []byte{1, 2, 3}), Equals, false)
+}
+
+func (s *compareSuite) TestKeyEqual(c *C) {
+	key := NewKey([]byte{1, 2, 3})
+	c.Check(key.Equal([]byte{1, 2, 3}), Equals, true)
+	c.Check(key.Equal([]byte{1, 2, 0}), Equals, false)
+
+	key.Zeroize()
+	c.Check(key.Equal(nil), Equals, false)
+}
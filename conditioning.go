@@ -0,0 +1,101 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+)
+
+// KeyConditioning selects how ConditionKey handles a key derivation key that
+// doesn't match the key size of a PRF with a fixed key size, such as a block
+// cipher based PRF.
+type KeyConditioning int
+
+const (
+	// RejectKey rejects an unacceptable key with an error.
+	RejectKey KeyConditioning = iota
+
+	// TruncateKey uses the leading bytes of a key that is longer than the
+	// natural key size of the PRF. Keys that are shorter are rejected.
+	TruncateKey
+
+	// HashKey replaces the key with its SHA-256 digest, or its SHA-512
+	// digest if the natural key size of the PRF is larger than 32 bytes,
+	// truncated to the natural key size of the PRF.
+	HashKey
+)
+
+func (c KeyConditioning) String() string {
+	switch c {
+	case RejectKey:
+		return "reject"
+	case TruncateKey:
+		return "truncate"
+	case HashKey:
+		return "hash"
+	default:
+		return fmt.Sprintf("KeyConditioning(%d)", int(c))
+	}
+}
+
+// ConditionKey returns a key derivation key that is acceptable for the supplied
+// PRF. If the supplied key is already acceptable, or the PRF doesn't implement
+// PRFKeyInfo, it is returned unmodified. Otherwise, if the PRF has a fixed key
+// size, it is conditioned to that size using the specified method, and an error
+// is returned if this isn't possible. Keys that are rejected by PRFs that accept
+// a range of key lengths, such as a key that is shorter than the minimum length
+// for HMAC, are always rejected, as conditioning can't make them any stronger.
+func ConditionKey(prf PRF, key []byte, conditioning KeyConditioning) ([]byte, error) {
+	info, ok := prf.(PRFKeyInfo)
+	if !ok {
+		return key, nil
+	}
+	err := info.CheckKeyLength(len(key))
+	if err == nil {
+		return key, nil
+	}
+
+	size := info.KeySize()
+	if !hasFixedKeySize(info) {
+		return nil, err
+	}
+
+	switch conditioning {
+	case RejectKey:
+		return nil, err
+	case TruncateKey:
+		if len(key) < size {
+			return nil, newError(ErrInvalidKeyLength, "cannot truncate key of length %d for %s (expected at least %d bytes)", len(key), prfName(prf), size)
+		}
+		key = key[:size]
+	case HashKey:
+		switch {
+		case size <= sha256.Size:
+			h := sha256.Sum256(key)
+			key = h[:size]
+		case size <= sha512.Size:
+			h := sha512.Sum512(key)
+			key = h[:size]
+		default:
+			return nil, newError(ErrInvalidKeyLength, "cannot hash key for %s (key size of %d bytes is larger than %d bytes)", prfName(prf), size, sha512.Size)
+		}
+	default:
+		return nil, fmt.Errorf("invalid key conditioning method %d", conditioning)
+	}
+
+	if err := info.CheckKeyLength(len(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// hasFixedKeySize indicates whether the supplied PRF only accepts keys of its
+// natural key size.
+func hasFixedKeySize(info PRFKeyInfo) bool {
+	size := info.KeySize()
+	return info.CheckKeyLength(size) == nil && info.CheckKeyLength(size+1) != nil
+}
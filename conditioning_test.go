@@ -0,0 +1,89 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type conditioningSuite struct{}
+
+var _ = Suite(&conditioningSuite{})
+
+func (s *conditioningSuite) TestString(c *C) {
+	c.Check(RejectKey.String(), Equals, "reject")
+	c.Check(TruncateKey.String(), Equals, "truncate")
+	c.Check(HashKey.String(), Equals, "hash")
+	c.Check(KeyConditioning(5).String(), Equals, "KeyConditioning(5)")
+}
+
+func (s *conditioningSuite) TestAcceptableKey(c *C) {
+	key := make([]byte, 16)
+	for _, conditioning := range []KeyConditioning{RejectKey, TruncateKey, HashKey} {
+		k, err := ConditionKey(NewCMACAESPRF(16), key, conditioning)
+		c.Check(err, IsNil)
+		c.Check(k, DeepEquals, key)
+	}
+}
+
+func (s *conditioningSuite) TestReject(c *C) {
+	_, err := ConditionKey(NewCMACAESPRF(16), make([]byte, 32), RejectKey)
+	c.Check(err, ErrorMatches, `invalid key length 32 for CMAC-AES128 \(expected 16 bytes\)`)
+}
+
+func (s *conditioningSuite) TestTruncate(c *C) {
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	k, err := ConditionKey(NewCMACAESPRF(24), key, TruncateKey)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, key[:24])
+}
+
+func (s *conditioningSuite) TestTruncateTooShort(c *C) {
+	_, err := ConditionKey(NewCMACAESPRF(32), make([]byte, 16), TruncateKey)
+	c.Check(err, ErrorMatches, `cannot truncate key of length 16 for CMAC-AES256 \(expected at least 32 bytes\)`)
+}
+
+func (s *conditioningSuite) TestHash(c *C) {
+	key := []byte("a key derivation key of arbitrary length")
+	k, err := ConditionKey(NewCMACAESPRF(16), key, HashKey)
+	c.Check(err, IsNil)
+	h := sha256.Sum256(key)
+	c.Check(k, DeepEquals, h[:16])
+}
+
+func (s *conditioningSuite) TestHashShortHMACKey(c *C) {
+	_, err := ConditionKey(NewHMACPRF(crypto.SHA512), []byte("short"), HashKey)
+	c.Check(err, ErrorMatches, "key length 5 is shorter than the minimum of 14 bytes")
+	c.Check(errors.Is(err, ErrInvalidKeyLength), Equals, true)
+}
+
+func (s *conditioningSuite) TestTruncateShortHMACKey(c *C) {
+	_, err := ConditionKey(NewHMACPRF(crypto.SHA256), []byte("short"), TruncateKey)
+	c.Check(err, ErrorMatches, "key length 5 is shorter than the minimum of 14 bytes")
+	c.Check(errors.Is(err, ErrInvalidKeyLength), Equals, true)
+}
+
+func (s *conditioningSuite) TestHashShortKMACKey(c *C) {
+	_, err := ConditionKey(NewKMACPRF(KMAC256, nil, 0), []byte("short"), HashKey)
+	c.Check(err, ErrorMatches, "key length 5 is shorter than the minimum of 32 bytes")
+}
+
+func (s *conditioningSuite) TestNoKeyInfo(c *C) {
+	key := []byte("foo")
+	k, err := ConditionKey(testPRF{NewHMACPRF(crypto.SHA256)}, key, RejectKey)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, key)
+}
+
+func (s *conditioningSuite) TestInvalidMethod(c *C) {
+	_, err := ConditionKey(NewCMACAESPRF(16), nil, KeyConditioning(5))
+	c.Check(err, ErrorMatches, "invalid key conditioning method 5")
+}
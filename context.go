@@ -0,0 +1,58 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// ContextBuilder builds a label or context from a sequence of typed fields,
+// using a canonical encoding. Integers are encoded as big-endian values of their
+// natural width and variable length fields are prefixed with their length as a
+// big-endian 32-bit integer, so that distinct sequences of fields of the same
+// types always produce distinct encodings. The zero value is ready to use.
+type ContextBuilder struct {
+	buf bytes.Buffer
+}
+
+// AddUint32 appends a 32-bit integer, such as an identifier.
+func (b *ContextBuilder) AddUint32(v uint32) *ContextBuilder {
+	binary.Write(&b.buf, binary.BigEndian, v)
+	return b
+}
+
+// AddUint64 appends a 64-bit integer, such as an identifier.
+func (b *ContextBuilder) AddUint64(v uint64) *ContextBuilder {
+	binary.Write(&b.buf, binary.BigEndian, v)
+	return b
+}
+
+// AddTime appends a timestamp, encoded as the number of seconds since the Unix
+// epoch in a signed 64-bit integer. Any fractional part is discarded.
+func (b *ContextBuilder) AddTime(t time.Time) *ContextBuilder {
+	binary.Write(&b.buf, binary.BigEndian, t.Unix())
+	return b
+}
+
+// AddBytes appends a variable length byte string, prefixed with its length.
+func (b *ContextBuilder) AddBytes(v []byte) *ContextBuilder {
+	binary.Write(&b.buf, binary.BigEndian, uint32(len(v)))
+	b.buf.Write(v)
+	return b
+}
+
+// AddString appends a variable length string, prefixed with its length.
+func (b *ContextBuilder) AddString(v string) *ContextBuilder {
+	binary.Write(&b.buf, binary.BigEndian, uint32(len(v)))
+	b.buf.WriteString(v)
+	return b
+}
+
+// Bytes returns the encoded fields.
+func (b *ContextBuilder) Bytes() []byte {
+	return b.buf.Bytes()
+}
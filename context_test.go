@@ -0,0 +1,47 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"time"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type contextSuite struct{}
+
+var _ = Suite(&contextSuite{})
+
+func (s *contextSuite) TestEmpty(c *C) {
+	var b ContextBuilder
+	c.Check(b.Bytes(), HasLen, 0)
+}
+
+func (s *contextSuite) TestIntegers(c *C) {
+	var b ContextBuilder
+	b.AddUint32(0x01020304).AddUint64(0x05060708090a0b0c)
+	c.Check(b.Bytes(), DeepEquals, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+}
+
+func (s *contextSuite) TestTime(c *C) {
+	var b ContextBuilder
+	b.AddTime(time.Date(2021, time.March, 1, 12, 0, 0, 500, time.FixedZone("", 3600)))
+	c.Check(b.Bytes(), DeepEquals, decodeHexString(c, "00000000603cc930"))
+}
+
+func (s *contextSuite) TestStrings(c *C) {
+	var b ContextBuilder
+	b.AddString("foo").AddBytes([]byte{0xaa, 0xbb}).AddString("")
+	c.Check(b.Bytes(), DeepEquals, []byte{0, 0, 0, 3, 'f', 'o', 'o', 0, 0, 0, 2, 0xaa, 0xbb, 0, 0, 0, 0})
+}
+
+func (s *contextSuite) TestUnambiguous(c *C) {
+	var b1, b2 ContextBuilder
+	b1.AddString("ab").AddString("c")
+	b2.AddString("a").AddString("bc")
+	c.Check(b1.Bytes(), Not(DeepEquals), b2.Bytes())
+}
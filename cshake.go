@@ -0,0 +1,103 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/sha3"
+	"fmt"
+)
+
+// CSHAKEVariant selects the cSHAKE function defined in NIST SP-800-185.
+type CSHAKEVariant int
+
+const (
+	// CSHAKE128 selects the cSHAKE128 function.
+	CSHAKE128 CSHAKEVariant = iota
+
+	// CSHAKE256 selects the cSHAKE256 function.
+	CSHAKE256
+)
+
+func (v CSHAKEVariant) String() string {
+	switch v {
+	case CSHAKE128:
+		return "cSHAKE128"
+	case CSHAKE256:
+		return "cSHAKE256"
+	default:
+		return fmt.Sprintf("CSHAKEVariant(%d)", int(v))
+	}
+}
+
+type cshakePRF struct {
+	variant       CSHAKEVariant
+	customization []byte
+	size          uint32
+}
+
+func (p *cshakePRF) Len() uint32 {
+	return p.size
+}
+
+func (p *cshakePRF) String() string {
+	return p.variant.String()
+}
+
+func (p *cshakePRF) newCSHAKE() *sha3.SHAKE {
+	switch p.variant {
+	case CSHAKE128:
+		return sha3.NewCSHAKE128(nil, p.customization)
+	default:
+		return sha3.NewCSHAKE256(nil, p.customization)
+	}
+}
+
+func (p *cshakePRF) BlockSize() int {
+	return p.newCSHAKE().BlockSize()
+}
+
+// KeySize returns the security strength of the cSHAKE variant in bytes.
+func (p *cshakePRF) KeySize() int {
+	if p.variant == CSHAKE256 {
+		return 32
+	}
+	return 16
+}
+
+func (p *cshakePRF) CheckKeyLength(n int) error {
+	return checkMinKeyLength(n, p.KeySize())
+}
+
+func (p *cshakePRF) Run(s, x []byte) []byte {
+	h := p.newCSHAKE()
+	h.Write(bytepadKey(s, h.BlockSize()))
+	h.Write(x)
+	res := make([]byte, p.size)
+	h.Read(res)
+	return res
+}
+
+// NewCSHAKEPRF creates a new cSHAKE based PRF for use with the iteration modes,
+// using the specified cSHAKE variant and customization string. Each invocation
+// computes:
+//
+//	cSHAKE#(bytepad(encode_string(s), rate) || x, size*8, "", customization)
+//
+// The size argument is the length in bytes of the output of each PRF invocation.
+// As cSHAKE is an extendable output function, a size that is larger than the
+// requested key length allows a key to be derived with a single iteration.
+// Unlike KMAC, the output length is not an input to the computation, so the
+// output for one size is a prefix of the output for a larger size.
+func NewCSHAKEPRF(variant CSHAKEVariant, customization []byte, size uint32) PRF {
+	switch variant {
+	case CSHAKE128, CSHAKE256:
+	default:
+		panic("invalid cSHAKE variant")
+	}
+	if size == 0 {
+		panic("invalid cSHAKE output size 0")
+	}
+	return &cshakePRF{variant: variant, customization: customization, size: size}
+}
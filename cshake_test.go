@@ -0,0 +1,60 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type cshakeSuite struct{}
+
+var _ = Suite(&cshakeSuite{})
+
+var cshakeTestKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+// The following tests were generated with the SHAKE implementation in Python's
+// hashlib, which is equivalent to cSHAKE with an empty customization string.
+
+func (s *cshakeSuite) TestCounterModeCSHAKE128SingleIteration(c *C) {
+	prf := NewCSHAKEPRF(CSHAKE128, nil, 80)
+	c.Check(prf.Len(), Equals, uint32(80))
+	c.Check(CounterModeKey(prf, decodeHexString(c, cshakeTestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "774a58fa5a78805e711625074ca3b26e44607231e451c45b1a2e546717793a40982e7caf38bc32878ed1bb7b1f9b9c21e51ccc8670904052a4969619fdd1f0d4fd98efa12e1f0dd8150080dcc6d937ec"))
+}
+
+func (s *cshakeSuite) TestCounterModeCSHAKE256SingleIteration(c *C) {
+	prf := NewCSHAKEPRF(CSHAKE256, nil, 80)
+	c.Check(CounterModeKey(prf, decodeHexString(c, cshakeTestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "ffc8d5c34ba5115174467838e5119740e98a383a4e101386157755d8c7606607223288363d4cd57ad33c7d0ee6964b94451eacef5540530b960aaa237540f0578ceacff2ce08a0fe7095e831307dc210"))
+}
+
+func (s *cshakeSuite) TestCounterModeCSHAKE256MultipleIterations(c *C) {
+	prf := NewCSHAKEPRF(CSHAKE256, nil, 32)
+	c.Check(CounterModeKey(prf, decodeHexString(c, cshakeTestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "ffc8d5c34ba5115174467838e5119740e98a383a4e101386157755d8c76066074e9cef11b63e2c1f97b7d32f46cee0075f7ba4a108f376d49eb988adcdb18af485fbca04657cf560ff2239388051ecbf"))
+}
+
+func (s *cshakeSuite) TestCustomization(c *C) {
+	key := decodeHexString(c, cshakeTestKey)
+	a := NewCSHAKEPRF(CSHAKE128, nil, 32).Run(key, []byte("foo"))
+	b := NewCSHAKEPRF(CSHAKE128, []byte("cust"), 32).Run(key, []byte("foo"))
+	c.Check(b, Not(DeepEquals), a)
+	c.Check(NewCSHAKEPRF(CSHAKE128, []byte("cust"), 32).Run(key, []byte("foo")), DeepEquals, b)
+}
+
+func (s *cshakeSuite) TestString(c *C) {
+	c.Check(NewCSHAKEPRF(CSHAKE128, nil, 16).(interface{ String() string }).String(), Equals, "cSHAKE128")
+	c.Check(CSHAKEVariant(5).String(), Equals, "CSHAKEVariant(5)")
+}
+
+func (s *cshakeSuite) TestInvalidVariant(c *C) {
+	c.Check(func() { NewCSHAKEPRF(CSHAKEVariant(5), nil, 32) }, PanicMatches, "invalid cSHAKE variant")
+}
+
+func (s *cshakeSuite) TestInvalidSize(c *C) {
+	c.Check(func() { NewCSHAKEPRF(CSHAKE128, nil, 0) }, PanicMatches, "invalid cSHAKE output size 0")
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IntegerEncoder encodes integer fields in the input to the PRF, such as the
+// iteration counter. It can be implemented to match layouts that aren't
+// supported by the built-in encodings.
+type IntegerEncoder interface {
+	// MaxValue returns the largest value that can be encoded.
+	MaxValue() uint64
+
+	// AppendInteger appends the encoding of the supplied value to dst and
+	// returns the extended slice. The value will never be larger than the
+	// value returned from MaxValue.
+	AppendInteger(dst []byte, v uint64) []byte
+}
+
+// FixedWidthEncoder is an IntegerEncoder that encodes values as fixed width
+// binary integers. This is the encoding used by NIST SP-800-108.
+type FixedWidthEncoder struct {
+	// Width is the length of the encoded integer in bits, which must be 8,
+	// 16, 24, 32 or 64.
+	Width int
+
+	// LittleEndian indicates that the integer should be encoded in
+	// little-endian byte order. The default is big-endian.
+	LittleEndian bool
+}
+
+func (e FixedWidthEncoder) checkWidth() {
+	if !validCounterWidth(e.Width) {
+		panic(fmt.Sprintf("invalid integer width %d", e.Width))
+	}
+}
+
+func (e FixedWidthEncoder) MaxValue() uint64 {
+	e.checkWidth()
+	if e.Width == 64 {
+		return 1<<64 - 1
+	}
+	return 1<<e.Width - 1
+}
+
+func (e FixedWidthEncoder) AppendInteger(dst []byte, v uint64) []byte {
+	e.checkWidth()
+
+	var b [8]byte
+	if e.LittleEndian {
+		binary.LittleEndian.PutUint64(b[:], v)
+		return append(dst, b[:e.Width/8]...)
+	}
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(dst, b[8-e.Width/8:]...)
+}
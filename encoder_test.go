@@ -0,0 +1,40 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type encoderSuite struct{}
+
+var _ = Suite(&encoderSuite{})
+
+func (s *encoderSuite) TestFixedWidthEncoder(c *C) {
+	for _, t := range []struct {
+		enc      FixedWidthEncoder
+		max      uint64
+		expected []byte
+	}{
+		{enc: FixedWidthEncoder{Width: 8}, max: 0xff, expected: []byte{0x08}},
+		{enc: FixedWidthEncoder{Width: 16}, max: 0xffff, expected: []byte{0x07, 0x08}},
+		{enc: FixedWidthEncoder{Width: 24}, max: 0xffffff, expected: []byte{0x06, 0x07, 0x08}},
+		{enc: FixedWidthEncoder{Width: 32}, max: 0xffffffff, expected: []byte{0x05, 0x06, 0x07, 0x08}},
+		{enc: FixedWidthEncoder{Width: 64}, max: 0xffffffffffffffff, expected: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+		{enc: FixedWidthEncoder{Width: 16, LittleEndian: true}, max: 0xffff, expected: []byte{0x08, 0x07}},
+		{enc: FixedWidthEncoder{Width: 24, LittleEndian: true}, max: 0xffffff, expected: []byte{0x08, 0x07, 0x06}},
+		{enc: FixedWidthEncoder{Width: 64, LittleEndian: true}, max: 0xffffffffffffffff, expected: []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}},
+	} {
+		c.Check(t.enc.MaxValue(), Equals, t.max)
+		c.Check(t.enc.AppendInteger([]byte("foo"), 0x0102030405060708), DeepEquals, append([]byte("foo"), t.expected...))
+	}
+}
+
+func (s *encoderSuite) TestFixedWidthEncoderInvalidWidth(c *C) {
+	c.Check(func() { FixedWidthEncoder{Width: 12}.MaxValue() }, PanicMatches, "invalid integer width 12")
+	c.Check(func() { FixedWidthEncoder{}.AppendInteger(nil, 1) }, PanicMatches, "invalid integer width 0")
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// CounterModeKeyWithEntropy derives a keystream of the specified length using a
+// variant of the counter mode function defined in NIST SP-800-108, where
+// additional entropy is read from the supplied reader and mixed in to the input of
+// each PRF invocation. Each block is computed as:
+//
+//	K(i) = PRF(key, [i]2 || Label || 0x00 || Context || [L]2 || E(i))
+//
+// where E(i) is the next PRF-length sized chunk read from entropy.
+//
+// WARNING: This is not a NIST SP-800-108 key derivation function and the output
+// is not reproducible unless the same entropy is supplied again. It is only
+// suitable for generating non-reproducible keystreams, and must not be used where
+// the same key needs to be derived again from the same inputs.
+//
+// An error is returned if the required amount of entropy cannot be read.
+func CounterModeKeyWithEntropy(prf PRF, key, label, context []byte, entropy io.Reader, bitLength uint32) ([]byte, error) {
+	prfLen := prf.Len()
+	e := make([]byte, uint64(numBlocks(prfLen, bitLength))*uint64(prfLen))
+	if _, err := io.ReadFull(entropy, e); err != nil {
+		return nil, err
+	}
+
+	fixed := fixedBytes(label, context, bitLength)
+
+	return commonKDF(prf, bitLength, func(i uint32) ([]byte, error) {
+		var x bytes.Buffer
+		binary.Write(&x, binary.BigEndian, i)
+		x.Write(fixed)
+		x.Write(e[(i-1)*prfLen : i*prfLen])
+		return prf.Run(key, x.Bytes()), nil
+	})
+}
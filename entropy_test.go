@@ -0,0 +1,62 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type entropySuite struct{}
+
+var _ = Suite(&entropySuite{})
+
+func (s *entropySuite) entropy(seed byte) []byte {
+	e := make([]byte, 64)
+	for i := range e {
+		e[i] = seed + byte(i)
+	}
+	return e
+}
+
+func (s *entropySuite) TestDerive(c *C) {
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	k, err := CounterModeKeyWithEntropy(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), bytes.NewReader(s.entropy(0)), 400)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, decodeHexString(c, "1329b35764ba78b1531bc68e5eda42a1c74dd838e267d9deddff2913b7ff025de947c0b4e3987ae5dabda9b083c24aaac819"))
+}
+
+func (s *entropySuite) TestReproducibleWithSameEntropy(c *C) {
+	key := []byte("1234567890123456")
+	prf := NewHMACPRF(crypto.SHA256)
+
+	k1, err := CounterModeKeyWithEntropy(prf, key, []byte("foo"), nil, bytes.NewReader(s.entropy(10)), 512)
+	c.Check(err, IsNil)
+	k2, err := CounterModeKeyWithEntropy(prf, key, []byte("foo"), nil, bytes.NewReader(s.entropy(10)), 512)
+	c.Check(err, IsNil)
+	c.Check(k1, DeepEquals, k2)
+}
+
+func (s *entropySuite) TestDifferentEntropy(c *C) {
+	key := []byte("1234567890123456")
+	prf := NewHMACPRF(crypto.SHA256)
+
+	k1, err := CounterModeKeyWithEntropy(prf, key, []byte("foo"), nil, bytes.NewReader(s.entropy(10)), 512)
+	c.Check(err, IsNil)
+	k2, err := CounterModeKeyWithEntropy(prf, key, []byte("foo"), nil, bytes.NewReader(s.entropy(20)), 512)
+	c.Check(err, IsNil)
+	c.Check(k1, Not(DeepEquals), k2)
+	c.Check(k1, Not(DeepEquals), CounterModeKey(prf, key, []byte("foo"), nil, 512))
+}
+
+func (s *entropySuite) TestInsufficientEntropy(c *C) {
+	_, err := CounterModeKeyWithEntropy(NewHMACPRF(crypto.SHA256), []byte("1234567890123456"), []byte("foo"), nil, bytes.NewReader(s.entropy(0)[:40]), 512)
+	c.Check(err, Equals, io.ErrUnexpectedEOF)
+}
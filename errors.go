@@ -0,0 +1,70 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoPRF indicates that no PRF was supplied.
+	ErrNoPRF = errors.New("no PRF supplied")
+
+	// ErrInvalidMode indicates that an invalid iteration mode was supplied.
+	ErrInvalidMode = errors.New("invalid mode")
+
+	// ErrInvalidKeyLength indicates that the length of a secret key isn't
+	// acceptable for the PRF.
+	ErrInvalidKeyLength = errors.New("invalid key length")
+
+	// ErrBadIVLength indicates that the length of the IV supplied for
+	// feedback mode doesn't match the output length of the PRF.
+	ErrBadIVLength = errors.New("bad IV length")
+
+	// ErrUnsupportedCounterWidth indicates that the width of the iteration
+	// counter is not supported.
+	ErrUnsupportedCounterWidth = errors.New("unsupported counter width")
+
+	// ErrUnsupportedLengthWidth indicates that the width of the length field
+	// is not supported.
+	ErrUnsupportedLengthWidth = errors.New("unsupported length width")
+
+	// ErrOutputTooLong indicates that the requested output length can't be
+	// produced, either because it requires more iterations than the
+	// iteration counter permits or because it can't be represented in the
+	// length field.
+	ErrOutputTooLong = errors.New("output too long")
+
+	// ErrBufferTooSmall indicates that a caller supplied buffer is too small
+	// for the requested output.
+	ErrBufferTooSmall = errors.New("buffer too small")
+
+	// ErrAmbiguousParams indicates that AutoMode couldn't select an
+	// iteration mode from the supplied parameters.
+	ErrAmbiguousParams = errors.New("ambiguous parameters")
+)
+
+// Error is the type of the errors returned from this package that correspond to
+// one of the Err values. These can be tested for with errors.Is, and the Err
+// field can be inspected after using errors.As to obtain the Error.
+type Error struct {
+	Err error // One of the Err values
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError returns a new *Error of the specified kind with a message that is
+// formatted from the supplied arguments.
+func newError(err error, format string, args ...any) error {
+	return &Error{Err: err, msg: fmt.Sprintf(format, args...)}
+}
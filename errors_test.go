@@ -0,0 +1,108 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"errors"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type errorsSuite struct{}
+
+var _ = Suite(&errorsSuite{})
+
+func (s *errorsSuite) testDeriveError(c *C, params *Params, expected error, msg string) {
+	_, err := Derive(params)
+	c.Check(err, ErrorMatches, msg)
+	c.Check(errors.Is(err, expected), Equals, true)
+}
+
+func (s *errorsSuite) TestNoPRF(c *C) {
+	params := testParams()
+	params.PRF = nil
+	s.testDeriveError(c, params, ErrNoPRF, "no PRF supplied")
+}
+
+func (s *errorsSuite) TestInvalidMode(c *C) {
+	params := testParams()
+	params.Mode = Mode(5)
+	s.testDeriveError(c, params, ErrInvalidMode, "invalid mode 5")
+}
+
+func (s *errorsSuite) TestInvalidKeyLength(c *C) {
+	params := testParams()
+	params.Key = []byte("foo")
+	s.testDeriveError(c, params, ErrInvalidKeyLength, "key length 3 is shorter than the minimum of 14 bytes")
+}
+
+func (s *errorsSuite) TestBadIVLength(c *C) {
+	params := testParams()
+	params.Mode = FeedbackMode
+	params.IV = make([]byte, 16)
+	s.testDeriveError(c, params, ErrBadIVLength, "IV length 16 does not match the PRF output length 32")
+}
+
+func (s *errorsSuite) TestUnsupportedCounterWidth(c *C) {
+	params := testParams()
+	params.CounterWidth = 12
+	s.testDeriveError(c, params, ErrUnsupportedCounterWidth, "unsupported counter width 12")
+}
+
+func (s *errorsSuite) TestUnsupportedLengthWidth(c *C) {
+	params := testParams()
+	params.LengthWidth = 12
+	s.testDeriveError(c, params, ErrUnsupportedLengthWidth, "unsupported length width 12")
+}
+
+func (s *errorsSuite) TestOutputTooLongCounter(c *C) {
+	params := testParams()
+	params.CounterWidth = 8
+	params.BitLength = 256 * 256
+	s.testDeriveError(c, params, ErrOutputTooLong, "bit length 65536 requires too many iterations for the 8-bit counter")
+}
+
+func (s *errorsSuite) TestOutputTooLongLength(c *C) {
+	params := testParams()
+	params.LengthWidth = 8
+	s.testDeriveError(c, params, ErrOutputTooLong, "length 256 overflows the 8-bit length field")
+}
+
+func (s *errorsSuite) TestBufferTooSmall(c *C) {
+	_, err := DeriveInto(make([]byte, 16), testParams())
+	c.Check(err, ErrorMatches, "buffer of 16 bytes is too small for a 32 byte key")
+	c.Check(errors.Is(err, ErrBufferTooSmall), Equals, true)
+}
+
+func (s *errorsSuite) TestAs(c *C) {
+	params := testParams()
+	params.CounterWidth = 12
+	_, err := Derive(params)
+
+	var e *Error
+	c.Assert(errors.As(err, &e), Equals, true)
+	c.Check(e.Err, Equals, ErrUnsupportedCounterWidth)
+}
+
+func (s *errorsSuite) TestIsOtherKind(c *C) {
+	params := testParams()
+	params.CounterWidth = 12
+	_, err := Derive(params)
+	c.Check(errors.Is(err, ErrOutputTooLong), Equals, false)
+}
+
+func (s *errorsSuite) TestCheckKeyLengthCMAC(c *C) {
+	err := CheckKeyLength(NewCMACAESPRF(16), 24)
+	c.Check(err, ErrorMatches, `invalid key length 24 for CMAC-AES128 \(expected 16 bytes\)`)
+	c.Check(errors.Is(err, ErrInvalidKeyLength), Equals, true)
+}
+
+func (s *errorsSuite) TestCheckIterations(c *C) {
+	err := (&LayoutAssembler{CounterWidth: 8}).CheckIterations(256)
+	c.Check(err, ErrorMatches, "256 iterations are required but the iteration counter only permits 255")
+	c.Check(errors.Is(err, ErrOutputTooLong), Equals, true)
+}
@@ -1,8 +1,67 @@
 package kdf
 
+import "crypto/aes"
+
 var (
 	CounterModeKeyInternal = counterModeKeyInternal
 	FeedbackModeKeyInternal = feedbackModeKeyInternal
 	FixedBytes = fixedBytes
 	PipelineModeKeyInternal = pipelineModeKeyInternal
 )
+
+func MockCheckedCounterHook(fn func(value *uint32)) (restore func()) {
+	orig := checkedCounterHook
+	checkedCounterHook = func(c *checkedCounter) {
+		fn(&c.value)
+	}
+	return func() {
+		checkedCounterHook = orig
+	}
+}
+
+func MockPRFRegistry() (restore func()) {
+	prfsMu.Lock()
+	orig := prfs
+	prfs = make(map[string]func() PRF)
+	for k, v := range orig {
+		prfs[k] = v
+	}
+	prfsMu.Unlock()
+	return func() {
+		prfsMu.Lock()
+		prfs = orig
+		prfsMu.Unlock()
+	}
+}
+
+func AESKeyWrap(kek, key []byte) ([]byte, error) {
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return aesKeyWrap(c, key)
+}
+
+func AESKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return aesKeyUnwrap(c, wrapped)
+}
+
+func AESKeyWrapPad(kek, key []byte) ([]byte, error) {
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return aesKeyWrapPad(c, key)
+}
+
+func AESKeyUnwrapPad(kek, wrapped []byte) ([]byte, error) {
+	c, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return aesKeyUnwrapPad(c, wrapped)
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import "fmt"
+
+// LengthLocation describes the location of the length field in fixed input data
+// produced by FixedDataBuilder.
+type LengthLocation int
+
+const (
+	// LengthAfterContext places the length field after the context, as
+	// recommended by NIST SP-800-108.
+	LengthAfterContext LengthLocation = iota
+
+	// LengthBeforeLabel places the length field before the label.
+	LengthBeforeLabel
+
+	// LengthOmitted omits the length field from the fixed input data.
+	LengthOmitted
+)
+
+// FixedDataBuilder builds fixed input data in the form recommended by NIST
+// SP-800-108:
+//
+//	Label || Separator || Context || [L]2
+//
+// where [L]2 is the length of the derived key, which by default is the length
+// in bits encoded as a big-endian 32-bit integer. The resulting fixed data can
+// be used with the *WithFixedData and *WithAssembler functions for any of the
+// iteration modes. The zero value produces the same fixed data as the functions
+// that take a label and context.
+type FixedDataBuilder struct {
+	Label   []byte
+	Context []byte
+
+	// Separator is the byte that separates the label and context, which is
+	// 0x00 by default.
+	Separator byte
+
+	// LengthWidth is the length of the encoded length field in bits, which
+	// must be 8, 16, 24, 32 or 64. Zero selects a 32-bit field.
+	LengthWidth int
+
+	// LengthLittleEndian indicates that the length field should be encoded
+	// as a little-endian integer.
+	LengthLittleEndian bool
+
+	// LengthEncoder is used to encode the length field if it is set, in
+	// which case LengthWidth and LengthLittleEndian are ignored.
+	LengthEncoder IntegerEncoder
+
+	// LengthInBytes indicates that the length field should contain the
+	// length of the derived key in bytes rather than bits, rounded up to a
+	// whole number of bytes.
+	LengthInBytes bool
+
+	// LengthLocation is the location of the length field.
+	LengthLocation LengthLocation
+}
+
+func (b *FixedDataBuilder) lengthEncoder() IntegerEncoder {
+	if b.LengthEncoder != nil {
+		return b.LengthEncoder
+	}
+	width := b.LengthWidth
+	if width == 0 {
+		width = 32
+	}
+	if !validCounterWidth(width) {
+		panic(fmt.Sprintf("invalid length width %d", width))
+	}
+	return FixedWidthEncoder{Width: width, LittleEndian: b.LengthLittleEndian}
+}
+
+// lengthValue returns the value of the length field for a derived key of the
+// specified length in bits.
+func (b *FixedDataBuilder) lengthValue(bitLength uint32) uint64 {
+	if b.LengthInBytes {
+		return (uint64(bitLength) + 7) / 8
+	}
+	return uint64(bitLength)
+}
+
+// Build returns the fixed input data for a derived key of the specified length
+// in bits. This will panic if the length cannot be represented by the
+// configured length field.
+func (b *FixedDataBuilder) Build(bitLength uint32) []byte {
+	switch b.LengthLocation {
+	case LengthAfterContext, LengthBeforeLabel:
+	case LengthOmitted:
+		var res []byte
+		res = append(res, b.Label...)
+		res = append(res, b.Separator)
+		return append(res, b.Context...)
+	default:
+		panic("invalid length location")
+	}
+
+	enc := b.lengthEncoder()
+
+	l := b.lengthValue(bitLength)
+	if l > enc.MaxValue() {
+		if e, ok := enc.(FixedWidthEncoder); ok {
+			panic(fmt.Sprintf("length %d overflows %d-bit length field", l, e.Width))
+		}
+		panic(fmt.Sprintf("length %d exceeds the maximum value of %d", l, enc.MaxValue()))
+	}
+
+	var res []byte
+	if b.LengthLocation == LengthBeforeLabel {
+		res = enc.AppendInteger(res, l)
+	}
+	res = append(res, b.Label...)
+	res = append(res, b.Separator)
+	res = append(res, b.Context...)
+	if b.LengthLocation == LengthAfterContext {
+		res = enc.AppendInteger(res, l)
+	}
+	return res
+}
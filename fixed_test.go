@@ -0,0 +1,114 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type fixedSuite struct{}
+
+var _ = Suite(&fixedSuite{})
+
+func (s *fixedSuite) TestBuild(c *C) {
+	b := &FixedDataBuilder{Label: []byte("foo"), Context: []byte("bar")}
+	c.Check(b.Build(256), DeepEquals, []byte{'f', 'o', 'o', 0, 'b', 'a', 'r', 0, 0, 1, 0})
+}
+
+func (s *fixedSuite) TestBuildSeparator(c *C) {
+	b := &FixedDataBuilder{Label: []byte("foo"), Context: []byte("bar"), Separator: '|'}
+	c.Check(b.Build(0x01020304), DeepEquals, []byte{'f', 'o', 'o', '|', 'b', 'a', 'r', 1, 2, 3, 4})
+}
+
+func (s *fixedSuite) TestBuildEmpty(c *C) {
+	var b FixedDataBuilder
+	c.Check(b.Build(128), DeepEquals, []byte{0, 0, 0, 0, 128})
+}
+
+func (s *fixedSuite) TestModes(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	b := &FixedDataBuilder{Label: []byte("label"), Context: []byte("context")}
+	iv := make([]byte, 32)
+
+	c.Check(CounterModeKeyWithFixedData(prf, key, b.Build(640), 640), DeepEquals,
+		CounterModeKey(prf, key, []byte("label"), []byte("context"), 640))
+	c.Check(FeedbackModeKeyWithFixedData(prf, key, b.Build(640), iv, 640, true), DeepEquals,
+		FeedbackModeKey(prf, key, []byte("label"), []byte("context"), iv, 640, true))
+	c.Check(PipelineModeKeyWithFixedData(prf, key, b.Build(640), 640, true), DeepEquals,
+		PipelineModeKey(prf, key, []byte("label"), []byte("context"), 640, true))
+}
+
+func (s *fixedSuite) TestBuildLengthWidths(c *C) {
+	for _, t := range []struct {
+		width    int
+		expected []byte
+	}{
+		{width: 0, expected: []byte{0, 0, 1, 0}},
+		{width: 16, expected: []byte{1, 0}},
+		{width: 24, expected: []byte{0, 1, 0}},
+		{width: 32, expected: []byte{0, 0, 1, 0}},
+		{width: 64, expected: []byte{0, 0, 0, 0, 0, 0, 1, 0}},
+	} {
+		b := &FixedDataBuilder{Label: []byte("foo"), LengthWidth: t.width}
+		c.Check(b.Build(256), DeepEquals, append([]byte{'f', 'o', 'o', 0}, t.expected...))
+	}
+}
+
+func (s *fixedSuite) TestBuildLengthLittleEndian(c *C) {
+	b := &FixedDataBuilder{Label: []byte("foo"), LengthWidth: 16, LengthLittleEndian: true}
+	c.Check(b.Build(256), DeepEquals, []byte{'f', 'o', 'o', 0, 0, 1})
+}
+
+func (s *fixedSuite) TestBuildLengthInBytes(c *C) {
+	b := &FixedDataBuilder{Label: []byte("foo"), LengthWidth: 8, LengthInBytes: true}
+	c.Check(b.Build(256), DeepEquals, []byte{'f', 'o', 'o', 0, 32})
+	c.Check(b.Build(253), DeepEquals, []byte{'f', 'o', 'o', 0, 32})
+}
+
+func (s *fixedSuite) TestBuildLengthEncoder(c *C) {
+	b := &FixedDataBuilder{Label: []byte("foo"), LengthEncoder: decimalEncoder{}, LengthWidth: 12}
+	c.Check(b.Build(256), DeepEquals, []byte("foo\x00256"))
+	c.Check(func() { b.Build(1000) }, PanicMatches, "length 1000 exceeds the maximum value of 999")
+}
+
+func (s *fixedSuite) TestBuildLengthOverflow(c *C) {
+	b := &FixedDataBuilder{LengthWidth: 8}
+	c.Check(b.Build(255), DeepEquals, []byte{0, 255})
+	c.Check(func() { b.Build(256) }, PanicMatches, "length 256 overflows 8-bit length field")
+}
+
+func (s *fixedSuite) TestBuildInvalidLengthWidth(c *C) {
+	b := &FixedDataBuilder{LengthWidth: 12}
+	c.Check(func() { b.Build(256) }, PanicMatches, "invalid length width 12")
+}
+
+func (s *fixedSuite) TestBuildLengthLocations(c *C) {
+	for _, t := range []struct {
+		location LengthLocation
+		expected []byte
+	}{
+		{location: LengthAfterContext, expected: []byte{'f', 'o', 'o', 0, 'b', 'a', 'r', 0, 0, 1, 0}},
+		{location: LengthBeforeLabel, expected: []byte{0, 0, 1, 0, 'f', 'o', 'o', 0, 'b', 'a', 'r'}},
+		{location: LengthOmitted, expected: []byte{'f', 'o', 'o', 0, 'b', 'a', 'r'}},
+	} {
+		b := &FixedDataBuilder{Label: []byte("foo"), Context: []byte("bar"), LengthLocation: t.location}
+		c.Check(b.Build(256), DeepEquals, t.expected)
+	}
+}
+
+func (s *fixedSuite) TestBuildLengthOmittedIgnoresWidth(c *C) {
+	b := &FixedDataBuilder{Label: []byte("foo"), LengthWidth: 8, LengthLocation: LengthOmitted}
+	c.Check(b.Build(1024), DeepEquals, []byte{'f', 'o', 'o', 0})
+}
+
+func (s *fixedSuite) TestBuildInvalidLengthLocation(c *C) {
+	b := &FixedDataBuilder{LengthLocation: LengthLocation(5)}
+	c.Check(func() { b.Build(256) }, PanicMatches, "invalid length location")
+}
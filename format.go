@@ -0,0 +1,64 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+)
+
+// Format describes a textual encoding for derived key material.
+type Format int
+
+const (
+	// FormatHex encodes the key as lowercase hexadecimal.
+	FormatHex Format = iota
+
+	// FormatBase64 encodes the key using standard, padded base64.
+	FormatBase64
+
+	// FormatBase64URL encodes the key using unpadded, URL-safe base64.
+	FormatBase64URL
+
+	// FormatPEM encodes the key as a PEM block of type "SECRET KEY".
+	FormatPEM
+
+	// FormatJWK encodes the key as a JSON Web Key of type "oct", as defined
+	// in RFC 7518.
+	FormatJWK
+)
+
+// PEMBlockType is the PEM block type used by FormatPEM.
+const PEMBlockType = "SECRET KEY"
+
+type jwk struct {
+	Kty string `json:"kty"`
+	K   string `json:"k"`
+}
+
+// FormatKey encodes the supplied key material in the specified format.
+func FormatKey(key []byte, format Format) (string, error) {
+	switch format {
+	case FormatHex:
+		return hex.EncodeToString(key), nil
+	case FormatBase64:
+		return base64.StdEncoding.EncodeToString(key), nil
+	case FormatBase64URL:
+		return base64.RawURLEncoding.EncodeToString(key), nil
+	case FormatPEM:
+		return string(pem.EncodeToMemory(&pem.Block{Type: PEMBlockType, Bytes: key})), nil
+	case FormatJWK:
+		b, err := json.Marshal(&jwk{Kty: "oct", K: base64.RawURLEncoding.EncodeToString(key)})
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("invalid format %d", format)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type formatSuite struct{}
+
+var _ = Suite(&formatSuite{})
+
+var formatTestKey = []byte{0xfb, 0xff, 0x3e, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+func (s *formatSuite) testFormatKey(c *C, format Format, expected string) {
+	str, err := FormatKey(formatTestKey, format)
+	c.Check(err, IsNil)
+	c.Check(str, Equals, expected)
+}
+
+func (s *formatSuite) TestHex(c *C) {
+	s.testFormatKey(c, FormatHex, "fbff3e00010203040506")
+}
+
+func (s *formatSuite) TestBase64(c *C) {
+	s.testFormatKey(c, FormatBase64, "+/8+AAECAwQFBg==")
+}
+
+func (s *formatSuite) TestBase64URL(c *C) {
+	s.testFormatKey(c, FormatBase64URL, "-_8-AAECAwQFBg")
+}
+
+func (s *formatSuite) TestPEM(c *C) {
+	s.testFormatKey(c, FormatPEM, `-----BEGIN SECRET KEY-----
++/8+AAECAwQFBg==
+-----END SECRET KEY-----
+`)
+}
+
+func (s *formatSuite) TestJWK(c *C) {
+	s.testFormatKey(c, FormatJWK, `{"kty":"oct","k":"-_8-AAECAwQFBg"}`)
+}
+
+func (s *formatSuite) TestInvalid(c *C) {
+	_, err := FormatKey(formatTestKey, Format(100))
+	c.Check(err, ErrorMatches, "invalid format 100")
+}
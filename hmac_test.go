@@ -0,0 +1,118 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha3"
+	"hash"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type hmacSuite struct{}
+
+var _ = Suite(&hmacSuite{})
+
+var hmacTestKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+// The following tests were generated with the KBKDF implementation in OpenSSL,
+// using a label of "label" and a context of "context".
+
+func (s *hmacSuite) testCounterMode(c *C, h crypto.Hash, expected string) {
+	prf := NewHMACPRF(h)
+	c.Check(prf.Len(), Equals, uint32(h.Size()))
+	c.Check(CounterModeKey(prf, decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), 640), DeepEquals, decodeHexString(c, expected))
+}
+
+func (s *hmacSuite) testFeedbackMode(c *C, h crypto.Hash, iv, expected string) {
+	c.Check(FeedbackModeKey(NewHMACPRF(h), decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), decodeHexString(c, iv), 640, true), DeepEquals, decodeHexString(c, expected))
+}
+
+func (s *hmacSuite) TestCounterModeSHA3_224(c *C) {
+	s.testCounterMode(c, crypto.SHA3_224, "6667a2f8155e2e505bc41bb0d79b97615703e12fbce9df9db048cfb9589e5a5712de3386d31cdd42639ecc708758f0c922824bcffd0167fd90edd877c3bb11b933aefd62de0da4b1e38e96b96a3331c1")
+}
+
+func (s *hmacSuite) TestCounterModeSHA3_256(c *C) {
+	s.testCounterMode(c, crypto.SHA3_256, "a7b5f3a536f46287c2d6e3879db308090d3de29bf2ac937577c9800f84fdee77052d8fa810a199c523ffefe63fd8efe9ad1d5e6aaf22cb76666f41c8eefc2b427121d86e7184f2ee7fbfd95416fc0d1e")
+}
+
+func (s *hmacSuite) TestCounterModeSHA3_384(c *C) {
+	s.testCounterMode(c, crypto.SHA3_384, "415447cfab4597d0ac2a6c080b8fb4f0564df1991152c36508f0260472e37ccdf0403a29960133f86d5bc2a21d5a12390ecf4f0e96f096f715595a60edd2d879e20faf8311b6cc0695cf569afb66b041")
+}
+
+func (s *hmacSuite) TestCounterModeSHA3_512(c *C) {
+	s.testCounterMode(c, crypto.SHA3_512, "ad489318585abc6b3158433954cac55c4c3ce1a5d0a31527cc22ad5302e4d74a309b5a92da2ff101d59ca0fd9419083736b4c78a8ce48aaecf7e24479cb604e2befd1ed2f4a0c3f3684c0ef235c40faa")
+}
+
+func (s *hmacSuite) TestFeedbackModeSHA3_224(c *C) {
+	s.testFeedbackMode(c, crypto.SHA3_224,
+		"6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f",
+		"3f3d9a4514e956d25f798daf1f02c5149e256d6365afdd53ef50f652406f1eb9db185350c97a8fce9dc0e16a082be925e4c655253c00dc4b530d21c0f4f47221a36be08a16a654832829035791088401")
+}
+
+func (s *hmacSuite) TestFeedbackModeSHA3_256(c *C) {
+	s.testFeedbackMode(c, crypto.SHA3_256,
+		"6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f80818283",
+		"fc76b261756ed3528a6893cee13f54004a9e4e95f6bc4884de5d56e755b3e28e43feaade88ac5f94768bb64ee890300a60221ed6d672c3dcd9fccb062b22509041844f4253af4c14cf35e3fa91ae43ed")
+}
+
+func (s *hmacSuite) TestFeedbackModeSHA3_384(c *C) {
+	s.testFeedbackMode(c, crypto.SHA3_384,
+		"6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f90919293",
+		"1827b77739db53c286c4c2b59fbfb7fb8e750d2078b07a99e7e2dc79bf863e5e6d7821cb2f21c6ee582c8f0bf5f25cadbfc597347e28c90f264f8b1656624d8a856ea01402e19ebc0d366afe5caa1721")
+}
+
+func (s *hmacSuite) TestFeedbackModeSHA3_512(c *C) {
+	s.testFeedbackMode(c, crypto.SHA3_512,
+		"6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3",
+		"4252908cfdfc865f4b5ccbb98b5f6453e6fc6eb2edddff38d57edefc3987232d0cdfab8259a54fce71259897e033ae92a30ed28535289d23a7b9c79dbb2aae1256dd1af36534aa21e9993fc570fe2da8")
+}
+
+func (s *hmacSuite) TestCounterModeSHA512_224(c *C) {
+	s.testCounterMode(c, crypto.SHA512_224, "a0878aa7834ef36927a4cb7f9b7e6b668cccc62492c32221d4b353f34e485cdcf802c647b9e62061f66de513322204eb4a5f44bcab695323c789e73dbf82ee6323600dd746bbecaf2d69e204fd3ff35d")
+}
+
+func (s *hmacSuite) TestCounterModeSHA512_256(c *C) {
+	s.testCounterMode(c, crypto.SHA512_256, "37430896f9169182be5ddad9aa9f7f2ddec93d43e028cac86cded1e1258a019681f4d85526c459bb1d69b64c3c9bc3d5dc0e46ebf5d6a0a2ada115b7035dfa63ac84431389deeb5c6d19df65b0f17d9d")
+}
+
+func (s *hmacSuite) TestFeedbackModeSHA512_224(c *C) {
+	s.testFeedbackMode(c, crypto.SHA512_224,
+		"6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f",
+		"ebdcc164cdd97c1c0f56d0fe0d89f8cbf61bb7d7a45bcb071f15208604d105b7245671d0fe89ef8e7bbaf553a9da20c6a2938b575a472acdc2774e3814d09ef481a04333367742baac4bac9ba9c4ce85")
+}
+
+func (s *hmacSuite) TestFeedbackModeSHA512_256(c *C) {
+	s.testFeedbackMode(c, crypto.SHA512_256,
+		"6465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f80818283",
+		"0eb75e069b76615361f3bfd09d34eda8ab969ab11d6f8d628d108bc4c58832b737cd831acd8ae55258110d01f4504771c2791cd7e2baf9ca29a5517451356e9ea941830db67e3aeefbff322cbe804019")
+}
+
+func (s *hmacSuite) TestString(c *C) {
+	c.Check(NewHMACPRF(crypto.SHA3_256).(interface{ String() string }).String(), Equals, "HMAC-SHA3-256")
+	c.Check(NewHMACPRF(crypto.SHA512_256).(interface{ String() string }).String(), Equals, "HMAC-SHA-512/256")
+}
+
+func (s *hmacSuite) TestFromFunc(c *C) {
+	prf := NewHMACPRFFromFunc(func() hash.Hash { return sha3.New256() })
+	c.Check(prf.Len(), Equals, uint32(32))
+	c.Check(CounterModeKey(prf, decodeHexString(c, hmacTestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "a7b5f3a536f46287c2d6e3879db308090d3de29bf2ac937577c9800f84fdee77052d8fa810a199c523ffefe63fd8efe9ad1d5e6aaf22cb76666f41c8eefc2b427121d86e7184f2ee7fbfd95416fc0d1e"))
+}
+
+func (s *hmacSuite) TestFromFuncMatchesHMACPRF(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+	c.Check(NewHMACPRFFromFunc(sha256.New).Run(key, []byte("foo")), DeepEquals, NewHMACPRF(crypto.SHA256).Run(key, []byte("foo")))
+	c.Check(NewHMACPRFFromFunc(sha256.New224).Len(), Equals, uint32(28))
+}
+
+func (s *hmacSuite) TestMD5Rejected(c *C) {
+	c.Check(func() { NewHMACPRF(crypto.MD5) }, PanicMatches, "insecure digest algorithm MD5: use the legacy package")
+	c.Check(func() { NewHMACPRF(crypto.MD5SHA1) }, PanicMatches, "insecure digest algorithm MD5\\+SHA1: use the legacy package")
+}
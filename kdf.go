@@ -4,15 +4,19 @@
 
 /*
 Package kdf implements the key derivation functions defined in NIST SP-800-108.
+
+The length of a derived key is specified in bits. If it is not a multiple of 8,
+the output is rounded up to a whole number of bytes and the unused low-order
+bits of the final byte are cleared, as in the NIST CAVP test vectors.
 */
 package kdf
 
 import (
-	"bytes"
 	"crypto"
 	"crypto/hmac"
-	"encoding/binary"
+	"encoding"
 	"hash"
+	"time"
 )
 
 // PRF represents a pseudorandom function, required by the key derivation functions.
@@ -32,73 +36,473 @@ func (p hmacPRF) Len() uint32 {
 	return uint32(p.h.Size())
 }
 
+func (p hmacPRF) String() string {
+	return "HMAC-" + p.h.String()
+}
+
+func (p hmacPRF) BlockSize() int {
+	return p.h.New().BlockSize()
+}
+
+func (p hmacPRF) KeySize() int {
+	return p.h.Size()
+}
+
+func (p hmacPRF) CheckKeyLength(n int) error {
+	return checkMinKeyLength(n, MinHMACKeyLength)
+}
+
 func (p hmacPRF) Run(s, x []byte) []byte {
 	h := hmac.New(func() hash.Hash { return p.h.New() }, s)
 	h.Write(x)
 	return h.Sum(nil)
 }
 
-// NewHMACPRF creates a new HMAC based PRF using the supplied digest algorithm.
+func (p hmacPRF) NewHash(key []byte) hash.Hash {
+	return hmac.New(func() hash.Hash { return p.h.New() }, key)
+}
+
+func (p hmacPRF) NewKeyedPRF(key []byte) KeyedPRF {
+	return NewKeyedHMAC(func() hash.Hash { return p.h.New() }, key)
+}
+
+// keyedHMAC is a KeyedPRF that reuses a single HMAC instance, which retains the
+// key-dependent inner and outer hash states between invocations. This relies on
+// the digest implementing encoding.BinaryMarshaler, as the digests in the
+// standard library do, else the padded key is rehashed on every invocation.
+type keyedHMAC struct {
+	h hash.Hash
+}
+
+func (p *keyedHMAC) Len() uint32 {
+	return uint32(p.h.Size())
+}
+
+func (p *keyedHMAC) Run(x []byte) []byte {
+	return p.AppendRun(nil, x)
+}
+
+func (p *keyedHMAC) keyedHash() hash.Hash {
+	return p.h
+}
+
+func (p *keyedHMAC) AppendRun(dst, x []byte) []byte {
+	p.h.Reset()
+	p.h.Write(x)
+	return p.h.Sum(dst)
+}
+
+// clonedHMAC is a KeyedPRF that computes HMAC for digests that implement
+// hash.Cloner but not encoding.BinaryMarshaler. The inner and outer hash states
+// are computed once from the padded key, and cloned for each invocation.
+type clonedHMAC struct {
+	inner hash.Cloner
+	outer hash.Cloner
+}
+
+func (p *clonedHMAC) Len() uint32 {
+	return uint32(p.inner.Size())
+}
+
+func (p *clonedHMAC) Run(x []byte) []byte {
+	inner, err := p.inner.Clone()
+	if err != nil {
+		panic(err)
+	}
+	inner.Write(x)
+	sum := inner.Sum(nil)
+
+	outer, err := p.outer.Clone()
+	if err != nil {
+		panic(err)
+	}
+	outer.Write(sum)
+	return outer.Sum(sum[:0])
+}
+
+func (p *clonedHMAC) keyedHash() hash.Hash {
+	h := &clonedHMACHash{p: p}
+	h.Reset()
+	return h
+}
+
+// clonedHMACHash is a hash.Hash that computes HMAC from the states in a
+// clonedHMAC, and which returns to the keyed state when it is reset.
+type clonedHMACHash struct {
+	p     *clonedHMAC
+	inner hash.Hash
+}
+
+func (h *clonedHMACHash) Write(p []byte) (int, error) {
+	return h.inner.Write(p)
+}
+
+func (h *clonedHMACHash) Sum(b []byte) []byte {
+	sum := h.inner.Sum(nil)
+	outer, err := h.p.outer.Clone()
+	if err != nil {
+		panic(err)
+	}
+	outer.Write(sum)
+	return outer.Sum(b)
+}
+
+func (h *clonedHMACHash) Reset() {
+	inner, err := h.p.inner.Clone()
+	if err != nil {
+		panic(err)
+	}
+	h.inner = inner
+}
+
+func (h *clonedHMACHash) Size() int {
+	return h.p.inner.Size()
+}
+
+func (h *clonedHMACHash) BlockSize() int {
+	return h.p.inner.BlockSize()
+}
+
+// newClonedHMAC returns a clonedHMAC for the supplied digest and key, or nil if
+// the digest cannot be cloned.
+func newClonedHMAC(h func() hash.Hash, key []byte) *clonedHMAC {
+	inner, ok := h().(hash.Cloner)
+	if !ok {
+		return nil
+	}
+	outer := h().(hash.Cloner)
+
+	blockSize := inner.BlockSize()
+	if len(key) > blockSize {
+		inner.Write(key)
+		key = inner.Sum(nil)
+		inner.Reset()
+	}
+	pad := make([]byte, blockSize)
+	defer clear(pad)
+
+	copy(pad, key)
+	for i := range pad {
+		pad[i] ^= 0x36
+	}
+	inner.Write(pad)
+	for i := range pad {
+		pad[i] ^= 0x36 ^ 0x5c
+	}
+	outer.Write(pad)
+
+	if _, err := inner.Clone(); err != nil {
+		return nil
+	}
+	return &clonedHMAC{inner: inner, outer: outer}
+}
+
+// NewKeyedHMAC returns a KeyedPRF that computes HMAC with the digest returned
+// from the supplied function and the supplied key. The key-dependent inner and
+// outer hash states are computed once, and are restored for each invocation if
+// the digest implements encoding.BinaryMarshaler or hash.Cloner, so that the
+// padded key isn't rehashed for every block. This is intended for implementing
+// PRFKeyer in HMAC based PRFs that are provided by other packages.
+func NewKeyedHMAC(h func() hash.Hash, key []byte) KeyedPRF {
+	if _, ok := h().(encoding.BinaryMarshaler); !ok {
+		if p := newClonedHMAC(h, key); p != nil {
+			return p
+		}
+	}
+	return &keyedHMAC{h: hmac.New(h, key)}
+}
+
+// NewHMACPRF creates a new HMAC based PRF using the supplied digest algorithm,
+// which can be any of the algorithms supported by the crypto package, including
+// the SHA-3 family and the truncated SHA-512/224 and SHA-512/256 variants of
+// SHA-512. The digest algorithm must be linked in to the binary, eg,
+// by importing crypto/sha256 or crypto/sha3.
+//
+// This will panic if h is MD5 or MD5SHA1. HMAC-MD5 is only available from the
+// legacy subpackage, for migrating data from systems that used it.
 func NewHMACPRF(h crypto.Hash) PRF {
+	switch h {
+	case crypto.MD5, crypto.MD5SHA1:
+		panic("insecure digest algorithm " + h.String() + ": use the legacy package")
+	}
 	return hmacPRF{h}
 }
 
+type hmacFuncPRF struct {
+	newHash func() hash.Hash
+	size    uint32
+}
+
+func (p *hmacFuncPRF) Len() uint32 {
+	return p.size
+}
+
+func (p *hmacFuncPRF) BlockSize() int {
+	return p.newHash().BlockSize()
+}
+
+func (p *hmacFuncPRF) KeySize() int {
+	return int(p.size)
+}
+
+func (p *hmacFuncPRF) CheckKeyLength(n int) error {
+	return checkMinKeyLength(n, MinHMACKeyLength)
+}
+
+func (p *hmacFuncPRF) Run(s, x []byte) []byte {
+	h := hmac.New(p.newHash, s)
+	h.Write(x)
+	return h.Sum(nil)
+}
+
+func (p *hmacFuncPRF) NewHash(key []byte) hash.Hash {
+	return hmac.New(p.newHash, key)
+}
+
+func (p *hmacFuncPRF) NewKeyedPRF(key []byte) KeyedPRF {
+	return NewKeyedHMAC(p.newHash, key)
+}
+
+// NewHMACPRFFromFunc creates a new HMAC based PRF using the digest algorithm
+// returned from the supplied function. This is useful for digest algorithms
+// that aren't registered with the crypto package, such as vendored or hardware
+// accelerated implementations. The function must return a new instance each
+// time it is called.
+func NewHMACPRFFromFunc(h func() hash.Hash) PRF {
+	return &hmacFuncPRF{newHash: h, size: uint32(h().Size())}
+}
+
 func fixedBytes(label, context []byte, bitLength uint32) []byte {
-	var res bytes.Buffer
-	res.Write(label)
-	res.Write([]byte{0})
-	res.Write(context)
-	binary.Write(&res, binary.BigEndian, bitLength)
-	return res.Bytes()
+	b := FixedDataBuilder{Label: label, Context: context}
+	return b.Build(bitLength)
+}
+
+// numBlocks returns the number of PRF invocations required to produce the
+// specified number of bits.
+func numBlocks(prfLen, bitLength uint32) uint32 {
+	return uint32((uint64(bitLength) + uint64(prfLen)*8 - 1) / (uint64(prfLen) * 8))
 }
 
-func commonKDF(prfLen uint32, fixed []byte, bitLength uint32, fn func(uint32) []byte) []byte {
-	n := (bitLength + prfLen - 1) / prfLen
+func commonKDFToSink(prf PRF, bitLength uint32, sink OutputSink, fn func(uint32) ([]byte, error)) error {
+	m := currentMetrics()
+	var start time.Time
+	if m != nil {
+		start = time.Now()
+	}
+
+	n := numBlocks(prf.Len(), bitLength)
+	remaining := int((bitLength + 7) / 8)
 
-	var res bytes.Buffer
+	sink.Grow(remaining)
 
 	for i := uint32(1); i <= n; i++ {
-		res.Write(fn(i))
+		k, err := fn(i)
+		if err != nil {
+			return err
+		}
+		if len(k) > remaining {
+			k = k[:remaining]
+		}
+		if i == n && bitLength%8 != 0 {
+			// Clear the unused low-order bits of the final byte. The
+			// block is copied first because it may be retained as the
+			// iteration variable.
+			k = append([]byte(nil), k...)
+			k[len(k)-1] &= 0xff << (8 - bitLength%8)
+		}
+		sink.WriteBlock(k)
+		remaining -= len(k)
+	}
+
+	if m != nil {
+		m.RecordDerivation(prfName(prf), n, time.Since(start))
 	}
 
-	return res.Bytes()[:(bitLength+7)/8]
+	return nil
 }
 
-func counterModeKeyInternal(prf PRF, key, fixed []byte, bitLength uint32) []byte {
-	return commonKDF(prf.Len(), fixed, bitLength, func(i uint32) []byte {
-		var x bytes.Buffer
-		binary.Write(&x, binary.BigEndian, i)
-		x.Write(fixed)
-		return prf.Run(key, x.Bytes())
+func commonKDF(prf PRF, bitLength uint32, fn func(uint32) ([]byte, error)) ([]byte, error) {
+	var sink SliceSink
+	if err := commonKDFToSink(prf, bitLength, &sink, fn); err != nil {
+		return nil, err
+	}
+	return sink.Bytes(), nil
+}
+
+// iterationChecker is implemented by assemblers that limit the number of
+// iterations in a derivation.
+type iterationChecker interface {
+	CheckIterations(n uint32) error
+}
+
+// ModeKeyWithAssembler derives a key of the specified length using the supplied
+// iteration mode, PRF and secret key. The input to the PRF for each iteration is
+// produced by the supplied assembler. The iv argument is passed to the mode, and
+// is the IV in feedback mode and the initial value A(0) in double-pipeline mode.
+//
+// If the assembler has a CheckIterations method, such as LayoutAssembler, this
+// will panic with the returned error before invoking the PRF if the requested
+// length requires too many iterations.
+func ModeKeyWithAssembler(mode IterationMode, prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32) []byte {
+	var sink SliceSink
+	ModeKeyToSink(mode, prf, key, assembler, iv, bitLength, &sink)
+	return sink.Bytes()
+}
+
+// ModeKeyToSink derives a key in the same way as ModeKeyWithAssembler, but
+// writes the derived key to the supplied sink.
+func ModeKeyToSink(mode IterationMode, prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32, sink OutputSink) {
+	// The block function never fails without a check function.
+	modeKeyToSink(mode, prf, key, assembler, iv, bitLength, sink, nil)
+}
+
+// modeKeyToSink implements ModeKeyToSink. If check is not nil, it is called
+// before each block is computed, and the derivation is aborted if it returns an
+// error.
+func modeKeyToSink(mode IterationMode, prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32, sink OutputSink, check func() error) error {
+	n := numBlocks(prf.Len(), bitLength)
+	if c, ok := assembler.(iterationChecker); ok {
+		if err := c.CheckIterations(n); err != nil {
+			panic(err)
+		}
+	}
+
+	// The modes defined in NIST SP-800-108 only use the key to invoke the
+	// PRF, so bind it to the PRF once for the whole derivation rather than
+	// re-keying the PRF for every block. This isn't worthwhile for
+	// derivations that only invoke the PRF once.
+	//
+	// Where possible, the input for each block is also written directly
+	// to the keyed PRF rather than being assembled in to a buffer first.
+	var fn func(uint32) []byte
+	if m, ok := mode.(Mode); ok && (n > 1 || m == PipelineMode) {
+		keyed := newKeyedPRF(prf, key)
+		if la, ok := assembler.(*LayoutAssembler); ok {
+			if kh, ok := keyed.(keyedPRFHasher); ok {
+				fn = m.hashBlockFunc(kh.keyedHash(), la, iv)
+			}
+		}
+		if _, ok := prf.(PRFKeyer); ok && fn == nil {
+			fn = m.BlockFunc(&boundPRF{prf: prf, keyed: keyed}, nil, assembler, iv)
+		}
+	}
+	if fn == nil {
+		fn = mode.BlockFunc(prf, key, assembler, iv)
+	}
+
+	return commonKDFToSink(prf, bitLength, sink, func(i uint32) ([]byte, error) {
+		if check != nil {
+			if err := check(); err != nil {
+				return nil, err
+			}
+		}
+		return fn(i), nil
 	})
 }
 
+// CounterModeKeyWithAssembler derives a key of the specified length using the
+// counter mode function defined in NIST SP-800-108, using the supplied PRF and
+// secret key. The input to the PRF for each iteration is produced by the supplied
+// assembler.
+func CounterModeKeyWithAssembler(prf PRF, key []byte, assembler FixedDataAssembler, bitLength uint32) []byte {
+	if numBlocks(prf.Len(), bitLength) == 1 {
+		if c, ok := assembler.(iterationChecker); ok {
+			if err := c.CheckIterations(1); err != nil {
+				panic(err)
+			}
+		}
+		return counterModeSingleBlock(prf, key, assembler.Assemble(1, 0, nil), bitLength)
+	}
+
+	var sink SliceSink
+	CounterModeKeyToSink(prf, key, assembler, bitLength, &sink)
+	return sink.Bytes()
+}
+
+// CounterModeKeyToSink derives a key in the same way as
+// CounterModeKeyWithAssembler, but writes the derived key to the supplied sink.
+func CounterModeKeyToSink(prf PRF, key []byte, assembler FixedDataAssembler, bitLength uint32, sink OutputSink) {
+	ModeKeyToSink(CounterMode, prf, key, assembler, nil, bitLength, sink)
+}
+
+// counterModeSingleBlock derives a key in counter mode that only requires a
+// single invocation of the PRF with the supplied input, which is the case for
+// most keys. This avoids the generic iteration loop, and the PRF output is
+// returned as the derived key rather than being copied in to a separate buffer.
+func counterModeSingleBlock(prf PRF, key, x []byte, bitLength uint32) []byte {
+	m := currentMetrics()
+	var start time.Time
+	if m != nil {
+		start = time.Now()
+	}
+
+	k := prf.Run(key, x)
+	if n := int((bitLength + 7) / 8); n < len(k) {
+		clear(k[n:])
+		k = k[:n:n]
+	}
+	if bitLength%8 != 0 {
+		k[len(k)-1] &= 0xff << (8 - bitLength%8)
+	}
+
+	if m != nil {
+		m.RecordDerivation(prfName(prf), 1, time.Since(start))
+	}
+
+	return k
+}
+
+func counterModeKeyInternal(prf PRF, key, fixed []byte, bitLength uint32) []byte {
+	if numBlocks(prf.Len(), bitLength) == 1 {
+		// Build the input for the first block directly, rather than via
+		// a LayoutAssembler.
+		x := make([]byte, 4, 4+len(fixed))
+		x[3] = 1
+		return counterModeSingleBlock(prf, key, append(x, fixed...), bitLength)
+	}
+	return CounterModeKeyWithAssembler(prf, key, NewStandardAssembler(fixed, true), bitLength)
+}
+
 // CounterModeKey derives a key of the specified length using the counter mode
 // function defined in NIST SP-800-108, using the supplied PRF, secret key and
-// other input parameters.
+// other input parameters. The fixed input data is encoded as
+// Label || 0x00 || Context || [L]2, as described by FixedDataBuilder.
 func CounterModeKey(prf PRF, key, label, context []byte, bitLength uint32) []byte {
 	return counterModeKeyInternal(prf, key, fixedBytes(label, context, bitLength), bitLength)
 }
 
-func feedbackModeKeyInternal(prf PRF, key, fixed, iv []byte, bitLength uint32, useCounter bool) []byte {
-	k := iv
+// CounterModeKeyWithFixedData derives a key of the specified length using the
+// counter mode function defined in NIST SP-800-108, using the supplied PRF, secret
+// key and fixed input data. This is useful where the fixed input data is not
+// encoded in the form of Label || 0x00 || Context || [L]2.
+func CounterModeKeyWithFixedData(prf PRF, key, fixedData []byte, bitLength uint32) []byte {
+	return counterModeKeyInternal(prf, key, fixedData, bitLength)
+}
 
-	return commonKDF(prf.Len(), fixed, bitLength, func(i uint32) []byte {
-		var x bytes.Buffer
-		x.Write(k)
-		if useCounter {
-			binary.Write(&x, binary.BigEndian, i)
-		}
-		x.Write(fixed)
+// FeedbackModeKeyWithAssembler derives a key of the specified length using the
+// feedback mode function defined in NIST SP-800-108, using the supplied PRF, secret
+// key and IV. The input to the PRF for each iteration is produced by the supplied
+// assembler.
+func FeedbackModeKeyWithAssembler(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32) []byte {
+	var sink SliceSink
+	FeedbackModeKeyToSink(prf, key, assembler, iv, bitLength, &sink)
+	return sink.Bytes()
+}
 
-		k = prf.Run(key, x.Bytes())
-		return k
-	})
+// FeedbackModeKeyToSink derives a key in the same way as
+// FeedbackModeKeyWithAssembler, but writes the derived key to the supplied sink.
+func FeedbackModeKeyToSink(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32, sink OutputSink) {
+	ModeKeyToSink(FeedbackMode, prf, key, assembler, iv, bitLength, sink)
+}
+
+func feedbackModeKeyInternal(prf PRF, key, fixed, iv []byte, bitLength uint32, useCounter bool) []byte {
+	return FeedbackModeKeyWithAssembler(prf, key, NewStandardAssembler(fixed, useCounter), iv, bitLength)
 }
 
 // FeebackModeKey derives a key of the specified length using the feedback mode
 // function defined in NIST SP-800-108, using the supplied PRF, secret key and
-// other input parameters.
+// other input parameters. The fixed input data is encoded as
+// Label || 0x00 || Context || [L]2, as described by FixedDataBuilder.
 //
 // The useCounter argument specifies whether the iteration counter should be
 // used as an input to the PRF.
@@ -106,29 +510,54 @@ func FeedbackModeKey(prf PRF, key, label, context, iv []byte, bitLength uint32,
 	return feedbackModeKeyInternal(prf, key, fixedBytes(label, context, bitLength), iv, bitLength, useCounter)
 }
 
-func pipelineModeKeyInternal(prf PRF, key, fixed []byte, bitLength uint32, useCounter bool) []byte {
-	a := fixed
+// FeedbackModeKeyWithFixedData derives a key of the specified length using the
+// feedback mode function defined in NIST SP-800-108, using the supplied PRF, secret
+// key, fixed input data and IV.
+//
+// The useCounter argument specifies whether the iteration counter should be
+// used as an input to the PRF.
+func FeedbackModeKeyWithFixedData(prf PRF, key, fixedData, iv []byte, bitLength uint32, useCounter bool) []byte {
+	return feedbackModeKeyInternal(prf, key, fixedData, iv, bitLength, useCounter)
+}
 
-	return commonKDF(prf.Len(), fixed, bitLength, func(i uint32) []byte {
-		a = prf.Run(key, a)
+// PipelineModeKeyWithAssembler derives a key of the specified length using the
+// double-pipeline iteration mode function defined in NIST SP-800-108, using the
+// supplied PRF and secret key. The input to the PRF for each iteration is
+// produced by the supplied assembler. The iv argument is the initial value A(0)
+// of the first pipeline, which is normally the fixed input data.
+func PipelineModeKeyWithAssembler(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32) []byte {
+	var sink SliceSink
+	PipelineModeKeyToSink(prf, key, assembler, iv, bitLength, &sink)
+	return sink.Bytes()
+}
 
-		var x bytes.Buffer
-		x.Write(a)
-		if useCounter {
-			binary.Write(&x, binary.BigEndian, i)
-		}
-		x.Write(fixed)
+// PipelineModeKeyToSink derives a key in the same way as
+// PipelineModeKeyWithAssembler, but writes the derived key to the supplied sink.
+func PipelineModeKeyToSink(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32, sink OutputSink) {
+	ModeKeyToSink(PipelineMode, prf, key, assembler, iv, bitLength, sink)
+}
 
-		return prf.Run(key, x.Bytes())
-	})
+func pipelineModeKeyInternal(prf PRF, key, fixed []byte, bitLength uint32, useCounter bool) []byte {
+	return PipelineModeKeyWithAssembler(prf, key, NewStandardAssembler(fixed, useCounter), fixed, bitLength)
 }
 
 // PipelineModeKey derives a key of the specified length using the double-pipeline
 // iteration mode function defined in NIST SP-800-108, using the supplied PRF,
-// secret key and other input parameters.
+// secret key and other input parameters. The fixed input data is encoded as
+// Label || 0x00 || Context || [L]2, as described by FixedDataBuilder.
 //
 // The useCounter argument specifies whether the iteration counter should be
 // used as an input to the PRF.
 func PipelineModeKey(prf PRF, key, label, context []byte, bitLength uint32, useCounter bool) []byte {
 	return pipelineModeKeyInternal(prf, key, fixedBytes(label, context, bitLength), bitLength, useCounter)
 }
+
+// PipelineModeKeyWithFixedData derives a key of the specified length using the
+// double-pipeline iteration mode function defined in NIST SP-800-108, using the
+// supplied PRF, secret key and fixed input data.
+//
+// The useCounter argument specifies whether the iteration counter should be
+// used as an input to the PRF.
+func PipelineModeKeyWithFixedData(prf PRF, key, fixedData []byte, bitLength uint32, useCounter bool) []byte {
+	return pipelineModeKeyInternal(prf, key, fixedData, bitLength, useCounter)
+}
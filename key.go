@@ -0,0 +1,122 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// KCVMethod selects the method used to compute a key check value.
+type KCVMethod int
+
+const (
+	// KCVEncryptZero computes the key check value as the first 3 bytes of
+	// the encryption of a block of zero bytes. This is the traditional
+	// method used for DES and TDEA keys.
+	KCVEncryptZero KCVMethod = iota
+
+	// KCVCMAC computes the key check value as the first 5 bytes of the CMAC
+	// of a block of zero bytes, as described in ANSI X9.24-1:2017 for AES
+	// keys.
+	KCVCMAC
+)
+
+func (m KCVMethod) String() string {
+	switch m {
+	case KCVEncryptZero:
+		return "encrypt-zero"
+	case KCVCMAC:
+		return "cmac"
+	default:
+		return fmt.Sprintf("KCVMethod(%d)", int(m))
+	}
+}
+
+// Key holds derived key material and provides a way to wipe it once it is no
+// longer needed. The key material is also wiped when the Key becomes
+// unreachable, although callers should not rely on this and should call
+// Zeroize explicitly.
+type Key struct {
+	b []byte
+}
+
+// NewKey returns a Key that takes ownership of the supplied key material. The
+// caller should not retain any other reference to it.
+func NewKey(b []byte) *Key {
+	k := &Key{b: b}
+	runtime.AddCleanup(k, func(b []byte) { clear(b) }, b)
+	return k
+}
+
+// DeriveKey derives a key in the same way as Derive, returning it as a Key.
+func DeriveKey(params *Params) (*Key, error) {
+	b := make([]byte, (uint64(params.BitLength)+7)/8)
+	if _, err := DeriveInto(b, params); err != nil {
+		clear(b)
+		return nil, err
+	}
+	return NewKey(b), nil
+}
+
+// Bytes returns the key material. The returned slice aliases the key, so it is
+// wiped by Zeroize. It returns nil once the key has been wiped.
+func (k *Key) Bytes() []byte {
+	return k.b
+}
+
+// Len returns the length of the key in bytes.
+func (k *Key) Len() int {
+	return len(k.b)
+}
+
+// Zeroize wipes the key material. The key cannot be used afterwards.
+func (k *Key) Zeroize() {
+	clear(k.b)
+	k.b = nil
+}
+
+// KCV computes a key check value for this key using the specified method,
+// treating it as an AES key. An error is returned if the key is not a valid AES
+// key.
+func (k *Key) KCV(method KCVMethod) ([]byte, error) {
+	return k.KCVWithCipher(aes.NewCipher, method)
+}
+
+// KCVWithCipher computes a key check value for this key using the specified
+// method, treating it as a key for the block cipher created by the supplied
+// function. An error is returned if the cipher cannot be created, or if the
+// method is invalid.
+func (k *Key) KCVWithCipher(newCipher func(key []byte) (cipher.Block, error), method KCVMethod) ([]byte, error) {
+	if k.b == nil {
+		return nil, errors.New("key has been zeroized")
+	}
+	c, err := newCipher(k.b)
+	if err != nil {
+		return nil, err
+	}
+	zero := make([]byte, c.BlockSize())
+
+	switch method {
+	case KCVEncryptZero:
+		out := make([]byte, c.BlockSize())
+		c.Encrypt(out, zero)
+		return out[:3], nil
+	case KCVCMAC:
+		switch c.BlockSize() {
+		case 8, 16:
+		default:
+			return nil, fmt.Errorf("unsupported cipher block size %d", c.BlockSize())
+		}
+		m := newCMAC(c)
+		m.Write(zero)
+		return m.Sum(nil)[:5], nil
+	default:
+		return nil, fmt.Errorf("invalid KCV method %d", method)
+	}
+}
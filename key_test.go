@@ -0,0 +1,108 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type keySuite struct{}
+
+var _ = Suite(&keySuite{})
+
+func (s *keySuite) TestDeriveKey(c *C) {
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Key:          decodeHexString(c, hmacTestKey),
+		Label:        []byte("foo"),
+		Context:      []byte("bar"),
+		CounterWidth: 32,
+		BitLength:    128}
+
+	key, err := DeriveKey(params)
+	c.Assert(err, IsNil)
+	c.Check(key.Len(), Equals, 16)
+	c.Check(key.Bytes(), DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 128))
+}
+
+func (s *keySuite) TestDeriveKeyInvalidParams(c *C) {
+	_, err := DeriveKey(&Params{})
+	c.Check(err, ErrorMatches, "no PRF supplied")
+}
+
+func (s *keySuite) TestZeroize(c *C) {
+	b := []byte{1, 2, 3, 4}
+	key := NewKey(b)
+	key.Zeroize()
+	c.Check(b, DeepEquals, []byte{0, 0, 0, 0})
+	c.Check(key.Bytes(), IsNil)
+	c.Check(key.Len(), Equals, 0)
+
+	_, err := key.KCV(KCVEncryptZero)
+	c.Check(err, ErrorMatches, "key has been zeroized")
+}
+
+func (s *keySuite) TestKCVEncryptZeroAES(c *C) {
+	b := decodeHexString(c, "000102030405060708090a0b0c0d0e0f")
+	block, err := aes.NewCipher(b)
+	c.Assert(err, IsNil)
+	expected := make([]byte, 16)
+	block.Encrypt(expected, expected)
+
+	kcv, err := NewKey(b).KCV(KCVEncryptZero)
+	c.Check(err, IsNil)
+	c.Check(kcv, DeepEquals, expected[:3])
+}
+
+func (s *keySuite) TestKCVCMACAES(c *C) {
+	b := decodeHexString(c, "000102030405060708090a0b0c0d0e0f1011121314151617")
+	expected := NewCMACAESPRF(24).Run(b, make([]byte, 16))
+
+	kcv, err := NewKey(b).KCV(KCVCMAC)
+	c.Check(err, IsNil)
+	c.Check(kcv, DeepEquals, expected[:5])
+}
+
+func (s *keySuite) TestKCVEncryptZeroTDEA(c *C) {
+	b := decodeHexString(c, "0123456789abcdeffedcba98765432100123456789abcdef")
+
+	kcv, err := NewKey(b).KCVWithCipher(des.NewTripleDESCipher, KCVEncryptZero)
+	c.Check(err, IsNil)
+	c.Check(kcv, DeepEquals, decodeHexString(c, "08d7b4"))
+}
+
+func (s *keySuite) TestKCVInvalidKey(c *C) {
+	_, err := NewKey(make([]byte, 20)).KCV(KCVEncryptZero)
+	c.Check(err, ErrorMatches, "crypto/aes: invalid key size 20")
+}
+
+func (s *keySuite) TestKCVInvalidMethod(c *C) {
+	_, err := NewKey(make([]byte, 16)).KCV(KCVMethod(5))
+	c.Check(err, ErrorMatches, "invalid KCV method 5")
+}
+
+type testBlock4 struct{}
+
+func (testBlock4) BlockSize() int          { return 4 }
+func (testBlock4) Encrypt(dst, src []byte) {}
+func (testBlock4) Decrypt(dst, src []byte) {}
+
+func (s *keySuite) TestKCVCMACUnsupportedBlockSize(c *C) {
+	_, err := NewKey(make([]byte, 16)).KCVWithCipher(func([]byte) (cipher.Block, error) { return testBlock4{}, nil }, KCVCMAC)
+	c.Check(err, ErrorMatches, "unsupported cipher block size 4")
+}
+
+func (s *keySuite) TestKCVMethodString(c *C) {
+	c.Check(KCVEncryptZero.String(), Equals, "encrypt-zero")
+	c.Check(KCVCMAC.String(), Equals, "cmac")
+	c.Check(KCVMethod(5).String(), Equals, "KCVMethod(5)")
+}
@@ -0,0 +1,267 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"encoding/binary"
+	"hash"
+	"sync"
+	"time"
+)
+
+// KeyedPRF is a PRF that is bound to a single secret key, so that any state that
+// depends only on the key can be computed once and reused for every invocation.
+// Implementations are not required to be safe for concurrent use.
+type KeyedPRF interface {
+	// Len returns the length of this PRF.
+	Len() uint32
+
+	// Run computes bytes for the supplied input value.
+	Run(x []byte) []byte
+}
+
+// PRFKeyer is an optional interface implemented by PRFs that can be bound to a
+// secret key in order to avoid repeating work that depends only on the key.
+type PRFKeyer interface {
+	// NewKeyedPRF returns a new KeyedPRF for the supplied secret key.
+	NewKeyedPRF(key []byte) KeyedPRF
+}
+
+// KeyedPRFAppender is implemented by KeyedPRFs that can append their output to
+// a supplied slice, which avoids allocating a new slice for each invocation. The
+// keyed PRFs for HMAC with the digests in the standard library and for CMAC
+// implement it, and don't allocate if dst has sufficient capacity.
+type KeyedPRFAppender interface {
+	KeyedPRF
+
+	// AppendRun computes bytes for the supplied input value, appends them
+	// to dst and returns the resulting slice.
+	AppendRun(dst, x []byte) []byte
+}
+
+// keyedPRFHasher is implemented by KeyedPRFs that can consume their input
+// incrementally. The returned hash.Hash computes the keyed PRF, and returns to
+// its keyed state when it is reset.
+type keyedPRFHasher interface {
+	keyedHash() hash.Hash
+}
+
+// keyedPRFCloner is implemented by KeyedPRFs that can create an independent
+// copy of themselves which shares any state that depends only on the key, such
+// as the key schedule of a block cipher. The original can be cloned from
+// multiple goroutines as long as it isn't used directly.
+type keyedPRFCloner interface {
+	cloneKeyed() KeyedPRF
+}
+
+type genericKeyedPRF struct {
+	prf PRF
+	key []byte
+}
+
+func (p *genericKeyedPRF) Len() uint32 {
+	return p.prf.Len()
+}
+
+func (p *genericKeyedPRF) Run(x []byte) []byte {
+	return p.prf.Run(p.key, x)
+}
+
+// genericKeyedPRFHasher is a genericKeyedPRF for a PRF that implements
+// PRFHasher.
+type genericKeyedPRFHasher struct {
+	genericKeyedPRF
+	hasher PRFHasher
+}
+
+func (p *genericKeyedPRFHasher) keyedHash() hash.Hash {
+	return p.hasher.NewHash(p.key)
+}
+
+// newKeyedPRF binds the supplied PRF to the supplied key, using PRFKeyer if the
+// PRF implements it.
+func newKeyedPRF(prf PRF, key []byte) KeyedPRF {
+	if k, ok := prf.(PRFKeyer); ok {
+		return k.NewKeyedPRF(key)
+	}
+	if h, ok := prf.(PRFHasher); ok {
+		return &genericKeyedPRFHasher{genericKeyedPRF: genericKeyedPRF{prf: prf, key: key}, hasher: h}
+	}
+	return &genericKeyedPRF{prf: prf, key: key}
+}
+
+// boundPRF adapts a KeyedPRF to the PRF interface, so that it can be used with
+// the iteration modes. The key supplied to Run is ignored.
+type boundPRF struct {
+	prf   PRF
+	keyed KeyedPRF
+}
+
+func (p *boundPRF) Len() uint32 {
+	return p.keyed.Len()
+}
+
+func (p *boundPRF) String() string {
+	return prfName(p.prf)
+}
+
+func (p *boundPRF) Run(_, x []byte) []byte {
+	return p.keyed.Run(x)
+}
+
+// kdfState is the state used by a single derivation from a KDF. It contains the
+// keyed PRF and the scratch buffers for the PRF input and output, and is
+// retained in a pool for reuse by subsequent derivations so that a derivation
+// in the steady state only allocates the derived key.
+type kdfState struct {
+	keyed KeyedPRF
+	input []byte
+	block []byte
+}
+
+// derive derives a key of the specified length in to the supplied buffer, which
+// must be the length of the key rounded up to a whole number of bytes.
+func (s *kdfState) derive(prf PRF, out, label, context []byte, bitLength uint32) {
+	// The PRF input is [i]2 || Label || 0x00 || Context || [L]2, and only the
+	// counter changes between iterations.
+	s.input = binary.BigEndian.AppendUint32(s.input[:0], 0)
+	s.input = append(s.input, label...)
+	s.input = append(s.input, 0x00)
+	s.input = append(s.input, context...)
+	s.input = binary.BigEndian.AppendUint32(s.input, bitLength)
+	s.run(prf, out, bitLength)
+}
+
+// deriveWithFixedData derives a key in the same way as derive, but with a PRF
+// input of [i]2 || FixedData.
+func (s *kdfState) deriveWithFixedData(prf PRF, out, fixed []byte, bitLength uint32) {
+	s.input = binary.BigEndian.AppendUint32(s.input[:0], 0)
+	s.input = append(s.input, fixed...)
+	s.run(prf, out, bitLength)
+}
+
+// run performs the counter mode iterations for the PRF input that has been
+// prepared in s.input, where the first 4 bytes are the counter.
+func (s *kdfState) run(prf PRF, out []byte, bitLength uint32) {
+	m := currentMetrics()
+	var start time.Time
+	if m != nil {
+		start = time.Now()
+	}
+
+	n := numBlocks(s.keyed.Len(), bitLength)
+	appender, canAppend := s.keyed.(KeyedPRFAppender)
+
+	off := 0
+	for i := uint32(1); i <= n; i++ {
+		binary.BigEndian.PutUint32(s.input, i)
+		if canAppend {
+			s.block = appender.AppendRun(s.block[:0], s.input)
+			off += copy(out[off:], s.block)
+		} else {
+			off += copy(out[off:], s.keyed.Run(s.input))
+		}
+	}
+	if bitLength%8 != 0 {
+		out[len(out)-1] &= 0xff << (8 - bitLength%8)
+	}
+
+	clear(s.block)
+
+	if m != nil {
+		m.RecordDerivation(prfName(prf), n, time.Since(start))
+	}
+}
+
+// KDF derives keys from a single PRF and key derivation key using the counter
+// mode function defined in NIST SP-800-108. Any state that depends only on the
+// key derivation key is computed once and reused for every derivation, which
+// makes this more efficient than CounterModeKey for deriving many keys from the
+// same key derivation key.
+//
+// A KDF is safe for concurrent use by multiple goroutines. Each concurrent
+// derivation uses its own instance of the keyed PRF state and scratch buffers,
+// and these are retained for reuse by subsequent derivations, so that repeated
+// derivations don't allocate anything other than the derived keys.
+type KDF struct {
+	prf  PRF
+	key  []byte
+	pool sync.Pool
+}
+
+// NewKDF creates a new KDF for the supplied PRF and key derivation key.
+func NewKDF(prf PRF, key []byte) *KDF {
+	k := &KDF{prf: prf, key: append([]byte(nil), key...)}
+	keyed := newKeyedPRF(k.prf, k.key)
+	if c, ok := keyed.(keyedPRFCloner); ok {
+		// Compute the key dependent state once, and share it between
+		// all instances of the keyed PRF.
+		k.pool.New = func() any {
+			return &kdfState{keyed: c.cloneKeyed()}
+		}
+	} else {
+		k.pool.New = func() any {
+			return &kdfState{keyed: newKeyedPRF(k.prf, k.key)}
+		}
+		k.pool.Put(&kdfState{keyed: keyed})
+	}
+	return k
+}
+
+// Derive derives a key of the specified length in the same way as
+// CounterModeKey, using the supplied label and context.
+func (k *KDF) Derive(label, context []byte, bitLength uint32) []byte {
+	if bitLength == 0 {
+		return nil
+	}
+	out := make([]byte, (uint64(bitLength)+7)/8)
+
+	s := k.pool.Get().(*kdfState)
+	s.derive(k.prf, out, label, context, bitLength)
+	k.pool.Put(s)
+
+	return out
+}
+
+// DeriveRequest describes a single derivation performed by DeriveMany.
+type DeriveRequest struct {
+	Label     []byte
+	Context   []byte
+	BitLength uint32 // The length of the derived key in bits
+}
+
+// DeriveMany derives a key for each of the supplied requests in the same way as
+// Derive, returning the keys in the same order as the requests. The keyed PRF
+// state is shared between all of the derivations and the keys are allocated
+// from a single buffer, which avoids most of the per-derivation overhead when
+// deriving a large number of keys. Each returned key has a capacity equal to its
+// length.
+func (k *KDF) DeriveMany(reqs []DeriveRequest) [][]byte {
+	s := k.pool.Get().(*kdfState)
+	defer k.pool.Put(s)
+
+	var total int
+	for _, req := range reqs {
+		total += int((uint64(req.BitLength) + 7) / 8)
+	}
+	buf := make([]byte, total)
+
+	keys := make([][]byte, len(reqs))
+	for i, req := range reqs {
+		n := int((uint64(req.BitLength) + 7) / 8)
+		keys[i] = buf[:n:n]
+		buf = buf[n:]
+
+		s.derive(k.prf, keys[i], req.Label, req.Context, req.BitLength)
+	}
+	return keys
+}
+
+// DeriveMany derives a key for each of the supplied requests from a single key
+// derivation key in the same way as CounterModeKey. It is equivalent to calling
+// DeriveMany on a KDF created with NewKDF.
+func DeriveMany(prf PRF, key []byte, reqs []DeriveRequest) [][]byte {
+	return NewKDF(prf, key).DeriveMany(reqs)
+}
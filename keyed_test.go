@@ -0,0 +1,245 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type keyedSuite struct{}
+
+var _ = Suite(&keyedSuite{})
+
+func (s *keyedSuite) TestKeyedHMAC(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+
+	k := prf.(PRFKeyer).NewKeyedPRF(key)
+	c.Check(k.Len(), Equals, uint32(32))
+	c.Check(k.Run([]byte("foo")), DeepEquals, prf.Run(key, []byte("foo")))
+	c.Check(k.Run([]byte("bar")), DeepEquals, prf.Run(key, []byte("bar")))
+}
+
+func (s *keyedSuite) TestKeyedHMACFromFunc(c *C) {
+	prf := NewHMACPRFFromFunc(sha256.New)
+	key := decodeHexString(c, hmacTestKey)
+
+	k := prf.(PRFKeyer).NewKeyedPRF(key)
+	c.Check(k.Run([]byte("foo")), DeepEquals, prf.Run(key, []byte("foo")))
+	c.Check(k.Run([]byte("bar")), DeepEquals, prf.Run(key, []byte("bar")))
+}
+
+func (s *keyedSuite) testKDF(c *C, prf PRF, key []byte) {
+	kdf := NewKDF(prf, key)
+	for _, t := range []struct {
+		label     string
+		context   string
+		bitLength uint32
+	}{
+		{label: "foo", context: "bar", bitLength: 256},
+		{label: "foo", context: "baz", bitLength: 640},
+		{label: "label", context: "context", bitLength: 100},
+	} {
+		c.Check(kdf.Derive([]byte(t.label), []byte(t.context), t.bitLength), DeepEquals,
+			CounterModeKey(prf, key, []byte(t.label), []byte(t.context), t.bitLength))
+	}
+}
+
+func (s *keyedSuite) TestKDFHMAC(c *C) {
+	s.testKDF(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+}
+
+func (s *keyedSuite) TestKDFGeneric(c *C) {
+	s.testKDF(c, NewCMACAESPRF(16), decodeHexString(c, "000102030405060708090a0b0c0d0e0f"))
+}
+
+func (s *keyedSuite) TestKDFCopiesKey(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+	kdf := NewKDF(NewCMACAESPRF(32), key)
+	expected := kdf.Derive([]byte("foo"), nil, 256)
+
+	key[0] ^= 0xff
+	c.Check(kdf.Derive([]byte("foo"), nil, 256), DeepEquals, expected)
+}
+
+func (s *keyedSuite) testKDFConcurrent(c *C, prf PRF, key []byte) {
+	kdf := NewKDF(prf, key)
+
+	const n = 16
+	results := make([][][]byte, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				context := []byte(fmt.Sprintf("%d-%d", i, j))
+				results[i] = append(results[i], kdf.Derive([]byte("foo"), context, 512))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		for j, k := range r {
+			context := []byte(fmt.Sprintf("%d-%d", i, j))
+			c.Check(k, DeepEquals, CounterModeKey(prf, key, []byte("foo"), context, 512))
+		}
+	}
+}
+
+func (s *keyedSuite) TestKDFConcurrent(c *C) {
+	s.testKDFConcurrent(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+}
+
+func (s *keyedSuite) TestKDFConcurrentCMAC(c *C) {
+	s.testKDFConcurrent(c, NewCMACAESPRF(16), decodeHexString(c, "000102030405060708090a0b0c0d0e0f"))
+}
+
+func (s *keyedSuite) TestDeriveMany(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+
+	reqs := []DeriveRequest{
+		{Label: []byte("foo"), Context: []byte("1"), BitLength: 256},
+		{Label: []byte("bar"), Context: []byte("2"), BitLength: 1000},
+		{Label: nil, Context: []byte("3"), BitLength: 12}}
+
+	keys := DeriveMany(prf, key, reqs)
+	c.Assert(keys, HasLen, len(reqs))
+	for i, req := range reqs {
+		c.Check(keys[i], DeepEquals, CounterModeKey(prf, key, req.Label, req.Context, req.BitLength), Commentf("request %d", i))
+		c.Check(cap(keys[i]), Equals, len(keys[i]))
+	}
+}
+
+func (s *keyedSuite) TestDeriveManyEmpty(c *C) {
+	c.Check(NewKDF(NewHMACPRF(crypto.SHA256), make([]byte, 32)).DeriveMany(nil), HasLen, 0)
+}
+
+// countingKeyerPRF is a PRF that implements PRFKeyer and counts how it is
+// invoked.
+type countingKeyerPRF struct {
+	PRF
+	keyed int
+	runs  int
+}
+
+func (p *countingKeyerPRF) Run(s, x []byte) []byte {
+	p.runs++
+	return p.PRF.Run(s, x)
+}
+
+func (p *countingKeyerPRF) NewKeyedPRF(key []byte) KeyedPRF {
+	p.keyed++
+	return p.PRF.(PRFKeyer).NewKeyedPRF(key)
+}
+
+func (s *keyedSuite) TestModesBindKeyOnce(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1000)
+
+	for _, t := range []struct {
+		mode     Mode
+		iv       []byte
+		expected []byte
+	}{
+		{mode: CounterMode, expected: CounterModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), 1000)},
+		{mode: FeedbackMode, iv: make([]byte, 32), expected: FeedbackModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), make([]byte, 32), 1000, true)},
+		{mode: PipelineMode, iv: fixed, expected: PipelineModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), 1000, true)},
+	} {
+		prf := &countingKeyerPRF{PRF: NewHMACPRF(crypto.SHA256)}
+		c.Check(ModeKeyWithAssembler(t.mode, prf, key, NewStandardAssembler(fixed, true), t.iv, 1000), DeepEquals, t.expected)
+		c.Check(prf.keyed, Equals, 1, Commentf("%v", t.mode))
+		c.Check(prf.runs, Equals, 0, Commentf("%v", t.mode))
+	}
+}
+
+func (s *keyedSuite) TestSingleBlockDoesntBindKey(c *C) {
+	prf := &countingKeyerPRF{PRF: NewHMACPRF(crypto.SHA256)}
+	key := decodeHexString(c, hmacTestKey)
+	c.Check(CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 256), DeepEquals,
+		CounterModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), 256))
+	c.Check(prf.keyed, Equals, 0)
+	c.Check(prf.runs, Equals, 1)
+}
+
+// cloneOnlyHash is a digest that implements hash.Cloner but not
+// encoding.BinaryMarshaler.
+type cloneOnlyHash struct {
+	hash.Hash
+}
+
+func (h cloneOnlyHash) Clone() (hash.Cloner, error) {
+	c, err := h.Hash.(hash.Cloner).Clone()
+	if err != nil {
+		return nil, err
+	}
+	return cloneOnlyHash{c}, nil
+}
+
+// opaqueHash is a digest that implements neither hash.Cloner nor
+// encoding.BinaryMarshaler.
+type opaqueHash struct {
+	hash.Hash
+}
+
+func (s *keyedSuite) testNewKeyedHMAC(c *C, h func() hash.Hash) {
+	for _, key := range [][]byte{
+		decodeHexString(c, hmacTestKey),
+		bytes.Repeat([]byte{0xaa}, 64),
+		bytes.Repeat([]byte{0x55}, 100),
+	} {
+		k := NewKeyedHMAC(h, key)
+		c.Check(k.Len(), Equals, uint32(32))
+		for _, x := range []string{"foo", "bar", ""} {
+			expected := hmac.New(sha256.New, key)
+			expected.Write([]byte(x))
+			c.Check(k.Run([]byte(x)), DeepEquals, expected.Sum(nil))
+		}
+	}
+}
+
+func (s *keyedSuite) TestNewKeyedHMACMarshalable(c *C) {
+	s.testNewKeyedHMAC(c, sha256.New)
+}
+
+func (s *keyedSuite) TestNewKeyedHMACCloneOnly(c *C) {
+	s.testNewKeyedHMAC(c, func() hash.Hash { return cloneOnlyHash{sha256.New()} })
+}
+
+func (s *keyedSuite) TestNewKeyedHMACOpaque(c *C) {
+	s.testNewKeyedHMAC(c, func() hash.Hash { return opaqueHash{sha256.New()} })
+}
+
+func (s *keyedSuite) TestNewKeyedHMACCloneOnlyCounterMode(c *C) {
+	prf := NewHMACPRFFromFunc(func() hash.Hash { return cloneOnlyHash{sha256.New()} })
+	key := decodeHexString(c, hmacTestKey)
+	c.Check(CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 1000), DeepEquals,
+		CounterModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), []byte("bar"), 1000))
+}
+
+func (s *keyedSuite) TestNewKeyedHMACCloneOnlyModes(c *C) {
+	prf := NewHMACPRFFromFunc(func() hash.Hash { return cloneOnlyHash{sha256.New()} })
+	expectedPRF := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	iv := make([]byte, 32)
+
+	c.Check(FeedbackModeKey(prf, key, []byte("foo"), []byte("bar"), iv, 1000, true), DeepEquals,
+		FeedbackModeKey(expectedPRF, key, []byte("foo"), []byte("bar"), iv, 1000, true))
+	c.Check(PipelineModeKey(prf, key, []byte("foo"), []byte("bar"), 1000, true), DeepEquals,
+		PipelineModeKey(expectedPRF, key, []byte("foo"), []byte("bar"), 1000, true))
+}
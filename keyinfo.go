@@ -0,0 +1,43 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+// MinHMACKeyLength is the minimum length in bytes of a key derivation key that
+// is accepted by the HMAC based PRFs, which corresponds to the 112-bit security
+// strength required by NIST SP-800-131A.
+const MinHMACKeyLength = 14
+
+// PRFKeyInfo is an optional interface implemented by PRFs that can provide
+// guidance about their key derivation keys. All of the PRFs provided by this
+// module implement it.
+type PRFKeyInfo interface {
+	// BlockSize returns the internal block size of this PRF in bytes.
+	BlockSize() int
+
+	// KeySize returns the natural key size of this PRF in bytes.
+	KeySize() int
+
+	// CheckKeyLength returns an error if a key derivation key of the
+	// specified length in bytes is not acceptable for this PRF.
+	CheckKeyLength(n int) error
+}
+
+// CheckKeyLength returns an error if the supplied PRF implements PRFKeyInfo and
+// indicates that a key derivation key of the specified length in bytes is not
+// acceptable. PRFs that don't implement PRFKeyInfo accept any key length.
+func CheckKeyLength(prf PRF, n int) error {
+	info, ok := prf.(PRFKeyInfo)
+	if !ok {
+		return nil
+	}
+	return info.CheckKeyLength(n)
+}
+
+func checkMinKeyLength(n, min int) error {
+	if n < min {
+		return newError(ErrInvalidKeyLength, "key length %d is shorter than the minimum of %d bytes", n, min)
+	}
+	return nil
+}
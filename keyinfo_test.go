@@ -0,0 +1,81 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	_ "crypto/sha512"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type keyInfoSuite struct{}
+
+var _ = Suite(&keyInfoSuite{})
+
+func (s *keyInfoSuite) testKeyInfo(c *C, prf PRF, blockSize, keySize int) {
+	info, ok := prf.(PRFKeyInfo)
+	c.Assert(ok, Equals, true)
+	c.Check(info.BlockSize(), Equals, blockSize)
+	c.Check(info.KeySize(), Equals, keySize)
+}
+
+func (s *keyInfoSuite) TestHMACSHA256(c *C) {
+	s.testKeyInfo(c, NewHMACPRF(crypto.SHA256), 64, 32)
+}
+
+func (s *keyInfoSuite) TestHMACSHA384(c *C) {
+	s.testKeyInfo(c, NewHMACPRF(crypto.SHA384), 128, 48)
+}
+
+func (s *keyInfoSuite) TestHMACFromFunc(c *C) {
+	s.testKeyInfo(c, NewHMACPRFFromFunc(sha256.New224), 64, 28)
+}
+
+func (s *keyInfoSuite) TestCMACAES256(c *C) {
+	s.testKeyInfo(c, NewCMACAESPRF(32), 16, 32)
+}
+
+func (s *keyInfoSuite) TestCMACTDEA2(c *C) {
+	s.testKeyInfo(c, NewCMACTDEAPRF(16), 8, 16)
+}
+
+func (s *keyInfoSuite) TestKMAC128(c *C) {
+	s.testKeyInfo(c, NewKMACPRF(KMAC128, nil, 0), 168, 16)
+}
+
+func (s *keyInfoSuite) TestKMAC256(c *C) {
+	s.testKeyInfo(c, NewKMACPRF(KMAC256, nil, 0), 136, 32)
+}
+
+func (s *keyInfoSuite) TestCSHAKE256(c *C) {
+	s.testKeyInfo(c, NewCSHAKEPRF(CSHAKE256, nil, 64), 136, 32)
+}
+
+func (s *keyInfoSuite) TestCheckKeyLengthHMAC(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	c.Check(CheckKeyLength(prf, 14), IsNil)
+	c.Check(CheckKeyLength(prf, 128), IsNil)
+	c.Check(CheckKeyLength(prf, 13), ErrorMatches, "key length 13 is shorter than the minimum of 14 bytes")
+}
+
+func (s *keyInfoSuite) TestCheckKeyLengthCMAC(c *C) {
+	prf := NewCMACAESPRF(16)
+	c.Check(CheckKeyLength(prf, 16), IsNil)
+	c.Check(CheckKeyLength(prf, 32), ErrorMatches, `invalid key length 32 for CMAC-AES128 \(expected 16 bytes\)`)
+}
+
+func (s *keyInfoSuite) TestCheckKeyLengthKMAC(c *C) {
+	prf := NewKMACPRF(KMAC256, nil, 0)
+	c.Check(CheckKeyLength(prf, 32), IsNil)
+	c.Check(CheckKeyLength(prf, 16), ErrorMatches, "key length 16 is shorter than the minimum of 32 bytes")
+}
+
+func (s *keyInfoSuite) TestCheckKeyLengthUnsupported(c *C) {
+	c.Check(CheckKeyLength(testPRF{NewHMACPRF(crypto.SHA256)}, 0), IsNil)
+}
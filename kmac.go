@@ -0,0 +1,195 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/sha3"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// KMACVariant selects the KMAC function defined in NIST SP-800-185.
+type KMACVariant int
+
+const (
+	// KMAC128 selects the KMAC128 function.
+	KMAC128 KMACVariant = iota
+
+	// KMAC256 selects the KMAC256 function.
+	KMAC256
+)
+
+func (v KMACVariant) String() string {
+	switch v {
+	case KMAC128:
+		return "KMAC128"
+	case KMAC256:
+		return "KMAC256"
+	default:
+		return fmt.Sprintf("KMACVariant(%d)", int(v))
+	}
+}
+
+func (v KMACVariant) newCSHAKE(n, s []byte) *sha3.SHAKE {
+	switch v {
+	case KMAC128:
+		return sha3.NewCSHAKE128(n, s)
+	case KMAC256:
+		return sha3.NewCSHAKE256(n, s)
+	default:
+		panic("invalid KMAC variant")
+	}
+}
+
+// securityStrength returns the security strength of the variant in bytes.
+func (v KMACVariant) securityStrength() int {
+	if v == KMAC256 {
+		return 32
+	}
+	return 16
+}
+
+// leftEncode implements left_encode from NIST SP-800-185.
+func leftEncode(x uint64) []byte {
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[1:], x)
+	i := 1
+	for i < 8 && b[i] == 0 {
+		i++
+	}
+	b[i-1] = byte(9 - i)
+	return b[i-1:]
+}
+
+// rightEncode implements right_encode from NIST SP-800-185.
+func rightEncode(x uint64) []byte {
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[:8], x)
+	i := 0
+	for i < 7 && b[i] == 0 {
+		i++
+	}
+	b[8] = byte(8 - i)
+	return b[i:]
+}
+
+// bytepadKey implements bytepad(encode_string(key), w) from NIST SP-800-185.
+func bytepadKey(key []byte, w int) []byte {
+	res := leftEncode(uint64(w))
+	res = append(res, leftEncode(uint64(len(key))*8)...)
+	res = append(res, key...)
+	if r := len(res) % w; r != 0 {
+		res = append(res, make([]byte, w-r)...)
+	}
+	return res
+}
+
+// newKMAC returns a cSHAKE instance that has been initialized for KMAC with the
+// supplied key and customization string.
+func newKMAC(v KMACVariant, key, s []byte) *sha3.SHAKE {
+	h := v.newCSHAKE([]byte("KMAC"), s)
+	h.Write(bytepadKey(key, h.BlockSize()))
+	return h
+}
+
+// kmacFinal completes a KMAC computation for the specified output length in bits.
+// If the length isn't a whole number of bytes, the unused low-order bits of the
+// final byte are cleared, as they are by the iteration modes.
+func kmacFinal(h *sha3.SHAKE, bitLength uint32) []byte {
+	h.Write(rightEncode(uint64(bitLength)))
+	res := make([]byte, (bitLength+7)/8)
+	h.Read(res)
+	if bitLength%8 != 0 {
+		res[len(res)-1] &= 0xff << (8 - bitLength%8)
+	}
+	return res
+}
+
+type kmacPRF struct {
+	variant       KMACVariant
+	customization []byte
+	size          uint32
+}
+
+func (p *kmacPRF) Len() uint32 {
+	return p.size
+}
+
+func (p *kmacPRF) String() string {
+	return p.variant.String()
+}
+
+func (p *kmacPRF) BlockSize() int {
+	return p.variant.newCSHAKE(nil, nil).BlockSize()
+}
+
+// KeySize returns the security strength of the KMAC variant in bytes.
+func (p *kmacPRF) KeySize() int {
+	return p.variant.securityStrength()
+}
+
+func (p *kmacPRF) CheckKeyLength(n int) error {
+	return checkMinKeyLength(n, p.KeySize())
+}
+
+func (p *kmacPRF) Run(s, x []byte) []byte {
+	h := newKMAC(p.variant, s, p.customization)
+	h.Write(x)
+	return kmacFinal(h, p.size*8)
+}
+
+// NewKMACPRF creates a new KMAC based PRF for use with the iteration modes, using
+// the specified KMAC variant and customization string. The size argument is the
+// length in bytes of the output of each PRF invocation, and is encoded as the
+// requested output length in each KMAC computation. If it is zero, a default of
+// 32 bytes for KMAC128 and 64 bytes for KMAC256 is used.
+//
+// Note that this is distinct from the KMAC based key derivation function, which
+// is implemented by KMACKey.
+func NewKMACPRF(variant KMACVariant, customization []byte, size uint32) PRF {
+	var defaultSize uint32
+	switch variant {
+	case KMAC128:
+		defaultSize = 32
+	case KMAC256:
+		defaultSize = 64
+	default:
+		panic("invalid KMAC variant")
+	}
+	if size == 0 {
+		size = defaultSize
+	}
+	return &kmacPRF{variant: variant, customization: customization, size: size}
+}
+
+// KMACKey derives a key of the specified length using the KMAC based key
+// derivation function defined in NIST SP-800-108r1, using the supplied secret key
+// and other input parameters. The key is computed as:
+//
+//	KMAC#(key, Context, L, Label)
+//
+// where the context is the main input string, L is the requested output length
+// and the label is the customization string.
+//
+// Unlike the iteration modes, the output is produced by a single KMAC invocation
+// and no separate fixed input data is constructed.
+func KMACKey(variant KMACVariant, key, label, context []byte, bitLength uint32) []byte {
+	m := currentMetrics()
+	var start time.Time
+	if m != nil {
+		start = time.Now()
+	}
+
+	h := newKMAC(variant, key, label)
+	h.Write(context)
+	res := kmacFinal(h, bitLength)
+
+	if m != nil {
+		m.RecordDerivation(variant.String(), 1, time.Since(start))
+	}
+
+	return res
+}
@@ -0,0 +1,24 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package kmac makes the KMAC128 and KMAC256 PRFs from the parent package available
+by name, so that they can be selected with NewPRFByName, PRFValue and Algorithm.
+It is imported for its side-effects:
+
+	import _ "github.com/chrisccoulson/go-sp800.108-kdf/kmac"
+
+The PRFs are registered with the default output length and no customization
+string. Other configurations can be created directly with NewKMACPRF.
+*/
+package kmac
+
+import (
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+func init() {
+	kdf.RegisterPRF("KMAC128", func() kdf.PRF { return kdf.NewKMACPRF(kdf.KMAC128, nil, 0) })
+	kdf.RegisterPRF("KMAC256", func() kdf.PRF { return kdf.NewKMACPRF(kdf.KMAC256, nil, 0) })
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kmac_test
+
+import (
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	_ "github.com/chrisccoulson/go-sp800.108-kdf/kmac"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type kmacSuite struct{}
+
+var _ = Suite(&kmacSuite{})
+
+func (s *kmacSuite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("KMAC128")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, kdf.NewKMACPRF(kdf.KMAC128, nil, 0))
+
+	prf, err = kdf.NewPRFByName("KMAC256")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, kdf.NewKMACPRF(kdf.KMAC256, nil, 0))
+}
+
+func (s *kmacSuite) TestAlgorithm(c *C) {
+	a, err := kdf.ParseAlgorithm("SP800-108-CTR-KMAC256/R32/L32")
+	c.Assert(err, IsNil)
+	c.Check(a.PRF, Equals, "KMAC256")
+}
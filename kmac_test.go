@@ -0,0 +1,139 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type kmacSuite struct{}
+
+var _ = Suite(&kmacSuite{})
+
+type testKMACData struct {
+	variant   KMACVariant
+	key       []byte
+	label     []byte
+	context   []byte
+	bitLength uint32
+	expected  []byte
+}
+
+func (s *kmacSuite) testKMACKey(c *C, data *testKMACData) {
+	c.Check(KMACKey(data.variant, data.key, data.label, data.context, data.bitLength), DeepEquals, data.expected)
+}
+
+// The following tests correspond to the KMAC samples published by NIST, where the
+// customization string is used as the label and the data as the context.
+
+func (s *kmacSuite) TestKMAC128Sample1(c *C) {
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC128,
+		key:       decodeHexString(c, "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f"),
+		context:   decodeHexString(c, "00010203"),
+		bitLength: 256,
+		expected:  decodeHexString(c, "e5780b0d3ea6f7d3a429c5706aa43a00fadbd7d49628839e3187243f456ee14e")})
+}
+
+func (s *kmacSuite) TestKMAC128Sample2(c *C) {
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC128,
+		key:       decodeHexString(c, "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f"),
+		label:     []byte("My Tagged Application"),
+		context:   decodeHexString(c, "00010203"),
+		bitLength: 256,
+		expected:  decodeHexString(c, "3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5")})
+}
+
+func (s *kmacSuite) TestKMAC256Sample6(c *C) {
+	context := make([]byte, 200)
+	for i := range context {
+		context[i] = byte(i)
+	}
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC256,
+		key:       decodeHexString(c, "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f"),
+		label:     []byte("My Tagged Application"),
+		context:   context,
+		bitLength: 512,
+		expected:  decodeHexString(c, "b58618f71f92e1d56c1b8c55ddd7cd188b97b4ca4d99831eb2699a837da2e4d970fbacfde50033aea585f1a2708510c32d07880801bd182898fe476876fc8965")})
+}
+
+// The following tests were generated with the KMAC implementation in OpenSSL.
+
+func (s *kmacSuite) TestKMAC128_128(c *C) {
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC128,
+		key:       decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		label:     []byte("label"),
+		context:   []byte("context"),
+		bitLength: 128,
+		expected:  decodeHexString(c, "0714e0a8db59b23babde4952dafd7185")})
+}
+
+func (s *kmacSuite) TestKMAC128_512(c *C) {
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC128,
+		key:       decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		label:     []byte("label"),
+		context:   []byte("context"),
+		bitLength: 512,
+		expected:  decodeHexString(c, "2330d46562bc05ef53ac07e9af06d056b491fe63a8ed1b3b9bd82d10d81a902c235bfbeb8ad9f4b9347459e38f3fc648c54da3f43fa6677d754df75a68520a1c")})
+}
+
+func (s *kmacSuite) TestKMAC256_256(c *C) {
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC256,
+		key:       decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		label:     []byte("label"),
+		context:   []byte("context"),
+		bitLength: 256,
+		expected:  decodeHexString(c, "1f9aeff65ea65f9c7681ffd1fc3acdf87b77beac5c15bd7787d2808f2f79d1e0")})
+}
+
+func (s *kmacSuite) TestKMAC256NoLabel(c *C) {
+	s.testKMACKey(c, &testKMACData{
+		variant:   KMAC256,
+		key:       decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		context:   []byte("context"),
+		bitLength: 256,
+		expected:  decodeHexString(c, "6bd2350b91d1aa143c6b6af3f2ffaafc5f0ac6f9d75204eb06284061d0d06553")})
+}
+
+func (s *kmacSuite) TestPRFDefaultSize(c *C) {
+	c.Check(NewKMACPRF(KMAC128, nil, 0).Len(), Equals, uint32(32))
+	c.Check(NewKMACPRF(KMAC256, nil, 0).Len(), Equals, uint32(64))
+	c.Check(NewKMACPRF(KMAC256, nil, 20).Len(), Equals, uint32(20))
+}
+
+func (s *kmacSuite) TestPRFRun(c *C) {
+	// KMAC128 sample #2
+	prf := NewKMACPRF(KMAC128, []byte("My Tagged Application"), 32)
+	c.Check(prf.Run(decodeHexString(c, "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f"), decodeHexString(c, "00010203")), DeepEquals,
+		decodeHexString(c, "3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5"))
+}
+
+func (s *kmacSuite) TestPRFInvalidVariant(c *C) {
+	c.Check(func() { NewKMACPRF(KMACVariant(5), nil, 32) }, PanicMatches, "invalid KMAC variant")
+}
+
+// The following tests were generated by computing each block with the KMAC
+// implementation in OpenSSL.
+
+func (s *kmacSuite) TestCounterModeKMAC128(c *C) {
+	prf := NewKMACPRF(KMAC128, []byte("cust"), 32)
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	c.Check(CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 384), DeepEquals,
+		decodeHexString(c, "18b6347fca79f13414ef76748187c93ac301790b429343d2de95c4b53193a6bdeaa850383b46626839d940ecdd67a924"))
+}
+
+func (s *kmacSuite) TestCounterModeKMAC256(c *C) {
+	prf := NewKMACPRF(KMAC256, nil, 0)
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	c.Check(CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 384), DeepEquals,
+		decodeHexString(c, "d417818b4d705a176ae2b326b147ee4dbb6bb400548b9e4d2d3159791ee4660f68a1b66aa6e998b5e39bfe3c38d589b4"))
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// LabelDeriver derives keys from a single PRF, key derivation key and label
+// using the counter mode function defined in NIST SP-800-108, with the iteration
+// counter located after the fixed input data. The input to the PRF for each
+// iteration is:
+//
+//	Label || 0x00 || Context || [L]2 || [i]2
+//
+// where [L]2 and [i]2 are big-endian 32-bit integers. This is the layout
+// produced by a LayoutAssembler with the CounterAfterFixed location.
+//
+// Because the data that varies between derivations and iterations is at the end
+// of the PRF input, the PRF state over the constant prefix is computed once and
+// cloned for every iteration, so that the key and label aren't processed again
+// for each derivation. This requires a PRF that implements PRFHasher and that
+// returns a hash.Hash that implements hash.Cloner, such as the HMAC based PRFs
+// with the digests in the standard library. Other PRFs produce the same keys,
+// but without the benefit of the cached state.
+//
+// A LabelDeriver is safe for concurrent use by multiple goroutines.
+type LabelDeriver struct {
+	prf   PRF
+	key   []byte // Only set when there is no cached state
+	label []byte
+	state hash.Cloner
+}
+
+// NewLabelDeriver creates a new LabelDeriver for the supplied PRF, key derivation
+// key and label.
+func NewLabelDeriver(prf PRF, key, label []byte) *LabelDeriver {
+	d := &LabelDeriver{prf: prf, label: append([]byte(nil), label...)}
+
+	if hasher, ok := prf.(PRFHasher); ok {
+		if h, ok := hasher.NewHash(key).(hash.Cloner); ok {
+			h.Write(d.label)
+			h.Write([]byte{0x00})
+			if _, err := h.Clone(); err == nil {
+				d.state = h
+				return d
+			}
+		}
+	}
+
+	d.key = append([]byte(nil), key...)
+	return d
+}
+
+// Derive derives a key of the specified length using the supplied context.
+func (d *LabelDeriver) Derive(context []byte, bitLength uint32) []byte {
+	if d.state == nil {
+		assembler := &LayoutAssembler{
+			FixedData:       fixedBytes(d.label, context, bitLength),
+			CounterLocation: CounterAfterFixed}
+		return CounterModeKeyWithAssembler(d.prf, d.key, assembler, bitLength)
+	}
+
+	var suffix [8]byte
+	binary.BigEndian.PutUint32(suffix[:4], bitLength)
+
+	k, _ := commonKDF(d.prf, bitLength, func(i uint32) ([]byte, error) {
+		h, err := d.state.Clone()
+		if err != nil {
+			panic(err)
+		}
+		binary.BigEndian.PutUint32(suffix[4:], i)
+		h.Write(context)
+		h.Write(suffix[:])
+		return h.Sum(nil), nil
+	})
+	return k
+}
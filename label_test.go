@@ -0,0 +1,103 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type labelSuite struct{}
+
+var _ = Suite(&labelSuite{})
+
+func (s *labelSuite) testDerive(c *C, prf PRF, key []byte) {
+	d := NewLabelDeriver(prf, key, []byte("foo"))
+	for _, t := range []struct {
+		context   string
+		bitLength uint32
+	}{
+		{context: "bar", bitLength: 256},
+		{context: "baz", bitLength: 1000},
+		{context: "", bitLength: 100},
+		{context: "a much longer context that spans more than a single block of the underlying digest", bitLength: 512},
+	} {
+		assembler := &LayoutAssembler{
+			FixedData:       FixedBytes([]byte("foo"), []byte(t.context), t.bitLength),
+			CounterLocation: CounterAfterFixed}
+		c.Check(d.Derive([]byte(t.context), t.bitLength), DeepEquals,
+			CounterModeKeyWithAssembler(prf, key, assembler, t.bitLength), Commentf("%q", t.context))
+	}
+}
+
+func (s *labelSuite) TestDeriveHMAC(c *C) {
+	s.testDerive(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+}
+
+func (s *labelSuite) TestDeriveHMACFromFunc(c *C) {
+	s.testDerive(c, NewHMACPRFFromFunc(sha256.New), decodeHexString(c, hmacTestKey))
+}
+
+func (s *labelSuite) TestDeriveHMACNotCloneable(c *C) {
+	s.testDerive(c, NewHMACPRFFromFunc(func() hash.Hash { return opaqueHash{sha256.New()} }), decodeHexString(c, hmacTestKey))
+}
+
+func (s *labelSuite) TestDeriveCMAC(c *C) {
+	s.testDerive(c, NewCMACAESPRF(16), decodeHexString(c, "000102030405060708090a0b0c0d0e0f"))
+}
+
+func (s *labelSuite) TestDeriveGeneric(c *C) {
+	s.testDerive(c, testPRF{NewHMACPRF(crypto.SHA256)}, decodeHexString(c, hmacTestKey))
+}
+
+func (s *labelSuite) TestCopiesKeyAndLabel(c *C) {
+	prf := NewCMACAESPRF(16)
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f")
+	label := []byte("foo")
+	d := NewLabelDeriver(testPRF{prf}, key, label)
+	expected := d.Derive([]byte("bar"), 256)
+
+	key[0] ^= 0xff
+	label[0] ^= 0xff
+	c.Check(d.Derive([]byte("bar"), 256), DeepEquals, expected)
+}
+
+func (s *labelSuite) TestConcurrent(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	d := NewLabelDeriver(prf, key, []byte("foo"))
+
+	const n = 16
+	results := make([][][]byte, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				results[i] = append(results[i], d.Derive([]byte(fmt.Sprintf("%d-%d", i, j)), 512))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		for j, k := range r {
+			context := []byte(fmt.Sprintf("%d-%d", i, j))
+			assembler := &LayoutAssembler{
+				FixedData:       FixedBytes([]byte("foo"), context, 512),
+				CounterLocation: CounterAfterFixed}
+			c.Check(k, DeepEquals, CounterModeKeyWithAssembler(prf, key, assembler, 512))
+		}
+	}
+}
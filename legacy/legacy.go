@@ -0,0 +1,74 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package legacy provides PRFs based on obsolete digest algorithms, for use with
+the key derivation functions in the parent package. These exist only so that keys
+which were originally derived by legacy systems can be re-derived in order to
+migrate data away from them, and they must not be used to derive new keys.
+
+These PRFs are not approved by NIST. Some of them, such as HMAC-MD5, are based on
+digest algorithms that are known to be broken. They are not registered with the
+parent package, so they can't be selected by name with NewPRFByName or PRFValue,
+and must be created explicitly with the constructors in this package.
+*/
+package legacy
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"fmt"
+	"hash"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+type hmacPRF struct {
+	name string
+	size int
+	new  func() hash.Hash
+}
+
+func (p *hmacPRF) Len() uint32 {
+	return uint32(p.size)
+}
+
+func (p *hmacPRF) String() string {
+	return p.name
+}
+
+func (p *hmacPRF) BlockSize() int {
+	return p.new().BlockSize()
+}
+
+func (p *hmacPRF) KeySize() int {
+	return p.size
+}
+
+func (p *hmacPRF) CheckKeyLength(n int) error {
+	if n < kdf.MinHMACKeyLength {
+		return fmt.Errorf("key length %d is shorter than the minimum of %d bytes", n, kdf.MinHMACKeyLength)
+	}
+	return nil
+}
+
+func (p *hmacPRF) Run(s, x []byte) []byte {
+	h := hmac.New(p.new, s)
+	h.Write(x)
+	return h.Sum(nil)
+}
+
+// NewHMACRIPEMD160PRF creates a new HMAC-RIPEMD160 based PRF.
+func NewHMACRIPEMD160PRF() kdf.PRF {
+	return &hmacPRF{name: "HMAC-RIPEMD-160", size: ripemd160.Size, new: ripemd160.New}
+}
+
+// NewHMACMD5PRF creates a new HMAC-MD5 based PRF. MD5 is broken, and this must
+// only be used to re-derive existing keys for decrypting data that is being
+// migrated.
+func NewHMACMD5PRF() kdf.PRF {
+	return &hmacPRF{name: "HMAC-MD5", size: md5.Size, new: md5.New}
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package legacy_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/legacy"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type legacySuite struct{}
+
+var _ = Suite(&legacySuite{})
+
+var legacyTestKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+// The following tests were generated with OpenSSL.
+
+func (s *legacySuite) TestHMACRIPEMD160(c *C) {
+	prf := NewHMACRIPEMD160PRF()
+	c.Check(prf.Len(), Equals, uint32(20))
+	c.Check(prf.Run(decodeHexString(c, legacyTestKey), []byte("abc")), DeepEquals, decodeHexString(c, "96f590513d039e85b6e18288b7f8358fd75f39d1"))
+}
+
+func (s *legacySuite) TestCounterModeHMACRIPEMD160(c *C) {
+	c.Check(kdf.CounterModeKey(NewHMACRIPEMD160PRF(), decodeHexString(c, legacyTestKey), []byte("label"), []byte("context"), 320), DeepEquals,
+		decodeHexString(c, "0c3d0c36ba5d6df42c2d75f391f7ce25235d8c38f03a69d8f7e1f295e0626ac9061089ef3587b105"))
+}
+
+func (s *legacySuite) TestHMACMD5(c *C) {
+	prf := NewHMACMD5PRF()
+	c.Check(prf.Len(), Equals, uint32(16))
+	c.Check(prf.Run(decodeHexString(c, legacyTestKey), []byte("abc")), DeepEquals, decodeHexString(c, "402b833eacaf1bff45d89bba5d52c9da"))
+}
+
+func (s *legacySuite) TestCounterModeHMACMD5(c *C) {
+	c.Check(kdf.CounterModeKey(NewHMACMD5PRF(), decodeHexString(c, legacyTestKey), []byte("label"), []byte("context"), 320), DeepEquals,
+		decodeHexString(c, "68f1104c6905bc14731c38c704ef6c8be2215493283a248ed484af85ed32dc63a3b96504375a85bc"))
+}
+
+func (s *legacySuite) TestString(c *C) {
+	c.Check(NewHMACRIPEMD160PRF().(interface{ String() string }).String(), Equals, "HMAC-RIPEMD-160")
+	c.Check(NewHMACMD5PRF().(interface{ String() string }).String(), Equals, "HMAC-MD5")
+}
+
+func (s *legacySuite) TestNotRegistered(c *C) {
+	for _, name := range []string{"HMAC-MD5", "HMAC-RIPEMD-160"} {
+		_, err := kdf.NewPRFByName(name)
+		c.Check(err, ErrorMatches, `unknown PRF "`+name+`"`)
+	}
+}
+
+func (s *legacySuite) TestKeyInfo(c *C) {
+	info := NewHMACRIPEMD160PRF().(kdf.PRFKeyInfo)
+	c.Check(info.BlockSize(), Equals, 64)
+	c.Check(info.KeySize(), Equals, 20)
+	c.Check(info.CheckKeyLength(8), ErrorMatches, "key length 8 is shorter than the minimum of 14 bytes")
+
+	info = NewHMACMD5PRF().(kdf.PRFKeyInfo)
+	c.Check(info.KeySize(), Equals, 16)
+	c.Check(info.CheckKeyLength(16), IsNil)
+}
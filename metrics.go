@@ -0,0 +1,46 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics is implemented by callers that want to collect metrics about key
+// derivations, eg, by forwarding them to an OpenTelemetry meter.
+type Metrics interface {
+	// RecordDerivation is called once for each completed derivation, with
+	// the name of the PRF, the number of PRF blocks that were computed and
+	// the time taken to complete the derivation.
+	RecordDerivation(prf string, blocks uint32, duration time.Duration)
+}
+
+type metricsHolder struct {
+	m Metrics
+}
+
+var metrics atomic.Value
+
+// SetMetrics sets the sink to which metrics are recorded for all subsequent key
+// derivations. Setting it to nil disables the collection of metrics, which is
+// the default.
+func SetMetrics(m Metrics) {
+	metrics.Store(metricsHolder{m})
+}
+
+func currentMetrics() Metrics {
+	h, _ := metrics.Load().(metricsHolder)
+	return h.m
+}
+
+// prfName returns a name that identifies the supplied PRF, for use in metrics.
+func prfName(prf PRF) string {
+	if s, ok := prf.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", prf)
+}
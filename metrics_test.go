@@ -0,0 +1,91 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"time"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type testMetricsRecord struct {
+	prf      string
+	blocks   uint32
+	duration time.Duration
+}
+
+type testMetrics struct {
+	records []testMetricsRecord
+}
+
+func (m *testMetrics) RecordDerivation(prf string, blocks uint32, duration time.Duration) {
+	m.records = append(m.records, testMetricsRecord{prf: prf, blocks: blocks, duration: duration})
+}
+
+type metricsSuite struct{}
+
+var _ = Suite(&metricsSuite{})
+
+func (s *metricsSuite) TearDownTest(c *C) {
+	SetMetrics(nil)
+}
+
+func (s *metricsSuite) TestRecord(c *C) {
+	m := new(testMetrics)
+	SetMetrics(m)
+
+	key := []byte("1234567890123456")
+	CounterModeKey(NewHMACPRF(crypto.SHA256), key, []byte("foo"), nil, 256)
+	FeedbackModeKey(NewHMACPRF(crypto.SHA1), key, []byte("foo"), nil, nil, 512, true)
+	PipelineModeKey(NewHMACPRF(crypto.SHA512), key, []byte("foo"), nil, 1032, false)
+
+	c.Assert(m.records, HasLen, 3)
+	c.Check(m.records[0].prf, Equals, "HMAC-SHA-256")
+	c.Check(m.records[0].blocks, Equals, uint32(1))
+	c.Check(m.records[1].prf, Equals, "HMAC-SHA-1")
+	c.Check(m.records[1].blocks, Equals, uint32(4))
+	c.Check(m.records[2].prf, Equals, "HMAC-SHA-512")
+	c.Check(m.records[2].blocks, Equals, uint32(3))
+	for _, r := range m.records {
+		c.Check(r.duration > 0, Equals, true)
+	}
+}
+
+func (s *metricsSuite) TestRecordKMAC(c *C) {
+	m := new(testMetrics)
+	SetMetrics(m)
+
+	KMACKey(KMAC256, []byte("1234567890123456"), []byte("foo"), nil, 256)
+
+	c.Assert(m.records, HasLen, 1)
+	c.Check(m.records[0].prf, Equals, "KMAC256")
+	c.Check(m.records[0].blocks, Equals, uint32(1))
+}
+
+type testPRF struct {
+	PRF
+}
+
+func (s *metricsSuite) TestRecordUnnamedPRF(c *C) {
+	m := new(testMetrics)
+	SetMetrics(m)
+
+	CounterModeKey(testPRF{NewHMACPRF(crypto.SHA256)}, []byte("1234567890123456"), []byte("foo"), nil, 256)
+
+	c.Assert(m.records, HasLen, 1)
+	c.Check(m.records[0].prf, Equals, "kdf_test.testPRF")
+}
+
+func (s *metricsSuite) TestUnset(c *C) {
+	m := new(testMetrics)
+	SetMetrics(m)
+	SetMetrics(nil)
+
+	CounterModeKey(NewHMACPRF(crypto.SHA256), []byte("1234567890123456"), []byte("foo"), nil, 256)
+	c.Check(m.records, HasLen, 0)
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// IterationMode implements the iteration scheme of a key derivation function,
+// which determines how the PRF is invoked for each block of output. The
+// iteration modes defined in NIST SP-800-108 are provided by Mode, and this can
+// be implemented to support non-standard schemes.
+type IterationMode interface {
+	// BlockFunc returns a function that computes the output of the PRF for
+	// each block of a single derivation, using the supplied PRF, secret key,
+	// assembler and IV. The returned function is called once for each block
+	// in order, with the one-based index of the block, and may retain state
+	// between calls.
+	BlockFunc(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte) func(i uint32) []byte
+}
+
+// Mode identifies one of the iteration modes defined in NIST SP-800-108. It
+// implements IterationMode.
+type Mode int
+
+const (
+	// CounterMode selects the counter mode function.
+	CounterMode Mode = iota
+
+	// FeedbackMode selects the feedback mode function.
+	FeedbackMode
+
+	// PipelineMode selects the double-pipeline iteration mode function.
+	PipelineMode
+)
+
+func (m Mode) String() string {
+	switch m {
+	case CounterMode:
+		return "counter"
+	case FeedbackMode:
+		return "feedback"
+	case PipelineMode:
+		return "pipeline"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// Set sets the mode from its name as returned from String, ignoring case. It
+// implements flag.Value, so that a mode can be selected with a command-line flag.
+func (m *Mode) Set(s string) error {
+	for _, mode := range []Mode{CounterMode, FeedbackMode, PipelineMode} {
+		if strings.EqualFold(s, mode.String()) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid mode %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Mode) MarshalText() ([]byte, error) {
+	switch m {
+	case CounterMode, FeedbackMode, PipelineMode:
+		return []byte(m.String()), nil
+	default:
+		return nil, fmt.Errorf("invalid mode %d", m)
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the same
+// names as Set.
+func (m *Mode) UnmarshalText(text []byte) error {
+	return m.Set(string(text))
+}
+
+func (m Mode) BlockFunc(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte) func(i uint32) []byte {
+	switch m {
+	case CounterMode:
+		return func(i uint32) []byte {
+			return prf.Run(key, assembler.Assemble(uint64(i), i-1, nil))
+		}
+	case FeedbackMode:
+		k := iv
+		return func(i uint32) []byte {
+			k = prf.Run(key, assembler.Assemble(uint64(i), i-1, k))
+			return k
+		}
+	case PipelineMode:
+		a := iv
+		return func(i uint32) []byte {
+			a = prf.Run(key, a)
+			return prf.Run(key, assembler.Assemble(uint64(i), i-1, a))
+		}
+	default:
+		panic("invalid mode")
+	}
+}
+
+// hashBlockFunc returns a function that computes the output of the PRF for each
+// block in the same way as the function returned from BlockFunc, but by writing
+// each segment of the input produced by the supplied assembler directly to the
+// supplied keyed hash rather than assembling the input in to a separate buffer
+// first. The hash must return to its keyed state when it is reset.
+func (m Mode) hashBlockFunc(h hash.Hash, assembler *LayoutAssembler, iv []byte) func(i uint32) []byte {
+	run := func(chain []byte, i uint32) []byte {
+		h.Reset()
+		assembler.writeInput(h, uint64(i), chain)
+		return h.Sum(nil)
+	}
+
+	switch m {
+	case CounterMode:
+		return func(i uint32) []byte {
+			return run(nil, i)
+		}
+	case FeedbackMode:
+		k := iv
+		return func(i uint32) []byte {
+			k = run(k, i)
+			return k
+		}
+	case PipelineMode:
+		a := iv
+		return func(i uint32) []byte {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(nil)
+			return run(a, i)
+		}
+	default:
+		panic("invalid mode")
+	}
+}
+
+// Derive derives a key using the iteration mode and other inputs described by
+// the supplied parameters. This is useful where the mode is selected from
+// configuration. An error is returned if the parameters are invalid.
+func Derive(params *Params) ([]byte, error) {
+	var sink SliceSink
+	if err := deriveToSink(params, &sink); err != nil {
+		return nil, err
+	}
+	return sink.Bytes(), nil
+}
+
+// DeriveInto derives a key in the same way as Derive, but writes it directly in
+// to the supplied buffer, which is useful for deriving keys in to locked or
+// otherwise specially managed memory. It returns the number of bytes written,
+// which is the length of the key rounded up to a whole number of bytes. An error
+// is returned if the parameters are invalid or the buffer is too small.
+func DeriveInto(dst []byte, params *Params) (int, error) {
+	if n := (uint64(params.BitLength) + 7) / 8; uint64(len(dst)) < n {
+		return 0, newError(ErrBufferTooSmall, "buffer of %d bytes is too small for a %d byte key", len(dst), n)
+	}
+	sink := &bufferSink{buf: dst}
+	if err := deriveToSink(params, sink); err != nil {
+		return 0, err
+	}
+	return sink.n, nil
+}
+
+func deriveToSink(params *Params, sink OutputSink) error {
+	return deriveToSinkWithCheck(params, sink, nil)
+}
+
+func deriveToSinkWithCheck(params *Params, sink OutputSink, check func() error) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+
+	key, _ := ConditionKey(params.PRF, params.Key, params.KeyConditioning)
+
+	assembler := params.assembler()
+	iv := params.IV
+	if params.Mode == PipelineMode {
+		iv = assembler.FixedData
+	}
+	return modeKeyToSink(params.Mode, params.PRF, key, assembler, iv, params.BitLength, sink, check)
+}
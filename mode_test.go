@@ -0,0 +1,215 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type modeSuite struct{}
+
+var _ = Suite(&modeSuite{})
+
+func (s *modeSuite) params(mode Mode) *Params {
+	return &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         mode,
+		Key:          []byte("1234567890123456"),
+		Label:        []byte("foo"),
+		Context:      []byte("bar"),
+		CounterWidth: 32,
+		BitLength:    600}
+}
+
+func (s *modeSuite) TestString(c *C) {
+	c.Check(CounterMode.String(), Equals, "counter")
+	c.Check(FeedbackMode.String(), Equals, "feedback")
+	c.Check(PipelineMode.String(), Equals, "pipeline")
+	c.Check(Mode(5).String(), Equals, "Mode(5)")
+	c.Check(fmt.Sprint(FeedbackMode), Equals, "feedback")
+}
+
+func (s *modeSuite) TestFlag(c *C) {
+	mode := CounterMode
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&mode, "mode", "")
+
+	c.Check(fs.Parse([]string{"-mode", "Pipeline"}), IsNil)
+	c.Check(mode, Equals, PipelineMode)
+}
+
+func (s *modeSuite) TestSetInvalid(c *C) {
+	mode := FeedbackMode
+	c.Check(mode.Set("foo"), ErrorMatches, `invalid mode "foo"`)
+	c.Check(mode, Equals, FeedbackMode)
+}
+
+func (s *modeSuite) TestTextMarshaling(c *C) {
+	var cfg struct {
+		Mode Mode
+	}
+	c.Check(json.Unmarshal([]byte(`{"Mode":"feedback"}`), &cfg), IsNil)
+	c.Check(cfg.Mode, Equals, FeedbackMode)
+
+	b, err := json.Marshal(&cfg)
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, `{"Mode":"feedback"}`)
+}
+
+func (s *modeSuite) TestMarshalTextInvalid(c *C) {
+	_, err := Mode(5).MarshalText()
+	c.Check(err, ErrorMatches, "invalid mode 5")
+}
+
+func (s *modeSuite) TestDeriveCounterMode(c *C) {
+	params := s.params(CounterMode)
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, params.Context, params.BitLength))
+}
+
+func (s *modeSuite) TestDeriveFeedbackMode(c *C) {
+	params := s.params(FeedbackMode)
+	params.IV = make([]byte, 32)
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, FeedbackModeKey(params.PRF, params.Key, params.Label, params.Context, params.IV, params.BitLength, true))
+}
+
+func (s *modeSuite) TestDeriveFeedbackModeNoCounter(c *C) {
+	params := s.params(FeedbackMode)
+	params.IV = make([]byte, 32)
+	params.CounterWidth = 0
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, FeedbackModeKey(params.PRF, params.Key, params.Label, params.Context, params.IV, params.BitLength, false))
+}
+
+func (s *modeSuite) TestDerivePipelineMode(c *C) {
+	params := s.params(PipelineMode)
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, PipelineModeKey(params.PRF, params.Key, params.Label, params.Context, params.BitLength, true))
+}
+
+func (s *modeSuite) TestDeriveLayout(c *C) {
+	params := s.params(CounterMode)
+	params.CounterWidth = 8
+	params.CounterLocation = CounterAfterFixed
+	params.LengthWidth = 16
+	params.LengthInBytes = true
+
+	fixed := (&FixedDataBuilder{Label: params.Label, Context: params.Context, LengthWidth: 16, LengthInBytes: true}).Build(params.BitLength)
+
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKeyWithAssembler(params.PRF, params.Key,
+		&LayoutAssembler{FixedData: fixed, CounterLocation: CounterAfterFixed, CounterWidth: 8}, params.BitLength))
+}
+
+func (s *modeSuite) TestDeriveInvalid(c *C) {
+	params := s.params(CounterMode)
+	params.CounterWidth = 0
+	_, err := Derive(params)
+	c.Check(err, ErrorMatches, "counter mode requires a counter")
+}
+
+// doubleCounterMode is a non-standard iteration mode that behaves like feedback
+// mode, but includes the iteration counter both before and after the iteration
+// variable.
+type doubleCounterMode struct{}
+
+func (doubleCounterMode) BlockFunc(prf PRF, key []byte, assembler FixedDataAssembler, iv []byte) func(i uint32) []byte {
+	k := iv
+	return func(i uint32) []byte {
+		x := append([]byte{byte(i)}, assembler.Assemble(uint64(i), i-1, k)...)
+		k = prf.Run(key, x)
+		return k
+	}
+}
+
+func (s *modeSuite) TestModeKeyCustom(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 400)
+	iv := make([]byte, 32)
+
+	k1 := prf.Run(key, append(append([]byte{1}, iv...), append([]byte{0, 0, 0, 1}, fixed...)...))
+	k2 := prf.Run(key, append(append([]byte{2}, k1...), append([]byte{0, 0, 0, 2}, fixed...)...))
+
+	c.Check(ModeKeyWithAssembler(doubleCounterMode{}, prf, key, NewStandardAssembler(fixed, true), iv, 400), DeepEquals, append(k1, k2[:18]...))
+}
+
+func (s *modeSuite) TestModeKeyBuiltin(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 600)
+	iv := make([]byte, 32)
+
+	c.Check(ModeKeyWithAssembler(CounterMode, prf, key, NewStandardAssembler(fixed, true), nil, 600), DeepEquals,
+		CounterModeKeyWithFixedData(prf, key, fixed, 600))
+	c.Check(ModeKeyWithAssembler(FeedbackMode, prf, key, NewStandardAssembler(fixed, true), iv, 600), DeepEquals,
+		FeedbackModeKeyWithFixedData(prf, key, fixed, iv, 600, true))
+	c.Check(ModeKeyWithAssembler(PipelineMode, prf, key, NewStandardAssembler(fixed, true), fixed, 600), DeepEquals,
+		PipelineModeKeyWithFixedData(prf, key, fixed, 600, true))
+}
+
+func (s *modeSuite) TestModeKeyInvalidMode(c *C) {
+	c.Check(func() {
+		ModeKeyWithAssembler(Mode(5), NewHMACPRF(crypto.SHA256), nil, NewStandardAssembler(nil, true), nil, 256)
+	}, PanicMatches, "invalid mode")
+}
+
+func (s *modeSuite) TestDeriveKeyConditioning(c *C) {
+	params := s.params(CounterMode)
+	params.PRF = NewCMACAESPRF(16)
+	params.Key = []byte("12345678901234567890")
+	params.KeyConditioning = TruncateKey
+
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(key, DeepEquals, CounterModeKey(params.PRF, params.Key[:16], params.Label, params.Context, params.BitLength))
+
+	params.KeyConditioning = RejectKey
+	_, err = Derive(params)
+	c.Check(err, ErrorMatches, `invalid key length 20 for CMAC-AES128 \(expected 16 bytes\)`)
+}
+
+func (s *modeSuite) TestDeriveInto(c *C) {
+	params := s.params(FeedbackMode)
+	params.IV = make([]byte, 32)
+
+	expected, err := Derive(params)
+	c.Assert(err, IsNil)
+
+	dst := make([]byte, 80)
+	for i := range dst {
+		dst[i] = 0xff
+	}
+	n, err := DeriveInto(dst, params)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 75)
+	c.Check(dst[:n], DeepEquals, expected)
+	c.Check(dst[n:], DeepEquals, []byte{0xff, 0xff, 0xff, 0xff, 0xff})
+}
+
+func (s *modeSuite) TestDeriveIntoTooSmall(c *C) {
+	_, err := DeriveInto(make([]byte, 74), s.params(CounterMode))
+	c.Check(err, ErrorMatches, "buffer of 74 bytes is too small for a 75 byte key")
+}
+
+func (s *modeSuite) TestDeriveIntoInvalid(c *C) {
+	params := s.params(CounterMode)
+	params.CounterWidth = 0
+	_, err := DeriveInto(make([]byte, 75), params)
+	c.Check(err, ErrorMatches, "counter mode requires a counter")
+}
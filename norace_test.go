@@ -0,0 +1,9 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build !race
+
+package kdf_test
+
+const raceEnabled = false
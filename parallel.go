@@ -0,0 +1,102 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// parallelThreshold is the output length in bytes above which a counter mode
+// derivation is split between multiple goroutines when the number of workers
+// is selected automatically. Below this, the cost of starting the goroutines
+// outweighs the benefit.
+const parallelThreshold = 64 * 1024
+
+// CounterModeKeyWithAssemblerParallel derives a key in the same way as
+// CounterModeKeyWithAssembler, but computes the blocks using the specified
+// number of goroutines. This is possible because each block in counter mode is
+// independent of the others, and is intended for deriving very long outputs
+// such as keystreams. The assembler must be safe for concurrent use, which is
+// the case for the assemblers provided by this package.
+//
+// If workers is zero or negative, the number of workers is selected
+// automatically: the derivation is split between runtime.GOMAXPROCS goroutines
+// if the output is at least 64KiB, else it is performed on the calling
+// goroutine. The number of workers is never more than the number of blocks.
+func CounterModeKeyWithAssemblerParallel(prf PRF, key []byte, assembler FixedDataAssembler, bitLength uint32, workers int) []byte {
+	n := numBlocks(prf.Len(), bitLength)
+	size := int((uint64(bitLength) + 7) / 8)
+
+	if workers <= 0 {
+		workers = 1
+		if size >= parallelThreshold {
+			workers = runtime.GOMAXPROCS(0)
+		}
+	}
+	if uint64(workers) > uint64(n) {
+		workers = int(n)
+	}
+	if workers <= 1 {
+		return CounterModeKeyWithAssembler(prf, key, assembler, bitLength)
+	}
+
+	if c, ok := assembler.(iterationChecker); ok {
+		if err := c.CheckIterations(n); err != nil {
+			panic(err)
+		}
+	}
+
+	m := currentMetrics()
+	var start time.Time
+	if m != nil {
+		start = time.Now()
+	}
+
+	out := make([]byte, size)
+	blockLen := int(prf.Len())
+
+	// Split the blocks in to a contiguous range for each worker, with the
+	// remainder spread across the first workers.
+	per := n / uint32(workers)
+	extra := n % uint32(workers)
+
+	var wg sync.WaitGroup
+	first := uint32(1)
+	for w := 0; w < workers; w++ {
+		count := per
+		if uint32(w) < extra {
+			count++
+		}
+		wg.Add(1)
+		go func(first, last uint32) {
+			defer wg.Done()
+			keyed := newKeyedPRF(prf, key)
+			for i := first; i <= last; i++ {
+				copy(out[int(i-1)*blockLen:], keyed.Run(assembler.Assemble(uint64(i), i-1, nil)))
+			}
+		}(first, first+count-1)
+		first += count
+	}
+	wg.Wait()
+
+	if bitLength%8 != 0 {
+		out[size-1] &= 0xff << (8 - bitLength%8)
+	}
+
+	if m != nil {
+		m.RecordDerivation(prfName(prf), n, time.Since(start))
+	}
+
+	return out
+}
+
+// CounterModeKeyParallel derives a key in the same way as CounterModeKey, but
+// computes the blocks using the specified number of goroutines as described for
+// CounterModeKeyWithAssemblerParallel.
+func CounterModeKeyParallel(prf PRF, key, label, context []byte, bitLength uint32, workers int) []byte {
+	return CounterModeKeyWithAssemblerParallel(prf, key, NewStandardAssembler(fixedBytes(label, context, bitLength), true), bitLength, workers)
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type parallelSuite struct{}
+
+var _ = Suite(&parallelSuite{})
+
+func (s *parallelSuite) testParallel(c *C, prf PRF, key []byte) {
+	for _, bitLength := range []uint32{0, 100, 256, 1000, 8 * 4096, 8*100000 + 3} {
+		expected := CounterModeKey(prf, key, []byte("foo"), []byte("bar"), bitLength)
+		for _, workers := range []int{-1, 0, 1, 2, 3, 8, 1000} {
+			c.Check(CounterModeKeyParallel(prf, key, []byte("foo"), []byte("bar"), bitLength, workers), DeepEquals, expected,
+				Commentf("bitLength: %d, workers: %d", bitLength, workers))
+		}
+	}
+}
+
+func (s *parallelSuite) TestParallelHMAC(c *C) {
+	s.testParallel(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+}
+
+func (s *parallelSuite) TestParallelCMAC(c *C) {
+	s.testParallel(c, NewCMACAESPRF(16), decodeHexString(c, "000102030405060708090a0b0c0d0e0f"))
+}
+
+func (s *parallelSuite) TestParallelGeneric(c *C) {
+	s.testParallel(c, testPRF{NewHMACPRF(crypto.SHA256)}, decodeHexString(c, hmacTestKey))
+}
+
+func (s *parallelSuite) TestParallelWithAssembler(c *C) {
+	prf := NewHMACPRF(crypto.SHA512)
+	key := decodeHexString(c, hmacTestKey)
+	assembler := &LayoutAssembler{
+		FixedData:       []byte("fixed"),
+		CounterLocation: CounterAfterFixed,
+		CounterWidth:    16}
+	c.Check(CounterModeKeyWithAssemblerParallel(prf, key, assembler, 8*5000, 4), DeepEquals,
+		CounterModeKeyWithAssembler(prf, key, assembler, 8*5000))
+}
+
+func (s *parallelSuite) TestParallelTooManyIterations(c *C) {
+	assembler := &LayoutAssembler{FixedData: []byte("fixed"), CounterWidth: 8}
+	c.Check(func() {
+		CounterModeKeyWithAssemblerParallel(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), assembler, 256*256, 4)
+	}, PanicMatches, `256 iterations are required but the iteration counter only permits 255`)
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Params describes the inputs to a key derivation.
+type Params struct {
+	// PRF is the pseudorandom function used to derive the key.
+	PRF PRF
+
+	// Mode is the iteration mode. This is ignored by AutoMode, which
+	// selects the mode from the other parameters.
+	Mode Mode
+
+	// Key is the secret key from which the new key is derived.
+	Key []byte
+
+	// KeyConditioning determines how a secret key that is not acceptable
+	// for the PRF is handled by Derive. By default, it is rejected.
+	KeyConditioning KeyConditioning
+
+	Label   []byte
+	Context []byte
+
+	// Separator is the byte that separates the label and context in the
+	// fixed input data, which is 0x00 by default.
+	Separator byte
+
+	// IV is the initialization value used in feedback mode. Its length must
+	// match the output length of the PRF unless AllowAnyIVLength is set.
+	IV []byte
+
+	// AllowAnyIVLength permits an IV of any length to be used in feedback
+	// mode, including an empty IV. This is required for interoperability
+	// with some implementations, but should otherwise not be set.
+	AllowAnyIVLength bool
+
+	// CounterWidth is the length of the iteration counter in bits, which
+	// must be 8, 16, 24, 32 or 64. Zero indicates that no counter is used,
+	// which is only permitted in feedback and double-pipeline mode.
+	CounterWidth int
+
+	// CounterLocation is the location of the iteration counter. The
+	// CounterMiddleFixed location is not supported.
+	CounterLocation CounterLocation
+
+	// CounterLittleEndian indicates that the iteration counter should be
+	// encoded as a little-endian integer, as required by some protocols.
+	CounterLittleEndian bool
+
+	// CounterWrap permits outputs that require more iterations than the
+	// counter can represent by wrapping the counter, as described for
+	// LayoutAssembler. This is insecure and must never be used for key
+	// derivation.
+	CounterWrap bool
+
+	// LengthWidth is the length of the encoded length field in bits, which
+	// must be 8, 16, 24, 32 or 64. Zero selects a 32-bit field.
+	LengthWidth int
+
+	// LengthLittleEndian indicates that the length field should be encoded
+	// as a little-endian integer.
+	LengthLittleEndian bool
+
+	// LengthInBytes indicates that the length field should contain the
+	// length of the derived key in bytes rather than bits.
+	LengthInBytes bool
+
+	// LengthLocation is the location of the length field in the fixed
+	// input data.
+	LengthLocation LengthLocation
+
+	// BitLength is the length of the derived key in bits.
+	BitLength uint32
+}
+
+// Validate checks that these parameters describe a valid key derivation,
+// returning an error if they don't.
+func (p *Params) Validate() error {
+	if p.PRF == nil {
+		return ErrNoPRF
+	}
+	if _, err := ConditionKey(p.PRF, p.Key, p.KeyConditioning); err != nil {
+		return err
+	}
+
+	switch p.Mode {
+	case CounterMode:
+		if p.CounterWidth == 0 {
+			return errors.New("counter mode requires a counter")
+		}
+		if p.IV != nil {
+			return errors.New("an IV is only used in feedback mode")
+		}
+	case FeedbackMode:
+		if !p.AllowAnyIVLength && len(p.IV) != int(p.PRF.Len()) {
+			return newError(ErrBadIVLength, "IV length %d does not match the PRF output length %d", len(p.IV), p.PRF.Len())
+		}
+	case PipelineMode:
+		if p.IV != nil {
+			return errors.New("an IV is only used in feedback mode")
+		}
+	default:
+		return newError(ErrInvalidMode, "invalid mode %d", p.Mode)
+	}
+
+	if p.CounterWidth != 0 && !validCounterWidth(p.CounterWidth) {
+		return newError(ErrUnsupportedCounterWidth, "unsupported counter width %d", p.CounterWidth)
+	}
+	switch p.CounterLocation {
+	case CounterBeforeFixed, CounterAfterFixed, CounterBeforeIter:
+	default:
+		return fmt.Errorf("unsupported counter location %d", p.CounterLocation)
+	}
+	if p.CounterWidth != 0 && !p.CounterWrap {
+		if err := checkIterations(p.PRF, p.BitLength, p.CounterWidth); err != nil {
+			return err
+		}
+	}
+
+	switch p.LengthLocation {
+	case LengthAfterContext, LengthBeforeLabel:
+		width := p.LengthWidth
+		if width == 0 {
+			width = 32
+		}
+		if !validCounterWidth(width) {
+			return newError(ErrUnsupportedLengthWidth, "unsupported length width %d", width)
+		}
+		if l := p.fixedDataBuilder().lengthValue(p.BitLength); width < 64 && l >= 1<<width {
+			return newError(ErrOutputTooLong, "length %d overflows the %d-bit length field", l, width)
+		}
+	case LengthOmitted:
+	default:
+		return fmt.Errorf("invalid length location %d", p.LengthLocation)
+	}
+
+	return nil
+}
+
+// MaxBitLength returns the maximum length in bits of a key that can be derived
+// with these parameters, which is limited by the width of the iteration counter
+// (unless CounterWrap is set) and the width of the length field. The BitLength
+// field is ignored. An error is returned if the PRF is not set or the counter or
+// length field widths are invalid.
+func (p *Params) MaxBitLength() (uint32, error) {
+	if p.PRF == nil {
+		return 0, ErrNoPRF
+	}
+
+	max := uint64(math.MaxUint32)
+
+	if p.CounterWidth != 0 {
+		if !validCounterWidth(p.CounterWidth) {
+			return 0, newError(ErrUnsupportedCounterWidth, "unsupported counter width %d", p.CounterWidth)
+		}
+		if p.CounterWidth < 64 && !p.CounterWrap {
+			if n := (uint64(1)<<p.CounterWidth - 1) * uint64(p.PRF.Len()) * 8; n < max {
+				max = n
+			}
+		}
+	}
+
+	if p.LengthLocation != LengthOmitted {
+		width := p.LengthWidth
+		if width == 0 {
+			width = 32
+		}
+		if !validCounterWidth(width) {
+			return 0, newError(ErrUnsupportedLengthWidth, "unsupported length width %d", width)
+		}
+		if width < 64 {
+			n := uint64(1)<<width - 1
+			if p.LengthInBytes {
+				n *= 8
+			}
+			if n < max {
+				max = n
+			}
+		}
+	}
+
+	return uint32(max), nil
+}
+
+// checkIterations returns an error if a derivation of the specified length
+// requires more than the 2^r-1 iterations permitted by NIST SP-800-108 for a
+// counter that is r bits wide.
+func checkIterations(prf PRF, bitLength uint32, width int) error {
+	if width < 64 && uint64(numBlocks(prf.Len(), bitLength)) >= 1<<width {
+		return newError(ErrOutputTooLong, "bit length %d requires too many iterations for the %d-bit counter", bitLength, width)
+	}
+	return nil
+}
+
+func (p *Params) fixedDataBuilder() *FixedDataBuilder {
+	return &FixedDataBuilder{
+		Label:              p.Label,
+		Context:            p.Context,
+		Separator:          p.Separator,
+		LengthWidth:        p.LengthWidth,
+		LengthLittleEndian: p.LengthLittleEndian,
+		LengthInBytes:      p.LengthInBytes,
+		LengthLocation:     p.LengthLocation}
+}
+
+// assembler returns the FixedDataAssembler for these parameters, which must
+// have been validated.
+func (p *Params) assembler() *LayoutAssembler {
+	return &LayoutAssembler{
+		FixedData:           p.fixedDataBuilder().Build(p.BitLength),
+		OmitCounter:         p.CounterWidth == 0,
+		CounterLocation:     p.CounterLocation,
+		CounterWidth:        p.CounterWidth,
+		CounterLittleEndian: p.CounterLittleEndian,
+		CounterWrap:         p.CounterWrap}
+}
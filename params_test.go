@@ -0,0 +1,168 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type paramsSuite struct{}
+
+var _ = Suite(&paramsSuite{})
+
+func (s *paramsSuite) TestValidateCounterMode(c *C) {
+	c.Check(testParams().Validate(), IsNil)
+}
+
+func (s *paramsSuite) TestValidateFeedbackMode(c *C) {
+	params := testParams()
+	params.Mode = FeedbackMode
+	params.IV = make([]byte, 32)
+	c.Check(params.Validate(), IsNil)
+
+	params.CounterWidth = 0
+	c.Check(params.Validate(), IsNil)
+}
+
+func (s *paramsSuite) TestValidatePipelineMode(c *C) {
+	params := testParams()
+	params.Mode = PipelineMode
+	params.CounterLocation = CounterAfterFixed
+	c.Check(params.Validate(), IsNil)
+}
+
+func (s *paramsSuite) TestValidateLengthEncoding(c *C) {
+	params := testParams()
+	params.LengthWidth = 8
+	params.LengthInBytes = true
+	params.LengthLocation = LengthBeforeLabel
+	c.Check(params.Validate(), IsNil)
+}
+
+func (s *paramsSuite) TestDeriveEncoding(c *C) {
+	params := testParams()
+	params.Separator = '|'
+	params.CounterWidth = 16
+	params.CounterLittleEndian = true
+	params.LengthWidth = 16
+	params.LengthLittleEndian = true
+
+	key, err := Derive(params)
+	c.Check(err, IsNil)
+
+	fixed := (&FixedDataBuilder{Label: params.Label, Context: params.Context, Separator: '|', LengthWidth: 16, LengthLittleEndian: true}).Build(params.BitLength)
+	c.Check(key, DeepEquals, CounterModeKeyWithAssembler(params.PRF, params.Key,
+		&LayoutAssembler{FixedData: fixed, CounterWidth: 16, CounterLittleEndian: true}, params.BitLength))
+}
+
+func (s *paramsSuite) TestValidateErrors(c *C) {
+	for _, t := range []struct {
+		modify func(*Params)
+		err    string
+	}{
+		{modify: func(p *Params) { p.PRF = nil }, err: "no PRF supplied"},
+		{modify: func(p *Params) { p.Mode = Mode(3) }, err: "invalid mode 3"},
+		{modify: func(p *Params) { p.Key = []byte("foo") }, err: "key length 3 is shorter than the minimum of 14 bytes"},
+		{modify: func(p *Params) { p.PRF = NewCMACAESPRF(32) }, err: `invalid key length 16 for CMAC-AES256 \(expected 32 bytes\)`},
+		{modify: func(p *Params) { p.CounterWidth = 0 }, err: "counter mode requires a counter"},
+		{modify: func(p *Params) { p.IV = make([]byte, 32) }, err: "an IV is only used in feedback mode"},
+		{modify: func(p *Params) { p.Mode = PipelineMode; p.IV = make([]byte, 32) }, err: "an IV is only used in feedback mode"},
+		{modify: func(p *Params) { p.Mode = FeedbackMode }, err: "IV length 0 does not match the PRF output length 32"},
+		{modify: func(p *Params) { p.Mode = FeedbackMode; p.IV = make([]byte, 16) }, err: "IV length 16 does not match the PRF output length 32"},
+		{modify: func(p *Params) { p.CounterWidth = 12 }, err: "unsupported counter width 12"},
+		{modify: func(p *Params) { p.CounterLocation = CounterMiddleFixed }, err: "unsupported counter location 3"},
+		{modify: func(p *Params) { p.CounterWidth = 8; p.BitLength = 65536 }, err: "bit length 65536 requires too many iterations for the 8-bit counter"},
+		{modify: func(p *Params) { p.LengthWidth = 12 }, err: "unsupported length width 12"},
+		{modify: func(p *Params) { p.LengthWidth = 8 }, err: "length 256 overflows the 8-bit length field"},
+		{modify: func(p *Params) { p.LengthLocation = LengthLocation(5) }, err: "invalid length location 5"},
+	} {
+		params := testParams()
+		t.modify(params)
+		c.Check(params.Validate(), ErrorMatches, t.err)
+	}
+}
+
+func (s *paramsSuite) TestValidateLengthOmitted(c *C) {
+	params := testParams()
+	params.LengthWidth = 8
+	params.LengthLocation = LengthOmitted
+	c.Check(params.Validate(), IsNil)
+}
+
+func (s *paramsSuite) TestValidateAllowAnyIVLength(c *C) {
+	params := testParams()
+	params.Mode = FeedbackMode
+	params.AllowAnyIVLength = true
+	c.Check(params.Validate(), IsNil)
+
+	params.IV = make([]byte, 16)
+	c.Check(params.Validate(), IsNil)
+}
+
+func (s *paramsSuite) TestMaxBitLength(c *C) {
+	for _, t := range []struct {
+		modify func(*Params)
+		max    uint32
+	}{
+		{modify: func(p *Params) {}, max: 0xffffffff},
+		{modify: func(p *Params) { p.CounterWidth = 8 }, max: 255 * 32 * 8},
+		{modify: func(p *Params) { p.CounterWidth = 16 }, max: 65535 * 32 * 8},
+		{modify: func(p *Params) { p.CounterWidth = 16; p.LengthWidth = 16 }, max: 65535},
+		{modify: func(p *Params) { p.CounterWidth = 16; p.LengthWidth = 16; p.LengthInBytes = true }, max: 65535 * 8},
+		{modify: func(p *Params) { p.CounterWidth = 8; p.LengthWidth = 8; p.LengthLocation = LengthOmitted }, max: 255 * 32 * 8},
+		{modify: func(p *Params) { p.CounterWidth = 8; p.CounterWrap = true }, max: 0xffffffff},
+		{modify: func(p *Params) { p.Mode = FeedbackMode; p.CounterWidth = 0; p.LengthWidth = 64 }, max: 0xffffffff},
+	} {
+		params := testParams()
+		t.modify(params)
+		max, err := params.MaxBitLength()
+		c.Check(err, IsNil)
+		c.Check(max, Equals, t.max)
+
+		if params.Mode == CounterMode && max < 0xffffffff {
+			params.BitLength = max
+			c.Check(params.Validate(), IsNil)
+			params.BitLength = max + 1
+			c.Check(params.Validate(), NotNil)
+		}
+	}
+}
+
+func (s *paramsSuite) TestCounterWrap(c *C) {
+	params := testParams()
+	params.CounterWidth = 8
+	params.BitLength = 300 * 32 * 8
+
+	_, err := Derive(params)
+	c.Check(err, ErrorMatches, "bit length 76800 requires too many iterations for the 8-bit counter")
+
+	params.CounterWrap = true
+	key, err := Derive(params)
+	c.Assert(err, IsNil)
+	c.Check(key, HasLen, 300*32)
+
+	// The counter wraps from 255 back to 1, so block 256 repeats block 1.
+	c.Check(key[255*32:], DeepEquals, key[:45*32])
+	c.Check(key[:32], Not(DeepEquals), key[32:64])
+}
+
+func (s *paramsSuite) TestMaxBitLengthErrors(c *C) {
+	params := testParams()
+	params.PRF = nil
+	_, err := params.MaxBitLength()
+	c.Check(err, ErrorMatches, "no PRF supplied")
+
+	params = testParams()
+	params.CounterWidth = 12
+	_, err = params.MaxBitLength()
+	c.Check(err, ErrorMatches, "unsupported counter width 12")
+
+	params = testParams()
+	params.LengthWidth = 12
+	_, err = params.MaxBitLength()
+	c.Check(err, ErrorMatches, "unsupported length width 12")
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/subtle"
+	"sync"
+)
+
+// Job describes a single counter mode key derivation to be performed by a
+// DeriverPool.
+type Job struct {
+	Key       []byte // The secret key
+	FixedData []byte // The fixed input data
+	BitLength uint32 // The length of the derived key in bits
+}
+
+type poolRequest struct {
+	job    *Job
+	result chan<- []byte
+}
+
+// DeriverPool is a long-lived pool of worker goroutines that derive keys in
+// counter mode using a single PRF. It bounds the number of concurrent derivations
+// and reuses the same workers across many requests, which makes it suitable for
+// servers. It is safe to use from multiple goroutines.
+//
+// Each worker retains the keyed PRF state and scratch buffers from its previous
+// job, so that the PRF key setup is only repeated when a job uses a different
+// key to the previous job handled by the same worker.
+type DeriverPool struct {
+	prf      PRF
+	requests chan poolRequest
+	wg       sync.WaitGroup
+}
+
+// NewDeriverPool creates a new pool with the specified number of workers, which
+// derive keys using the supplied PRF. The number of workers must be at least 1.
+// The pool must be closed with Close when it is no longer required.
+func NewDeriverPool(prf PRF, workers int) *DeriverPool {
+	if workers < 1 {
+		panic("invalid number of workers")
+	}
+
+	p := &DeriverPool{
+		prf:      prf,
+		requests: make(chan poolRequest)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *DeriverPool) run() {
+	defer p.wg.Done()
+
+	var (
+		key []byte
+		s   kdfState
+	)
+	for req := range p.requests {
+		job := req.job
+		if job.BitLength == 0 {
+			req.result <- nil
+			continue
+		}
+		if s.keyed == nil || subtle.ConstantTimeCompare(job.Key, key) != 1 {
+			clear(key)
+			key = append([]byte(nil), job.Key...)
+			s.keyed = newKeyedPRF(p.prf, key)
+		}
+
+		out := make([]byte, (uint64(job.BitLength)+7)/8)
+		s.deriveWithFixedData(p.prf, out, job.FixedData, job.BitLength)
+		req.result <- out
+	}
+	clear(key)
+}
+
+// Submit queues the supplied job and returns a channel on which the derived key
+// will be delivered once a worker has processed it. Submit blocks until a worker
+// is available to accept the job. It must not be called after Close.
+func (p *DeriverPool) Submit(job *Job) <-chan []byte {
+	result := make(chan []byte, 1)
+	p.requests <- poolRequest{job: job, result: result}
+	return result
+}
+
+// Derive submits the supplied job and waits for the derived key.
+func (p *DeriverPool) Derive(job *Job) []byte {
+	return <-p.Submit(job)
+}
+
+// Close stops the workers in this pool, after waiting for any jobs that have
+// already been accepted to complete.
+func (p *DeriverPool) Close() {
+	close(p.requests)
+	p.wg.Wait()
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"encoding/binary"
+	"sync"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type poolSuite struct{}
+
+var _ = Suite(&poolSuite{})
+
+func (s *poolSuite) job(i int) *Job {
+	key := make([]byte, 32)
+	binary.BigEndian.PutUint64(key, uint64(i))
+	return &Job{
+		Key:       key,
+		FixedData: FixedBytes([]byte("foo"), key[:8], uint32(128+(i%4)*64)),
+		BitLength: uint32(128 + (i%4)*64)}
+}
+
+func (s *poolSuite) TestDerive(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	pool := NewDeriverPool(prf, 2)
+	defer pool.Close()
+
+	job := s.job(0)
+	c.Check(pool.Derive(job), DeepEquals, CounterModeKeyWithFixedData(prf, job.Key, job.FixedData, job.BitLength))
+}
+
+func (s *poolSuite) TestManyJobs(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	pool := NewDeriverPool(prf, 4)
+	defer pool.Close()
+
+	const numSubmitters = 8
+	const numJobs = 100
+
+	results := make([][]byte, numSubmitters*numJobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numSubmitters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var chans []<-chan []byte
+			for j := 0; j < numJobs; j++ {
+				chans = append(chans, pool.Submit(s.job(i*numJobs+j)))
+			}
+			for j, ch := range chans {
+				results[i*numJobs+j] = <-ch
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		job := s.job(i)
+		c.Check(result, DeepEquals, CounterModeKeyWithFixedData(prf, job.Key, job.FixedData, job.BitLength))
+	}
+}
+
+func (s *poolSuite) TestCloseWaitsForAcceptedJobs(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	pool := NewDeriverPool(prf, 1)
+
+	job := s.job(1)
+	ch := pool.Submit(job)
+	pool.Close()
+
+	c.Check(<-ch, DeepEquals, CounterModeKeyWithFixedData(prf, job.Key, job.FixedData, job.BitLength))
+}
+
+func (s *poolSuite) TestSameKey(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	pool := NewDeriverPool(prf, 1)
+	defer pool.Close()
+
+	// Consecutive jobs with the same key reuse the keyed PRF state, which
+	// must be discarded when the key changes, including when the caller
+	// modifies the same key buffer in place.
+	key := make([]byte, 32)
+	for i := 0; i < 8; i++ {
+		key[0] = byte(i / 3)
+		job := &Job{Key: key, FixedData: FixedBytes([]byte("foo"), []byte{byte(i)}, 384), BitLength: 384}
+		c.Check(pool.Derive(job), DeepEquals, CounterModeKeyWithFixedData(prf, job.Key, job.FixedData, job.BitLength))
+	}
+}
+
+func (s *poolSuite) TestGenericPRF(c *C) {
+	prf := testPRF{NewHMACPRF(crypto.SHA256)}
+	pool := NewDeriverPool(prf, 1)
+	defer pool.Close()
+
+	for i := 0; i < 4; i++ {
+		job := s.job(i / 2)
+		c.Check(pool.Derive(job), DeepEquals, CounterModeKeyWithFixedData(prf, job.Key, job.FixedData, job.BitLength))
+	}
+}
+
+func (s *poolSuite) TestZeroLength(c *C) {
+	pool := NewDeriverPool(NewHMACPRF(crypto.SHA256), 1)
+	defer pool.Close()
+
+	c.Check(pool.Derive(&Job{Key: make([]byte, 32), BitLength: 0}), IsNil)
+}
@@ -0,0 +1,11 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build race
+
+package kdf_test
+
+// raceEnabled indicates that the race detector is enabled, which randomly
+// discards items from a sync.Pool and so makes allocation counts unreliable.
+const raceEnabled = true
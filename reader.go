@@ -0,0 +1,213 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Reader is an io.Reader that produces the output of a key derivation on demand,
+// allowing an arbitrary amount of key material to be consumed without computing
+// all of it up front. Each call to Read continues from where the previous call
+// finished.
+//
+// Because the total length isn't known in advance, the fixed input data used by
+// the assembler must not depend on it, or must encode the maximum length that
+// will be read.
+//
+// The position of a Reader can be saved with MarshalBinary and restored with
+// UnmarshalBinary, so that a long derivation can be resumed later. The saved
+// state contains derived key material and must be protected accordingly.
+type Reader struct {
+	mode      IterationMode
+	prf       PRF
+	key       []byte
+	assembler FixedDataAssembler
+	iv        []byte
+
+	fn    func(uint32) []byte
+	i     uint32 // the number of blocks computed
+	block []byte // the most recently computed block
+	pos   int    // the number of bytes of block that have been read
+}
+
+// NewReader returns a new Reader that produces the output of a key derivation
+// using the supplied iteration mode, PRF and secret key, with the input to the
+// PRF for each iteration produced by the supplied assembler. The iv argument is
+// passed to the mode, in the same way as for ModeKeyWithAssembler.
+func NewReader(mode IterationMode, prf PRF, key []byte, assembler FixedDataAssembler, iv []byte) *Reader {
+	return &Reader{
+		mode:      mode,
+		prf:       prf,
+		key:       key,
+		assembler: assembler,
+		iv:        iv,
+		fn:        mode.BlockFunc(prf, key, assembler, iv)}
+}
+
+func (r *Reader) next() error {
+	if r.i == math.MaxUint32 {
+		return newError(ErrOutputTooLong, "maximum number of iterations reached")
+	}
+	if c, ok := r.assembler.(iterationChecker); ok {
+		if err := c.CheckIterations(r.i + 1); err != nil {
+			return err
+		}
+	}
+	r.i++
+	r.block = r.fn(r.i)
+	r.pos = 0
+	return nil
+}
+
+// Read reads the next len(p) bytes of derived key material in to p. An error is
+// returned if the iteration counter cannot represent the next block, in which
+// case no more key material can be produced.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if r.pos == len(r.block) {
+			if err := r.next(); err != nil {
+				return n, err
+			}
+		}
+		c := copy(p, r.block[r.pos:])
+		r.pos += c
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+const readerStateMagic = "kdf\x01"
+
+// MarshalBinary saves the position of this reader.
+func (r *Reader) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(readerStateMagic)+12+len(r.block))
+	b = append(b, readerStateMagic...)
+	b = binary.BigEndian.AppendUint32(b, r.i)
+	b = binary.BigEndian.AppendUint32(b, uint32(r.pos))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(r.block)))
+	return append(b, r.block...), nil
+}
+
+// UnmarshalBinary restores the position of this reader from a state saved
+// with MarshalBinary. The reader must have been created with the same
+// arguments as the reader from which the state was saved.
+//
+// The state of counter mode and feedback mode derivations is restored
+// immediately. Restoring the state of other modes requires the PRF invocations
+// that have already been performed to be repeated.
+func (r *Reader) UnmarshalBinary(data []byte) error {
+	if len(data) < len(readerStateMagic) || string(data[:len(readerStateMagic)]) != readerStateMagic {
+		return errors.New("invalid reader state identifier")
+	}
+	data = data[len(readerStateMagic):]
+	if len(data) < 12 {
+		return errors.New("invalid reader state size")
+	}
+	i := binary.BigEndian.Uint32(data)
+	pos := binary.BigEndian.Uint32(data[4:])
+	n := binary.BigEndian.Uint32(data[8:])
+	data = data[12:]
+	if uint64(n) != uint64(len(data)) || pos > n || (i == 0 && n != 0) {
+		return errors.New("invalid reader state")
+	}
+	block := append([]byte(nil), data...)
+
+	var fn func(uint32) []byte
+	switch r.mode {
+	case CounterMode:
+		fn = r.mode.BlockFunc(r.prf, r.key, r.assembler, r.iv)
+	case FeedbackMode:
+		chain := r.iv
+		if i > 0 {
+			chain = block
+		}
+		fn = r.mode.BlockFunc(r.prf, r.key, r.assembler, chain)
+	case PipelineMode:
+		a := r.iv
+		for j := uint32(0); j < i; j++ {
+			a = r.prf.Run(r.key, a)
+		}
+		fn = r.mode.BlockFunc(r.prf, r.key, r.assembler, a)
+	default:
+		fn = r.mode.BlockFunc(r.prf, r.key, r.assembler, r.iv)
+		for j := uint32(1); j <= i; j++ {
+			fn(j)
+		}
+	}
+
+	r.fn = fn
+	r.i = i
+	r.block = block
+	r.pos = int(pos)
+	return nil
+}
+
+// CounterModeReaderAt provides random access to the output of the counter mode
+// function defined in NIST SP-800-108. Because each block of counter mode output
+// is independent of the others, any part of the output can be computed without
+// computing the preceding blocks. It implements io.ReaderAt.
+//
+// As with Reader, the fixed input data used by the assembler must not depend on
+// the total length of the output, or must encode the maximum length that will be
+// read.
+type CounterModeReaderAt struct {
+	prf       PRF
+	key       []byte
+	assembler FixedDataAssembler
+}
+
+// NewCounterModeReaderAt returns a new CounterModeReaderAt for the supplied PRF
+// and secret key, with the input to the PRF for each block produced by the
+// supplied assembler.
+func NewCounterModeReaderAt(prf PRF, key []byte, assembler FixedDataAssembler) *CounterModeReaderAt {
+	return &CounterModeReaderAt{prf: prf, key: key, assembler: assembler}
+}
+
+// Block returns the block of output with the specified zero-based index. An
+// error is returned if the iteration counter cannot represent the block.
+func (r *CounterModeReaderAt) Block(index uint32) ([]byte, error) {
+	if index == math.MaxUint32 {
+		return nil, newError(ErrOutputTooLong, "maximum number of iterations reached")
+	}
+	if c, ok := r.assembler.(iterationChecker); ok {
+		if err := c.CheckIterations(index + 1); err != nil {
+			return nil, err
+		}
+	}
+	return r.prf.Run(r.key, r.assembler.Assemble(uint64(index)+1, index, nil)), nil
+}
+
+// ReadAt reads len(p) bytes of output starting at the specified byte offset in
+// to p. An error is returned if the output at the requested offset cannot be
+// produced.
+func (r *CounterModeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	blockLen := int64(r.prf.Len())
+	index := off / blockLen
+	skip := off % blockLen
+
+	for len(p) > 0 {
+		if index > math.MaxUint32 {
+			return n, newError(ErrOutputTooLong, "maximum number of iterations reached")
+		}
+		b, err := r.Block(uint32(index))
+		if err != nil {
+			return n, err
+		}
+		c := copy(p, b[skip:])
+		p = p[c:]
+		n += c
+		index++
+		skip = 0
+	}
+	return n, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"io"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type readerSuite struct{}
+
+var _ = Suite(&readerSuite{})
+
+func (s *readerSuite) testReader(c *C, mode Mode, iv []byte) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 0)
+	if mode == PipelineMode {
+		iv = fixed
+	}
+
+	expected := ModeKeyWithAssembler(mode, prf, key, NewStandardAssembler(fixed, true), iv, 1000*8)
+
+	r := NewReader(mode, prf, key, NewStandardAssembler(fixed, true), iv)
+	var got []byte
+	for _, n := range []int{1, 31, 32, 100, 0, 5, 831} {
+		b := make([]byte, n)
+		m, err := r.Read(b)
+		c.Check(err, IsNil)
+		c.Check(m, Equals, n)
+		got = append(got, b...)
+	}
+	c.Check(got, DeepEquals, expected)
+}
+
+func (s *readerSuite) TestCounterMode(c *C) {
+	s.testReader(c, CounterMode, nil)
+}
+
+func (s *readerSuite) TestFeedbackMode(c *C) {
+	s.testReader(c, FeedbackMode, make([]byte, 32))
+}
+
+func (s *readerSuite) TestPipelineMode(c *C) {
+	s.testReader(c, PipelineMode, nil)
+}
+
+func (s *readerSuite) TestReadFull(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 0)
+
+	b := make([]byte, 100)
+	_, err := io.ReadFull(NewReader(CounterMode, prf, key, NewStandardAssembler(fixed, true), nil), b)
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, CounterModeKeyWithFixedData(prf, key, fixed, 800))
+}
+
+func (s *readerSuite) TestCounterLimit(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	r := NewReader(CounterMode, prf, decodeHexString(c, hmacTestKey), &LayoutAssembler{CounterWidth: 8}, nil)
+
+	b := make([]byte, 255*32)
+	n, err := r.Read(b)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, len(b))
+
+	n, err = r.Read(make([]byte, 1))
+	c.Check(err, ErrorMatches, "256 iterations are required but the iteration counter only permits 255")
+	c.Check(n, Equals, 0)
+}
+
+func (s *readerSuite) TestCounterModeReaderAtBlock(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 0)
+	expected := CounterModeKeyWithFixedData(prf, key, fixed, 32*8*10)
+
+	r := NewCounterModeReaderAt(prf, key, NewStandardAssembler(fixed, true))
+	b, err := r.Block(7)
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, expected[7*32:8*32])
+
+	b, err = r.Block(0)
+	c.Check(err, IsNil)
+	c.Check(b, DeepEquals, expected[:32])
+}
+
+func (s *readerSuite) TestCounterModeReaderAt(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 0)
+	expected := CounterModeKeyWithFixedData(prf, key, fixed, 32*8*10)
+
+	r := NewCounterModeReaderAt(prf, key, NewStandardAssembler(fixed, true))
+	for _, t := range []struct {
+		off int64
+		n   int
+	}{
+		{off: 0, n: 320},
+		{off: 32, n: 32},
+		{off: 45, n: 100},
+		{off: 319, n: 1},
+		{off: 100, n: 0},
+	} {
+		b := make([]byte, t.n)
+		n, err := r.ReadAt(b, t.off)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, t.n)
+		c.Check(b, DeepEquals, expected[t.off:t.off+int64(t.n)])
+	}
+}
+
+func (s *readerSuite) TestCounterModeReaderAtLimit(c *C) {
+	r := NewCounterModeReaderAt(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), &LayoutAssembler{CounterWidth: 8})
+
+	n, err := r.ReadAt(make([]byte, 64), 254*32)
+	c.Check(err, ErrorMatches, "256 iterations are required but the iteration counter only permits 255")
+	c.Check(n, Equals, 32)
+
+	_, err = r.Block(255)
+	c.Check(err, ErrorMatches, "256 iterations are required but the iteration counter only permits 255")
+
+	_, err = r.ReadAt(make([]byte, 1), -1)
+	c.Check(err, ErrorMatches, "negative offset")
+}
+
+func (s *readerSuite) testResume(c *C, mode IterationMode, iv []byte) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 0)
+	newReader := func() *Reader {
+		return NewReader(mode, prf, key, NewStandardAssembler(fixed, true), iv)
+	}
+
+	expected := make([]byte, 500)
+	_, err := io.ReadFull(newReader(), expected)
+	c.Assert(err, IsNil)
+
+	for _, split := range []int{0, 1, 32, 100, 499} {
+		r := newReader()
+		_, err := io.ReadFull(r, make([]byte, split))
+		c.Assert(err, IsNil)
+
+		state, err := r.MarshalBinary()
+		c.Check(err, IsNil)
+
+		r2 := newReader()
+		c.Check(r2.UnmarshalBinary(state), IsNil)
+
+		b := make([]byte, 500-split)
+		_, err = io.ReadFull(r2, b)
+		c.Check(err, IsNil)
+		c.Check(b, DeepEquals, expected[split:], Commentf("split at %d", split))
+	}
+}
+
+func (s *readerSuite) TestResumeCounterMode(c *C) {
+	s.testResume(c, CounterMode, nil)
+}
+
+func (s *readerSuite) TestResumeFeedbackMode(c *C) {
+	s.testResume(c, FeedbackMode, make([]byte, 32))
+}
+
+func (s *readerSuite) TestResumePipelineMode(c *C) {
+	s.testResume(c, PipelineMode, FixedBytes([]byte("foo"), []byte("bar"), 0))
+}
+
+func (s *readerSuite) TestResumeCustomMode(c *C) {
+	s.testResume(c, doubleCounterMode{}, make([]byte, 32))
+}
+
+func (s *readerSuite) TestUnmarshalInvalid(c *C) {
+	r := NewReader(CounterMode, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), NewStandardAssembler(nil, true), nil)
+	c.Check(r.UnmarshalBinary([]byte("foo")), ErrorMatches, "invalid reader state identifier")
+	c.Check(r.UnmarshalBinary([]byte("kdf\x01\x00")), ErrorMatches, "invalid reader state size")
+	c.Check(r.UnmarshalBinary(append([]byte("kdf\x01"), decodeHexString(c, "000000010000002100000020")...)), ErrorMatches, "invalid reader state")
+	c.Check(r.UnmarshalBinary(append([]byte("kdf\x01"), decodeHexString(c, "000000000000000000000001ff")...)), ErrorMatches, "invalid reader state")
+}
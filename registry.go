@@ -0,0 +1,177 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	prfsMu sync.RWMutex
+	prfs   = make(map[string]func() PRF)
+)
+
+// RegisterPRF makes a PRF available by the supplied name, so that it can be
+// created with NewPRFByName. It is intended to be called from the init function
+// of packages that implement PRFs, so that a PRF can be made available by
+// importing its package for side-effects. The name should be the same as the one
+// returned from the String method of the PRF. This will panic if a PRF is
+// already registered with the same name.
+func RegisterPRF(name string, fn func() PRF) {
+	prfsMu.Lock()
+	defer prfsMu.Unlock()
+
+	if _, exists := prfs[name]; exists {
+		panic(fmt.Sprintf("PRF %q already registered", name))
+	}
+	prfs[name] = fn
+}
+
+// approvedHMACDigests are the digest algorithms that are approved for use with
+// HMAC in key derivation functions by NIST SP-800-108.
+var approvedHMACDigests = []crypto.Hash{
+	crypto.SHA1,
+	crypto.SHA224,
+	crypto.SHA256,
+	crypto.SHA384,
+	crypto.SHA512,
+	crypto.SHA512_224,
+	crypto.SHA512_256,
+	crypto.SHA3_224,
+	crypto.SHA3_256,
+	crypto.SHA3_384,
+	crypto.SHA3_512,
+}
+
+// availableHMACPRFs returns the HMAC based PRFs for the approved digest
+// algorithms that are linked in to the binary.
+func availableHMACPRFs() map[string]crypto.Hash {
+	res := make(map[string]crypto.Hash)
+	for _, h := range approvedHMACDigests {
+		if !h.Available() {
+			continue
+		}
+		res[hmacPRF{h}.String()] = h
+	}
+	return res
+}
+
+// NewPRFByName creates the PRF with the supplied name, eg, "HMAC-SHA-256" or
+// "CMAC-AES128". HMAC based PRFs are available for the SHA-1, SHA-2 and SHA-3
+// digest algorithms that are linked in to the binary, and the CMAC-AES and
+// CMAC-TDEA PRFs are always available. Other PRFs, including KMAC128 and KMAC256,
+// are available once the packages that register them are imported.
+func NewPRFByName(name string) (PRF, error) {
+	prfsMu.RLock()
+	fn, exists := prfs[name]
+	prfsMu.RUnlock()
+	if exists {
+		return fn(), nil
+	}
+
+	if h, exists := availableHMACPRFs()[name]; exists {
+		return NewHMACPRF(h), nil
+	}
+
+	return nil, fmt.Errorf("unknown PRF %q", name)
+}
+
+// RegisteredPRFs returns the sorted names of all of the PRFs that can currently
+// be created with NewPRFByName.
+func RegisteredPRFs() []string {
+	var names []string
+	for name := range availableHMACPRFs() {
+		names = append(names, name)
+	}
+
+	prfsMu.RLock()
+	for name := range prfs {
+		names = append(names, name)
+	}
+	prfsMu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// normalizePRFName returns a form of the supplied PRF name that is used to
+// match names case-insensitively and regardless of punctuation, so that
+// "HMAC-SHA256" matches "HMAC-SHA-256".
+func normalizePRFName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", "_", "").Replace(name))
+}
+
+// canonicalPRFName returns the name of the available PRF that matches the
+// supplied name, ignoring case and hyphens.
+func canonicalPRFName(name string) (string, bool) {
+	for _, prf := range RegisteredPRFs() {
+		if normalizePRFName(prf) == normalizePRFName(name) {
+			return prf, true
+		}
+	}
+	return "", false
+}
+
+// PRFValue holds a PRF that is selected by name. It implements flag.Value,
+// encoding.TextMarshaler and encoding.TextUnmarshaler, so that a PRF can be
+// selected with a command-line flag or from a configuration file. Names are
+// matched against the PRFs that can be created with NewPRFByName, ignoring case
+// and hyphens. The zero value holds no PRF.
+type PRFValue struct {
+	PRF PRF
+}
+
+func (v *PRFValue) String() string {
+	if v == nil || v.PRF == nil {
+		return ""
+	}
+	return prfName(v.PRF)
+}
+
+// Set creates the PRF with the supplied name.
+func (v *PRFValue) Set(s string) error {
+	name, ok := canonicalPRFName(s)
+	if !ok {
+		return fmt.Errorf("unknown PRF %q", s)
+	}
+	prf, err := NewPRFByName(name)
+	if err != nil {
+		return err
+	}
+	v.PRF = prf
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler. An error is returned if there
+// is no PRF or if it has no name.
+func (v PRFValue) MarshalText() ([]byte, error) {
+	if v.PRF == nil {
+		return nil, errors.New("no PRF")
+	}
+	s, ok := v.PRF.(fmt.Stringer)
+	if !ok {
+		return nil, fmt.Errorf("PRF %T has no name", v.PRF)
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the same names
+// as Set.
+func (v *PRFValue) UnmarshalText(text []byte) error {
+	return v.Set(string(text))
+}
+
+func init() {
+	RegisterPRF("CMAC-AES128", func() PRF { return NewCMACAESPRF(16) })
+	RegisterPRF("CMAC-AES192", func() PRF { return NewCMACAESPRF(24) })
+	RegisterPRF("CMAC-AES256", func() PRF { return NewCMACAESPRF(32) })
+	RegisterPRF("CMAC-TDEA2", func() PRF { return NewCMACTDEAPRF(16) })
+	RegisterPRF("CMAC-TDEA3", func() PRF { return NewCMACTDEAPRF(24) })
+}
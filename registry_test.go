@@ -0,0 +1,134 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type registrySuite struct {
+	restore func()
+}
+
+func (s *registrySuite) SetUpTest(c *C) {
+	s.restore = MockPRFRegistry()
+}
+
+func (s *registrySuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+var _ = Suite(&registrySuite{})
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *registrySuite) TestNewPRFByNameHMAC(c *C) {
+	prf, err := NewPRFByName("HMAC-SHA-256")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewHMACPRF(crypto.SHA256))
+}
+
+func (s *registrySuite) TestNewPRFByNameCMAC(c *C) {
+	prf, err := NewPRFByName("CMAC-AES128")
+	c.Assert(err, IsNil)
+	c.Check(prf.(interface{ String() string }).String(), Equals, "CMAC-AES128")
+	c.Check(prf.Len(), Equals, uint32(16))
+}
+
+func (s *registrySuite) TestNewPRFByNameUnknown(c *C) {
+	_, err := NewPRFByName("foo")
+	c.Check(err, ErrorMatches, `unknown PRF "foo"`)
+}
+
+func (s *registrySuite) TestNewPRFByNameMD5(c *C) {
+	_, err := NewPRFByName("HMAC-MD5")
+	c.Check(err, ErrorMatches, `unknown PRF "HMAC-MD5"`)
+}
+
+func (s *registrySuite) TestNewPRFByNameUnapprovedDigest(c *C) {
+	for _, name := range []string{"HMAC-MD4", "HMAC-RIPEMD-160", "HMAC-BLAKE2b-256"} {
+		_, err := NewPRFByName(name)
+		c.Check(err, ErrorMatches, `unknown PRF "`+name+`"`)
+	}
+}
+
+func (s *registrySuite) TestRegisterPRF(c *C) {
+	RegisterPRF("HMAC-SHA-256-foo", func() PRF { return NewHMACPRF(crypto.SHA256) })
+
+	prf, err := NewPRFByName("HMAC-SHA-256-foo")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewHMACPRF(crypto.SHA256))
+	c.Check(containsString(RegisteredPRFs(), "HMAC-SHA-256-foo"), Equals, true)
+}
+
+func (s *registrySuite) TestRegisterPRFDuplicate(c *C) {
+	c.Check(func() { RegisterPRF("CMAC-AES128", nil) }, PanicMatches, `PRF "CMAC-AES128" already registered`)
+}
+
+func (s *registrySuite) TestRegisteredPRFs(c *C) {
+	names := RegisteredPRFs()
+	for _, name := range []string{"CMAC-AES128", "CMAC-AES256", "CMAC-TDEA3", "HMAC-SHA-256"} {
+		c.Check(containsString(names, name), Equals, true, Commentf("missing %s", name))
+	}
+	for _, name := range []string{"CMAC-SM4", "HMAC-MD5", "KMAC128"} {
+		c.Check(containsString(names, name), Equals, false, Commentf("unexpected %s", name))
+	}
+	for i := 1; i < len(names); i++ {
+		c.Check(names[i-1] < names[i], Equals, true)
+	}
+}
+
+func (s *registrySuite) TestPRFValueFlag(c *C) {
+	var v PRFValue
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&v, "prf", "")
+
+	c.Check(fs.Parse([]string{"-prf", "hmac-sha256"}), IsNil)
+	c.Check(v.PRF, DeepEquals, NewHMACPRF(crypto.SHA256))
+	c.Check(v.String(), Equals, "HMAC-SHA-256")
+}
+
+func (s *registrySuite) TestPRFValueUnknown(c *C) {
+	var v PRFValue
+	c.Check(v.Set("foo"), ErrorMatches, `unknown PRF "foo"`)
+	c.Check(v.PRF, IsNil)
+	c.Check(v.String(), Equals, "")
+}
+
+func (s *registrySuite) TestPRFValueTextMarshaling(c *C) {
+	var cfg struct {
+		PRF PRFValue
+	}
+	c.Check(json.Unmarshal([]byte(`{"PRF":"CMAC-AES256"}`), &cfg), IsNil)
+	c.Check(cfg.PRF.String(), Equals, "CMAC-AES256")
+
+	b, err := json.Marshal(&cfg)
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, `{"PRF":"CMAC-AES256"}`)
+}
+
+func (s *registrySuite) TestPRFValueMarshalTextNoPRF(c *C) {
+	_, err := PRFValue{}.MarshalText()
+	c.Check(err, ErrorMatches, "no PRF")
+}
+
+func (s *registrySuite) TestPRFValueMarshalTextNoName(c *C) {
+	_, err := PRFValue{PRF: NewHMACPRFFromFunc(sha256.New)}.MarshalText()
+	c.Check(err, ErrorMatches, `PRF \*kdf.hmacFuncPRF has no name`)
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+// VersionedKey is a key derived by a Rotator along with its version.
+type VersionedKey struct {
+	Version uint64
+	Key     []byte
+}
+
+// Rotator derives versioned keys from a single key derivation key in counter
+// mode, to support key rotation where new data is protected with the key for the
+// current version and existing data may still need the keys for previous
+// versions. The version is bound to each derived key by prepending it to the
+// context using the encoding described by ContextBuilder, ie, as a 64-bit
+// integer followed by the length-prefixed context, so keys for different
+// versions are always independent.
+//
+// A Rotator is immutable and safe for concurrent use by multiple goroutines.
+type Rotator struct {
+	kdf     *KDF
+	current uint64
+}
+
+// NewRotator creates a new Rotator for the supplied PRF and key derivation key,
+// where current is the current version. The key is copied.
+func NewRotator(prf PRF, key []byte, current uint64) *Rotator {
+	return &Rotator{kdf: NewKDF(prf, key), current: current}
+}
+
+// WithCurrent returns a Rotator that shares the key derivation key with this one,
+// but with a different current version.
+func (r *Rotator) WithCurrent(current uint64) *Rotator {
+	return &Rotator{kdf: r.kdf, current: current}
+}
+
+// Current returns the current version.
+func (r *Rotator) Current() uint64 {
+	return r.current
+}
+
+// Key derives the key for the specified version, using the supplied label and
+// context.
+func (r *Rotator) Key(version uint64, label, context []byte, bitLength uint32) []byte {
+	var b ContextBuilder
+	b.AddUint64(version).AddBytes(context)
+	return r.kdf.Derive(label, b.Bytes(), bitLength)
+}
+
+// CurrentKey derives the key for the current version, using the supplied label
+// and context.
+func (r *Rotator) CurrentKey(label, context []byte, bitLength uint32) VersionedKey {
+	return VersionedKey{Version: r.current, Key: r.Key(r.current, label, context, bitLength)}
+}
+
+// PreviousKeys derives the keys for up to n versions preceding the current
+// version, most recent first, using the supplied label and context. Fewer than
+// n keys are returned if there are fewer than n previous versions.
+func (r *Rotator) PreviousKeys(n int, label, context []byte, bitLength uint32) []VersionedKey {
+	var keys []VersionedKey
+	for v := r.current; v > 0 && len(keys) < n; {
+		v--
+		keys = append(keys, VersionedKey{Version: v, Key: r.Key(v, label, context, bitLength)})
+	}
+	return keys
+}
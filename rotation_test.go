@@ -0,0 +1,61 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type rotationSuite struct{}
+
+var _ = Suite(&rotationSuite{})
+
+func (s *rotationSuite) expected(c *C, version uint64, bitLength uint32) []byte {
+	var context ContextBuilder
+	context.AddUint64(version).AddBytes([]byte("bar"))
+	return CounterModeKey(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), []byte("foo"), context.Bytes(), bitLength)
+}
+
+func (s *rotationSuite) TestKey(c *C) {
+	r := NewRotator(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 5)
+	c.Check(r.Key(3, []byte("foo"), []byte("bar"), 256), DeepEquals, s.expected(c, 3, 256))
+}
+
+func (s *rotationSuite) TestCurrentKey(c *C) {
+	r := NewRotator(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 5)
+	c.Check(r.Current(), Equals, uint64(5))
+	c.Check(r.CurrentKey([]byte("foo"), []byte("bar"), 128), DeepEquals, VersionedKey{Version: 5, Key: s.expected(c, 5, 128)})
+}
+
+func (s *rotationSuite) TestPreviousKeys(c *C) {
+	r := NewRotator(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 5)
+	c.Check(r.PreviousKeys(2, []byte("foo"), []byte("bar"), 256), DeepEquals, []VersionedKey{
+		{Version: 4, Key: s.expected(c, 4, 256)},
+		{Version: 3, Key: s.expected(c, 3, 256)}})
+}
+
+func (s *rotationSuite) TestPreviousKeysTruncated(c *C) {
+	r := NewRotator(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 1)
+	c.Check(r.PreviousKeys(3, []byte("foo"), []byte("bar"), 256), DeepEquals, []VersionedKey{
+		{Version: 0, Key: s.expected(c, 0, 256)}})
+	c.Check(r.WithCurrent(0).PreviousKeys(3, []byte("foo"), []byte("bar"), 256), HasLen, 0)
+}
+
+func (s *rotationSuite) TestWithCurrent(c *C) {
+	r := NewRotator(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 5)
+	r2 := r.WithCurrent(6)
+	c.Check(r.Current(), Equals, uint64(5))
+	c.Check(r2.Current(), Equals, uint64(6))
+	c.Check(r2.PreviousKeys(1, []byte("foo"), []byte("bar"), 256), DeepEquals, []VersionedKey{r.CurrentKey([]byte("foo"), []byte("bar"), 256)})
+}
+
+func (s *rotationSuite) TestVersionsAreIndependent(c *C) {
+	r := NewRotator(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), 1)
+	c.Check(r.Key(0, []byte("foo"), []byte("bar"), 256), Not(DeepEquals), r.Key(1, []byte("foo"), []byte("bar"), 256))
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+// ModeKeyWithAssemblerE derives a key in the same way as ModeKeyWithAssembler,
+// but returns an error rather than panicking if the parameters are invalid. An
+// error is returned if the PRF is nil. If the assembler has a Validate or
+// CheckIterations method, such as LayoutAssembler, these are used to check that
+// it can produce the input for every block.
+//
+// This is stricter than ModeKeyWithAssembler about the secret key, which must be
+// acceptable to CheckKeyLength. For example, a key that is shorter than
+// MinHMACKeyLength is rejected for a HMAC based PRF.
+func ModeKeyWithAssemblerE(mode IterationMode, prf PRF, key []byte, assembler FixedDataAssembler, iv []byte, bitLength uint32) ([]byte, error) {
+	if prf == nil {
+		return nil, ErrNoPRF
+	}
+	if m, ok := mode.(Mode); ok {
+		switch m {
+		case CounterMode, FeedbackMode, PipelineMode:
+		default:
+			return nil, newError(ErrInvalidMode, "invalid mode %d", m)
+		}
+	}
+	if err := CheckKeyLength(prf, len(key)); err != nil {
+		return nil, err
+	}
+	if v, ok := assembler.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if c, ok := assembler.(iterationChecker); ok {
+		if err := c.CheckIterations(numBlocks(prf.Len(), bitLength)); err != nil {
+			return nil, err
+		}
+	}
+	return ModeKeyWithAssembler(mode, prf, key, assembler, iv, bitLength), nil
+}
+
+// CounterModeKeyE derives a key in the same way as CounterModeKey, but returns
+// an error if the PRF is nil or the secret key is not acceptable to
+// CheckKeyLength, which is stricter than CounterModeKey for HMAC based PRFs.
+func CounterModeKeyE(prf PRF, key, label, context []byte, bitLength uint32) ([]byte, error) {
+	return ModeKeyWithAssemblerE(CounterMode, prf, key, NewStandardAssembler(fixedBytes(label, context, bitLength), true), nil, bitLength)
+}
+
+// FeedbackModeKeyE derives a key in the same way as FeedbackModeKey, but
+// returns an error if the PRF is nil or the secret key is not acceptable to
+// CheckKeyLength, which is stricter than FeedbackModeKey for HMAC based PRFs.
+func FeedbackModeKeyE(prf PRF, key, label, context, iv []byte, bitLength uint32, useCounter bool) ([]byte, error) {
+	return ModeKeyWithAssemblerE(FeedbackMode, prf, key, NewStandardAssembler(fixedBytes(label, context, bitLength), useCounter), iv, bitLength)
+}
+
+// PipelineModeKeyE derives a key in the same way as PipelineModeKey, but
+// returns an error if the PRF is nil or the secret key is not acceptable to
+// CheckKeyLength, which is stricter than PipelineModeKey for HMAC based PRFs.
+func PipelineModeKeyE(prf PRF, key, label, context []byte, bitLength uint32, useCounter bool) ([]byte, error) {
+	fixed := fixedBytes(label, context, bitLength)
+	return ModeKeyWithAssemblerE(PipelineMode, prf, key, NewStandardAssembler(fixed, useCounter), fixed, bitLength)
+}
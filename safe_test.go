@@ -0,0 +1,92 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type safeSuite struct{}
+
+var _ = Suite(&safeSuite{})
+
+func (s *safeSuite) TestCounterModeKeyE(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+
+	k, err := CounterModeKeyE(prf, key, []byte("label"), []byte("context"), 640)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, CounterModeKey(prf, key, []byte("label"), []byte("context"), 640))
+}
+
+func (s *safeSuite) TestFeedbackModeKeyE(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	iv := make([]byte, 32)
+
+	k, err := FeedbackModeKeyE(prf, key, []byte("label"), []byte("context"), iv, 640, true)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, FeedbackModeKey(prf, key, []byte("label"), []byte("context"), iv, 640, true))
+
+	k, err = FeedbackModeKeyE(prf, key, []byte("label"), []byte("context"), iv, 640, false)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, FeedbackModeKey(prf, key, []byte("label"), []byte("context"), iv, 640, false))
+}
+
+func (s *safeSuite) TestPipelineModeKeyE(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+
+	k, err := PipelineModeKeyE(prf, key, []byte("label"), []byte("context"), 640, true)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, PipelineModeKey(prf, key, []byte("label"), []byte("context"), 640, true))
+}
+
+func (s *safeSuite) TestInvalidKeyLength(c *C) {
+	_, err := CounterModeKeyE(NewCMACAESPRF(16), make([]byte, 24), []byte("label"), nil, 128)
+	c.Check(err, ErrorMatches, `invalid key length 24 for CMAC-AES128 \(expected 16 bytes\)`)
+
+	_, err = FeedbackModeKeyE(NewHMACPRF(crypto.SHA256), []byte("foo"), []byte("label"), nil, nil, 128, true)
+	c.Check(err, ErrorMatches, "key length 3 is shorter than the minimum of 14 bytes")
+}
+
+func (s *safeSuite) TestInvalidAssembler(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+
+	_, err := ModeKeyWithAssemblerE(CounterMode, prf, key, &LayoutAssembler{CounterWidth: 12}, nil, 256)
+	c.Check(err, ErrorMatches, "invalid counter width 12")
+
+	_, err = ModeKeyWithAssemblerE(CounterMode, prf, key, &LayoutAssembler{CounterLocation: CounterLocation(10)}, nil, 256)
+	c.Check(err, ErrorMatches, "invalid counter location 10")
+
+	_, err = ModeKeyWithAssemblerE(CounterMode, prf, key, &LayoutAssembler{CounterWidth: 8}, nil, 256*256)
+	c.Check(err, ErrorMatches, "256 iterations are required but the iteration counter only permits 255")
+}
+
+func (s *safeSuite) TestInvalidMode(c *C) {
+	_, err := ModeKeyWithAssemblerE(Mode(5), NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey), NewStandardAssembler(nil, true), nil, 256)
+	c.Check(err, ErrorMatches, "invalid mode 5")
+}
+
+func (s *safeSuite) TestNoPRF(c *C) {
+	key := decodeHexString(c, hmacTestKey)
+
+	_, err := CounterModeKeyE(nil, key, []byte("label"), nil, 128)
+	c.Check(err, Equals, ErrNoPRF)
+
+	_, err = FeedbackModeKeyE(nil, key, []byte("label"), nil, make([]byte, 32), 128, true)
+	c.Check(err, Equals, ErrNoPRF)
+
+	_, err = PipelineModeKeyE(nil, key, []byte("label"), nil, 128, true)
+	c.Check(err, Equals, ErrNoPRF)
+
+	_, err = ModeKeyWithAssemblerE(CounterMode, nil, key, NewStandardAssembler(nil, true), nil, 128)
+	c.Check(err, Equals, ErrNoPRF)
+}
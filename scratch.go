@@ -0,0 +1,125 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import "encoding/binary"
+
+// ScratchBuffers contains the working buffers used by ModeKeyWithScratch, which
+// are supplied by the caller so that a derivation doesn't need to allocate. Only
+// the capacity of each buffer is significant. The buffers contain intermediate
+// key material during a derivation, and are cleared before it returns.
+type ScratchBuffers struct {
+	// Input is used to assemble the input to the PRF, including the
+	// iteration counter. Its capacity must be at least the length of the
+	// fixed input data plus 4 bytes for the counter in counter mode. In
+	// feedback and double-pipeline mode, it must be larger by the greater
+	// of the length of the PRF output and the length of the IV.
+	Input []byte
+
+	// Block receives the output of the PRF for each block. Its capacity
+	// must be at least the length of the PRF output.
+	Block []byte
+
+	// Chain holds the iteration variable, which is K(i-1) in feedback mode
+	// and A(i) in double-pipeline mode. Its capacity must be at least the
+	// length of the PRF output in these modes, and it isn't used in counter
+	// mode.
+	Chain []byte
+}
+
+// ModeKeyWithScratch derives a key of the specified length in to dst using the
+// supplied iteration mode and keyed PRF, in the same way as CounterModeKey,
+// FeedbackModeKey or PipelineModeKey for the supplied fixed input data. It uses
+// only the supplied buffers, so it doesn't allocate as long as the keyed PRF
+// doesn't, which permits completely allocation free operation on targets with
+// very small heaps.
+//
+// The iv argument is the IV in feedback mode and the initial value A(0) in
+// double-pipeline mode, which is normally the fixed input data. It is ignored
+// in counter mode, and useCounter must be true in counter mode.
+//
+// It returns the number of bytes written to dst, which is the length of the key
+// rounded up to a whole number of bytes. An error is returned if the mode is
+// invalid or if dst or any of the scratch buffers are too small.
+func ModeKeyWithScratch(dst []byte, mode Mode, prf KeyedPRFAppender, fixedData, iv []byte, bitLength uint32, useCounter bool, scratch *ScratchBuffers) (int, error) {
+	prfLen := int(prf.Len())
+
+	inputLen := len(fixedData)
+	if useCounter {
+		inputLen += 4
+	}
+	switch mode {
+	case CounterMode:
+		if !useCounter {
+			return 0, newError(ErrInvalidMode, "counter mode requires a counter")
+		}
+	case FeedbackMode, PipelineMode:
+		inputLen += max(prfLen, len(iv))
+		if cap(scratch.Chain) < prfLen {
+			return 0, newError(ErrBufferTooSmall, "chain buffer of %d bytes is too small for the %d byte PRF output", cap(scratch.Chain), prfLen)
+		}
+	default:
+		return 0, newError(ErrInvalidMode, "invalid mode %d", mode)
+	}
+
+	size := int((uint64(bitLength) + 7) / 8)
+	if len(dst) < size {
+		return 0, newError(ErrBufferTooSmall, "buffer of %d bytes is too small for a %d byte key", len(dst), size)
+	}
+	if cap(scratch.Input) < inputLen {
+		return 0, newError(ErrBufferTooSmall, "input buffer of %d bytes is too small for a %d byte PRF input", cap(scratch.Input), inputLen)
+	}
+	if cap(scratch.Block) < prfLen {
+		return 0, newError(ErrBufferTooSmall, "block buffer of %d bytes is too small for the %d byte PRF output", cap(scratch.Block), prfLen)
+	}
+
+	input := scratch.Input[:0]
+	block := scratch.Block[:0]
+	chain := scratch.Chain[:0]
+	defer func() {
+		clear(scratch.Input[:cap(scratch.Input)])
+		clear(scratch.Block[:cap(scratch.Block)])
+		clear(scratch.Chain[:cap(scratch.Chain)])
+	}()
+
+	// prev is the iteration variable that forms part of the input for the
+	// next block.
+	var prev []byte
+	switch mode {
+	case FeedbackMode:
+		prev = iv
+	case PipelineMode:
+		chain = prf.AppendRun(chain, iv)
+		prev = chain
+	}
+
+	n := numBlocks(uint32(prfLen), bitLength)
+	off := 0
+	for i := uint32(1); i <= n; i++ {
+		if mode == PipelineMode && i > 1 {
+			input = append(input[:0], chain...)
+			chain = prf.AppendRun(chain[:0], input)
+			prev = chain
+		}
+
+		input = append(input[:0], prev...)
+		if useCounter {
+			input = binary.BigEndian.AppendUint32(input, i)
+		}
+		input = append(input, fixedData...)
+		block = prf.AppendRun(block[:0], input)
+
+		off += copy(dst[off:size], block)
+		if mode == FeedbackMode {
+			chain = append(chain[:0], block...)
+			prev = chain
+		}
+	}
+	if bitLength%8 != 0 {
+		dst[size-1] &= 0xff << (8 - bitLength%8)
+	}
+
+	return size, nil
+}
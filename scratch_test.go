@@ -0,0 +1,127 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type scratchSuite struct{}
+
+var _ = Suite(&scratchSuite{})
+
+func newTestScratchBuffers(fixedLen, prfLen, ivLen int) *ScratchBuffers {
+	return &ScratchBuffers{
+		Input: make([]byte, 0, max(prfLen, ivLen)+4+fixedLen),
+		Block: make([]byte, 0, prfLen),
+		Chain: make([]byte, 0, prfLen)}
+}
+
+func (s *scratchSuite) testModeKeyWithScratch(c *C, prf PRF, key []byte) {
+	keyed := prf.(PRFKeyer).NewKeyedPRF(key).(KeyedPRFAppender)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1000)
+	iv := make([]byte, prf.Len())
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	for _, t := range []struct {
+		mode       Mode
+		iv         []byte
+		useCounter bool
+		expected   []byte
+	}{
+		{mode: CounterMode, useCounter: true, expected: CounterModeKeyWithFixedData(prf, key, fixed, 1000)},
+		{mode: FeedbackMode, iv: iv, useCounter: true, expected: FeedbackModeKeyWithFixedData(prf, key, fixed, iv, 1000, true)},
+		{mode: FeedbackMode, iv: iv, useCounter: false, expected: FeedbackModeKeyWithFixedData(prf, key, fixed, iv, 1000, false)},
+		{mode: PipelineMode, iv: fixed, useCounter: true, expected: PipelineModeKeyWithFixedData(prf, key, fixed, 1000, true)},
+		{mode: PipelineMode, iv: fixed, useCounter: false, expected: PipelineModeKeyWithFixedData(prf, key, fixed, 1000, false)},
+	} {
+		dst := make([]byte, 200)
+		scratch := newTestScratchBuffers(len(fixed), int(prf.Len()), len(t.iv))
+		n, err := ModeKeyWithScratch(dst, t.mode, keyed, fixed, t.iv, 1000, t.useCounter, scratch)
+		c.Check(err, IsNil)
+		c.Check(n, Equals, 125)
+		c.Check(dst[:n], DeepEquals, t.expected, Commentf("%v, useCounter: %v", t.mode, t.useCounter))
+		c.Check(dst[n:], DeepEquals, make([]byte, 75))
+		c.Check(scratch.Input[:cap(scratch.Input)], DeepEquals, make([]byte, cap(scratch.Input)))
+		c.Check(scratch.Block[:cap(scratch.Block)], DeepEquals, make([]byte, cap(scratch.Block)))
+		c.Check(scratch.Chain[:cap(scratch.Chain)], DeepEquals, make([]byte, cap(scratch.Chain)))
+	}
+}
+
+func (s *scratchSuite) TestModeKeyWithScratchHMAC(c *C) {
+	s.testModeKeyWithScratch(c, NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+}
+
+func (s *scratchSuite) TestModeKeyWithScratchCMAC(c *C) {
+	s.testModeKeyWithScratch(c, NewCMACAESPRF(16), decodeHexString(c, "000102030405060708090a0b0c0d0e0f"))
+}
+
+func (s *scratchSuite) TestModeKeyWithScratchLongIV(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	keyed := prf.(PRFKeyer).NewKeyedPRF(key).(KeyedPRFAppender)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 600)
+	iv := make([]byte, 50)
+
+	dst := make([]byte, 75)
+	n, err := ModeKeyWithScratch(dst, FeedbackMode, keyed, fixed, iv, 600, true, newTestScratchBuffers(len(fixed), 32, len(iv)))
+	c.Check(err, IsNil)
+	c.Check(dst[:n], DeepEquals, FeedbackModeKeyWithFixedData(prf, key, fixed, iv, 600, true))
+}
+
+func (s *scratchSuite) TestModeKeyWithScratchDoesntAllocate(c *C) {
+	keyed := NewHMACPRF(crypto.SHA256).(PRFKeyer).NewKeyedPRF(decodeHexString(c, hmacTestKey)).(KeyedPRFAppender)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1000)
+	dst := make([]byte, 125)
+	scratch := newTestScratchBuffers(len(fixed), 32, len(fixed))
+
+	for _, mode := range []Mode{CounterMode, FeedbackMode, PipelineMode} {
+		var iv []byte
+		switch mode {
+		case FeedbackMode:
+			iv = make([]byte, 32)
+		case PipelineMode:
+			iv = fixed
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			ModeKeyWithScratch(dst, mode, keyed, fixed, iv, 1000, true, scratch)
+		})
+		c.Check(allocs, Equals, float64(0), Commentf("%v", mode))
+	}
+}
+
+func (s *scratchSuite) TestModeKeyWithScratchErrors(c *C) {
+	keyed := NewHMACPRF(crypto.SHA256).(PRFKeyer).NewKeyedPRF(decodeHexString(c, hmacTestKey)).(KeyedPRFAppender)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1000)
+
+	for _, t := range []struct {
+		dst        []byte
+		mode       Mode
+		useCounter bool
+		scratch    *ScratchBuffers
+		err        string
+		kind       error
+	}{
+		{dst: make([]byte, 125), mode: Mode(10), useCounter: true, scratch: newTestScratchBuffers(len(fixed), 32, 0), err: "invalid mode 10", kind: ErrInvalidMode},
+		{dst: make([]byte, 125), mode: CounterMode, scratch: newTestScratchBuffers(len(fixed), 32, 0), err: "counter mode requires a counter", kind: ErrInvalidMode},
+		{dst: make([]byte, 124), mode: CounterMode, useCounter: true, scratch: newTestScratchBuffers(len(fixed), 32, 0), err: "buffer of 124 bytes is too small for a 125 byte key", kind: ErrBufferTooSmall},
+		{dst: make([]byte, 125), mode: CounterMode, useCounter: true, scratch: &ScratchBuffers{Input: make([]byte, 0, len(fixed)), Block: make([]byte, 32)}, err: "input buffer of 11 bytes is too small for a 15 byte PRF input", kind: ErrBufferTooSmall},
+		{dst: make([]byte, 125), mode: CounterMode, useCounter: true, scratch: &ScratchBuffers{Input: make([]byte, len(fixed)+4), Block: make([]byte, 31)}, err: "block buffer of 31 bytes is too small for the 32 byte PRF output", kind: ErrBufferTooSmall},
+		{dst: make([]byte, 125), mode: FeedbackMode, useCounter: true, scratch: &ScratchBuffers{Input: make([]byte, 100), Block: make([]byte, 32), Chain: make([]byte, 16)}, err: "chain buffer of 16 bytes is too small for the 32 byte PRF output", kind: ErrBufferTooSmall},
+		{dst: make([]byte, 125), mode: FeedbackMode, useCounter: true, scratch: &ScratchBuffers{Input: make([]byte, 40), Block: make([]byte, 32), Chain: make([]byte, 32)}, err: "input buffer of 40 bytes is too small for a 47 byte PRF input", kind: ErrBufferTooSmall},
+	} {
+		_, err := ModeKeyWithScratch(t.dst, t.mode, keyed, fixed, nil, 1000, t.useCounter, t.scratch)
+		c.Check(err, ErrorMatches, t.err)
+		c.Check(errors.Is(err, t.kind), Equals, true)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import "io"
+
+// OutputSink receives the output of a key derivation, allowing it to be written
+// directly in to storage that is managed by the caller.
+type OutputSink interface {
+	// Grow is called once at the start of a derivation with the total number
+	// of bytes that will be written.
+	Grow(n int)
+
+	// WriteBlock is called with each block of derived key material, in
+	// order. The final block is truncated to the requested length. The
+	// supplied slice is only valid for the duration of the call.
+	WriteBlock(block []byte)
+}
+
+// SliceSink is an OutputSink that accumulates the derived key material in a
+// slice. The zero value is ready to use.
+type SliceSink struct {
+	b []byte
+}
+
+func (s *SliceSink) Grow(n int) {
+	if n > cap(s.b)-len(s.b) {
+		b := make([]byte, len(s.b), len(s.b)+n)
+		copy(b, s.b)
+		s.b = b
+	}
+}
+
+func (s *SliceSink) WriteBlock(block []byte) {
+	s.b = append(s.b, block...)
+}
+
+// Bytes returns the derived key material written to this sink.
+func (s *SliceSink) Bytes() []byte {
+	return s.b
+}
+
+// bufferSink is an OutputSink that writes in to a caller supplied buffer that
+// is large enough for the entire output.
+type bufferSink struct {
+	buf []byte
+	n   int
+}
+
+func (s *bufferSink) Grow(int) {}
+
+func (s *bufferSink) WriteBlock(block []byte) {
+	s.n += copy(s.buf[s.n:], block)
+}
+
+// callbackSink is an OutputSink that passes each block to a callback along with
+// its index.
+type callbackSink struct {
+	fn func(i uint32, block []byte)
+	i  uint32
+}
+
+func (s *callbackSink) Grow(int) {}
+
+func (s *callbackSink) WriteBlock(block []byte) {
+	s.i++
+	s.fn(s.i, block)
+}
+
+// BlockCallback returns an OutputSink that invokes the supplied callback with
+// each block K(i) of derived key material as it is produced, along with the
+// one-based index i of the block. This permits custom assembly of the output,
+// such as routing different blocks to different consumers, without buffering
+// the whole output. The final block is truncated to the requested length, and
+// the supplied slice is only valid for the duration of the call.
+func BlockCallback(fn func(i uint32, block []byte)) OutputSink {
+	return &callbackSink{fn: fn}
+}
+
+// DeriveBlocks derives a key in the same way as Derive, but invokes the supplied
+// callback with each block of output as described for BlockCallback rather than
+// returning the key. An error is returned if the parameters are invalid, in
+// which case the callback is not invoked.
+func DeriveBlocks(params *Params, fn func(i uint32, block []byte)) error {
+	return deriveToSink(params, BlockCallback(fn))
+}
+
+// writerChunkSize is the maximum number of bytes of derived key material that
+// are buffered by a writerSink before they are written.
+const writerChunkSize = 4096
+
+// writerSink is an OutputSink that writes the derived key material to an
+// io.Writer in chunks. The first error is retained, and no further writes are
+// attempted after an error.
+type writerSink struct {
+	w   io.Writer
+	buf []byte
+	n   int64
+	err error
+}
+
+func (s *writerSink) Grow(n int) {
+	s.buf = make([]byte, 0, min(n, writerChunkSize))
+}
+
+func (s *writerSink) WriteBlock(block []byte) {
+	if len(s.buf)+len(block) > cap(s.buf) {
+		s.flush()
+	}
+	if s.err != nil {
+		return
+	}
+	s.buf = append(s.buf, block...)
+}
+
+// flush writes the buffered key material to the underlying writer, and clears
+// the buffer.
+func (s *writerSink) flush() error {
+	if s.err == nil && len(s.buf) > 0 {
+		n, err := s.w.Write(s.buf)
+		s.n += int64(n)
+		switch {
+		case err != nil:
+			s.err = err
+		case n != len(s.buf):
+			s.err = io.ErrShortWrite
+		}
+	}
+	clear(s.buf)
+	s.buf = s.buf[:0]
+	return s.err
+}
+
+// DeriveTo derives a key in the same way as Derive, but writes it to the
+// supplied writer in chunks rather than returning it, so that the whole output
+// is never held in memory. This bounds the memory required to produce very long
+// outputs, such as keystreams or test fixtures. It returns the number of bytes
+// written. An error is returned if the parameters are invalid or if writing
+// fails, in which case the derivation is abandoned.
+func DeriveTo(w io.Writer, params *Params) (int64, error) {
+	sink := &writerSink{w: w}
+	if err := deriveToSinkWithCheck(params, sink, func() error { return sink.err }); err != nil {
+		return sink.n, err
+	}
+	err := sink.flush()
+	return sink.n, err
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"errors"
+	"io"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type sinkSuite struct{}
+
+var _ = Suite(&sinkSuite{})
+
+// ringSink is an OutputSink that writes in to a fixed size ring buffer.
+type ringSink struct {
+	buf    []byte
+	pos    int
+	grow   int
+	blocks int
+}
+
+func (s *ringSink) Grow(n int) {
+	s.grow = n
+}
+
+func (s *ringSink) WriteBlock(block []byte) {
+	s.blocks++
+	for _, b := range block {
+		s.buf[s.pos] = b
+		s.pos = (s.pos + 1) % len(s.buf)
+	}
+}
+
+// contents returns the contents of the ring buffer, oldest byte first.
+func (s *ringSink) contents() []byte {
+	return append(append([]byte{}, s.buf[s.pos:]...), s.buf[:s.pos]...)
+}
+
+func (s *sinkSuite) TestSliceSink(c *C) {
+	var sink SliceSink
+	sink.Grow(10)
+	c.Check(sink.Bytes(), HasLen, 0)
+	sink.WriteBlock([]byte("foo"))
+	sink.WriteBlock([]byte("bar"))
+	c.Check(sink.Bytes(), DeepEquals, []byte("foobar"))
+}
+
+func (s *sinkSuite) TestCounterModeRing(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 1000)
+
+	sink := &ringSink{buf: make([]byte, 50)}
+	CounterModeKeyToSink(prf, key, NewStandardAssembler(fixed, true), 1000, sink)
+
+	expected := CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 1000)
+	c.Check(sink.grow, Equals, 125)
+	c.Check(sink.blocks, Equals, 4)
+	c.Check(sink.pos, Equals, 125%50)
+	c.Check(sink.contents(), DeepEquals, expected[75:])
+}
+
+func (s *sinkSuite) TestFeedbackModeRing(c *C) {
+	prf := NewHMACPRF(crypto.SHA1)
+	key := []byte("1234567890123456")
+	iv := make([]byte, 20)
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 256)
+
+	sink := &ringSink{buf: make([]byte, 32)}
+	FeedbackModeKeyToSink(prf, key, NewStandardAssembler(fixed, true), iv, 256, sink)
+
+	c.Check(sink.blocks, Equals, 2)
+	c.Check(sink.contents(), DeepEquals, FeedbackModeKey(prf, key, []byte("foo"), []byte("bar"), iv, 256, true))
+}
+
+func (s *sinkSuite) TestPipelineModeRing(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 256)
+
+	sink := &ringSink{buf: make([]byte, 32)}
+	PipelineModeKeyToSink(prf, key, NewStandardAssembler(fixed, false), fixed, 256, sink)
+
+	c.Check(sink.blocks, Equals, 1)
+	c.Check(sink.contents(), DeepEquals, PipelineModeKey(prf, key, []byte("foo"), []byte("bar"), 256, false))
+}
+
+func (s *sinkSuite) TestBlockCallback(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := FixedBytes([]byte("foo"), []byte("bar"), 600)
+
+	var indices []uint32
+	var out []byte
+	CounterModeKeyToSink(prf, key, NewStandardAssembler(fixed, true), 600, BlockCallback(func(i uint32, block []byte) {
+		indices = append(indices, i)
+		out = append(out, block...)
+	}))
+	c.Check(indices, DeepEquals, []uint32{1, 2, 3})
+	c.Check(out, DeepEquals, CounterModeKey(prf, key, []byte("foo"), []byte("bar"), 600))
+}
+
+func (s *sinkSuite) TestDeriveBlocks(c *C) {
+	params := testParams()
+	params.BitLength = 512
+
+	var blocks [][]byte
+	c.Check(DeriveBlocks(params, func(i uint32, block []byte) {
+		blocks = append(blocks, append([]byte{}, block...))
+	}), IsNil)
+
+	expected := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 512)
+	c.Check(blocks, DeepEquals, [][]byte{expected[:32], expected[32:]})
+}
+
+func (s *sinkSuite) TestDeriveBlocksInvalidParams(c *C) {
+	called := false
+	err := DeriveBlocks(&Params{}, func(uint32, []byte) { called = true })
+	c.Check(err, ErrorMatches, "no PRF supplied")
+	c.Check(called, Equals, false)
+}
+
+// chunkWriter is an io.Writer that records the size of each write, and which
+// fails once the specified number of writes have succeeded if fail is set.
+type chunkWriter struct {
+	out    []byte
+	writes []int
+	fail   int
+	short  bool
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.fail > 0 && len(w.writes) == w.fail {
+		return 0, errors.New("write failed")
+	}
+	w.writes = append(w.writes, len(p))
+	if w.short {
+		p = p[:len(p)-1]
+	}
+	w.out = append(w.out, p...)
+	return len(p), nil
+}
+
+func (s *sinkSuite) testDeriveTo(c *C, bitLength uint32, writes []int) {
+	params := testParams()
+	params.BitLength = bitLength
+
+	var w chunkWriter
+	n, err := DeriveTo(&w, params)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, int64(len(w.out)))
+	c.Check(w.writes, DeepEquals, writes)
+
+	expected, err := Derive(params)
+	c.Check(err, IsNil)
+	c.Check(w.out, DeepEquals, expected)
+}
+
+func (s *sinkSuite) TestDeriveToSmall(c *C) {
+	s.testDeriveTo(c, 500, []int{63})
+}
+
+func (s *sinkSuite) TestDeriveToChunked(c *C) {
+	s.testDeriveTo(c, 8*10000, []int{4096, 4096, 1808})
+}
+
+func (s *sinkSuite) TestDeriveToEmpty(c *C) {
+	s.testDeriveTo(c, 0, nil)
+}
+
+func (s *sinkSuite) TestDeriveToWriteError(c *C) {
+	params := testParams()
+	params.BitLength = 8 * 100000
+
+	w := &chunkWriter{fail: 2}
+	n, err := DeriveTo(w, params)
+	c.Check(err, ErrorMatches, "write failed")
+	c.Check(n, Equals, int64(8192))
+	c.Check(w.writes, DeepEquals, []int{4096, 4096})
+}
+
+func (s *sinkSuite) TestDeriveToShortWrite(c *C) {
+	params := testParams()
+	params.BitLength = 8 * 10000
+
+	w := &chunkWriter{short: true}
+	n, err := DeriveTo(w, params)
+	c.Check(err, Equals, io.ErrShortWrite)
+	c.Check(n, Equals, int64(4095))
+	c.Check(w.writes, DeepEquals, []int{4096})
+}
+
+func (s *sinkSuite) TestDeriveToInvalidParams(c *C) {
+	var w chunkWriter
+	n, err := DeriveTo(&w, &Params{})
+	c.Check(err, ErrorMatches, "no PRF supplied")
+	c.Check(n, Equals, int64(0))
+	c.Check(w.writes, HasLen, 0)
+}
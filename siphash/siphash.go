@@ -0,0 +1,139 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package siphash provides PRFs based on SipHash, for use with the key derivation
+functions in the parent package.
+
+SipHash is not approved by NIST and has a 64-bit output and a 128-bit key, so
+these PRFs are not suitable for deriving cryptographic keys. They are intended
+for high-rate diversification of non-secret identifiers, such as for cache
+sharding or deduplication tokens.
+*/
+package siphash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+const (
+	// KeySize is the size of a SipHash key in bytes.
+	KeySize = 16
+
+	// Size is the size of a SipHash output in bytes.
+	Size = 8
+
+	// BlockSize is the block size of SipHash in bytes.
+	BlockSize = 8
+)
+
+type state struct {
+	v0, v1, v2, v3 uint64
+}
+
+func (s *state) rounds(n int) {
+	for i := 0; i < n; i++ {
+		s.v0 += s.v1
+		s.v1 = bits.RotateLeft64(s.v1, 13)
+		s.v1 ^= s.v0
+		s.v0 = bits.RotateLeft64(s.v0, 32)
+		s.v2 += s.v3
+		s.v3 = bits.RotateLeft64(s.v3, 16)
+		s.v3 ^= s.v2
+		s.v0 += s.v3
+		s.v3 = bits.RotateLeft64(s.v3, 21)
+		s.v3 ^= s.v0
+		s.v2 += s.v1
+		s.v1 = bits.RotateLeft64(s.v1, 17)
+		s.v1 ^= s.v2
+		s.v2 = bits.RotateLeft64(s.v2, 32)
+	}
+}
+
+// sum computes SipHash-c-d of msg with the supplied 16 byte key.
+func sum(c, d int, key, msg []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+
+	s := state{
+		v0: k0 ^ 0x736f6d6570736575,
+		v1: k1 ^ 0x646f72616e646f6d,
+		v2: k0 ^ 0x6c7967656e657261,
+		v3: k1 ^ 0x7465646279746573}
+
+	b := uint64(len(msg)) << 56
+	for ; len(msg) >= BlockSize; msg = msg[BlockSize:] {
+		m := binary.LittleEndian.Uint64(msg)
+		s.v3 ^= m
+		s.rounds(c)
+		s.v0 ^= m
+	}
+	for i, x := range msg {
+		b |= uint64(x) << (8 * i)
+	}
+	s.v3 ^= b
+	s.rounds(c)
+	s.v0 ^= b
+
+	s.v2 ^= 0xff
+	s.rounds(d)
+	return s.v0 ^ s.v1 ^ s.v2 ^ s.v3
+}
+
+type sipHashPRF struct {
+	c, d int
+}
+
+func (p *sipHashPRF) Len() uint32 {
+	return Size
+}
+
+func (p *sipHashPRF) String() string {
+	return fmt.Sprintf("SipHash-%d-%d", p.c, p.d)
+}
+
+func (p *sipHashPRF) BlockSize() int {
+	return BlockSize
+}
+
+func (p *sipHashPRF) KeySize() int {
+	return KeySize
+}
+
+func (p *sipHashPRF) CheckKeyLength(n int) error {
+	if n != KeySize {
+		return fmt.Errorf("invalid key length %d for %v (expected %d bytes)", n, p, KeySize)
+	}
+	return nil
+}
+
+func (p *sipHashPRF) Run(s, x []byte) []byte {
+	if err := p.CheckKeyLength(len(s)); err != nil {
+		panic(err)
+	}
+	var out [Size]byte
+	binary.LittleEndian.PutUint64(out[:], sum(p.c, p.d, s, x))
+	return out[:]
+}
+
+// NewSipHash24PRF creates a new PRF based on SipHash-2-4. The PRF will panic if
+// it is supplied with a key that isn't 16 bytes long.
+func NewSipHash24PRF() kdf.PRF {
+	return &sipHashPRF{c: 2, d: 4}
+}
+
+// NewSipHash48PRF creates a new PRF based on the more conservative SipHash-4-8.
+// The PRF will panic if it is supplied with a key that isn't 16 bytes long.
+func NewSipHash48PRF() kdf.PRF {
+	return &sipHashPRF{c: 4, d: 8}
+}
+
+func init() {
+	kdf.RegisterPRF("SipHash-2-4", NewSipHash24PRF)
+	kdf.RegisterPRF("SipHash-4-8", NewSipHash48PRF)
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package siphash_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/siphash"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type siphashSuite struct{}
+
+var _ = Suite(&siphashSuite{})
+
+var siphashTestKey = "000102030405060708090a0b0c0d0e0f"
+
+// The following tests correspond to the test vectors from the SipHash reference
+// implementation.
+
+func (s *siphashSuite) TestSipHash24Empty(c *C) {
+	prf := NewSipHash24PRF()
+	c.Check(prf.Len(), Equals, uint32(8))
+	c.Check(prf.Run(decodeHexString(c, siphashTestKey), nil), DeepEquals, decodeHexString(c, "310e0edd47db6f72"))
+}
+
+func (s *siphashSuite) TestSipHash24PartialBlock(c *C) {
+	c.Check(NewSipHash24PRF().Run(decodeHexString(c, siphashTestKey), decodeHexString(c, "000102030405060708090a0b0c0d0e")), DeepEquals, decodeHexString(c, "e545be4961ca29a1"))
+}
+
+// The following tests were generated with an independent implementation in
+// Python.
+
+func (s *siphashSuite) TestCounterModeSipHash24(c *C) {
+	c.Check(kdf.CounterModeKey(NewSipHash24PRF(), decodeHexString(c, siphashTestKey), []byte("label"), []byte("context"), 256), DeepEquals,
+		decodeHexString(c, "ced6067a4230219915611e02823777a523fa268fdb3228cde067c18ef5b921f1"))
+}
+
+func (s *siphashSuite) TestCounterModeSipHash48(c *C) {
+	c.Check(kdf.CounterModeKey(NewSipHash48PRF(), decodeHexString(c, siphashTestKey), []byte("label"), []byte("context"), 256), DeepEquals,
+		decodeHexString(c, "db8331a561ef27dc72ee621045ecd52f919198a3542ceaeae27133c37f7d9ce6"))
+}
+
+func (s *siphashSuite) TestString(c *C) {
+	c.Check(NewSipHash24PRF().(interface{ String() string }).String(), Equals, "SipHash-2-4")
+	c.Check(NewSipHash48PRF().(interface{ String() string }).String(), Equals, "SipHash-4-8")
+}
+
+func (s *siphashSuite) TestInvalidKeyLength(c *C) {
+	c.Check(func() { NewSipHash24PRF().Run(make([]byte, 32), nil) }, PanicMatches, `invalid key length 32 for SipHash-2-4 \(expected 16 bytes\)`)
+	c.Check(kdf.CheckKeyLength(NewSipHash48PRF(), 16), IsNil)
+}
+
+func (s *siphashSuite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("SipHash-2-4")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewSipHash24PRF())
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package skein provides a PRF based on Skein-MAC, for use with the key derivation
+functions in the parent package. This uses Skein-512 version 1.3 in its native
+keyed mode rather than HMAC.
+
+Skein is not approved by NIST, and should only be used where FIPS compliance is
+not required.
+*/
+package skein
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+const (
+	// BlockSize is the block size of Skein-512 in bytes.
+	BlockSize = 64
+
+	// MaxSize is the maximum output size of the PRF in bytes.
+	MaxSize = 64
+)
+
+// Type values for the UBI tweak.
+const (
+	typeKey uint64 = 0
+	typeCfg uint64 = 4
+	typeMsg uint64 = 48
+	typeOut uint64 = 63
+)
+
+const c240 = 0x1bd11bdaa9fc1a22
+
+var rotations = [8][4]int{
+	{46, 36, 19, 37},
+	{33, 27, 14, 42},
+	{17, 49, 36, 39},
+	{44, 9, 54, 56},
+	{39, 30, 34, 24},
+	{13, 50, 10, 17},
+	{25, 29, 39, 43},
+	{8, 35, 56, 22}}
+
+// threefish encrypts the supplied block in place with Threefish-512 using the
+// supplied key and tweak.
+func threefish(key *[8]uint64, tweak *[2]uint64, x *[8]uint64) {
+	var k [9]uint64
+	k[8] = c240
+	for i := 0; i < 8; i++ {
+		k[i] = key[i]
+		k[8] ^= key[i]
+	}
+	t := [3]uint64{tweak[0], tweak[1], tweak[0] ^ tweak[1]}
+
+	injectKey := func(s int) {
+		for i := 0; i < 8; i++ {
+			x[i] += k[(s+i)%9]
+		}
+		x[5] += t[s%3]
+		x[6] += t[(s+1)%3]
+		x[7] += uint64(s)
+	}
+
+	for d := 0; d < 72; d++ {
+		if d%4 == 0 {
+			injectKey(d / 4)
+		}
+		r := &rotations[d%8]
+		for j := 0; j < 4; j++ {
+			x[2*j] += x[2*j+1]
+			x[2*j+1] = bits.RotateLeft64(x[2*j+1], r[j]) ^ x[2*j]
+		}
+		// Apply the word permutation 2 1 4 7 6 5 0 3.
+		*x = [8]uint64{x[2], x[1], x[4], x[7], x[6], x[5], x[0], x[3]}
+	}
+	injectKey(18)
+}
+
+// ubi implements the UBI chaining mode, updating g with the result of
+// processing msg with the specified type.
+func ubi(g *[8]uint64, msg []byte, typ uint64) {
+	var pos uint64
+	first := true
+	for {
+		var block [BlockSize]byte
+		n := copy(block[:], msg)
+		msg = msg[n:]
+		pos += uint64(n)
+
+		tweak := [2]uint64{pos, typ << 56}
+		if first {
+			tweak[1] |= 1 << 62
+		}
+		if len(msg) == 0 {
+			tweak[1] |= 1 << 63
+		}
+
+		var m, x [8]uint64
+		for i := range m {
+			m[i] = binary.LittleEndian.Uint64(block[i*8:])
+		}
+		x = m
+		threefish(g, &tweak, &x)
+		for i := range g {
+			g[i] = x[i] ^ m[i]
+		}
+
+		if len(msg) == 0 {
+			return
+		}
+		first = false
+	}
+}
+
+// sum computes Skein-512 with the specified key, which may be empty for the
+// unkeyed hash, and output size in bytes.
+func sum(key, msg []byte, size int) []byte {
+	var g [8]uint64
+	if len(key) > 0 {
+		ubi(&g, key, typeKey)
+	}
+
+	var cfg [32]byte
+	copy(cfg[:], "SHA3")
+	binary.LittleEndian.PutUint16(cfg[4:], 1)
+	binary.LittleEndian.PutUint64(cfg[8:], uint64(size)*8)
+	ubi(&g, cfg[:], typeCfg)
+
+	ubi(&g, msg, typeMsg)
+
+	var counter [8]byte
+	ubi(&g, counter[:], typeOut)
+
+	var out [BlockSize]byte
+	for i, v := range g {
+		binary.LittleEndian.PutUint64(out[i*8:], v)
+	}
+	return out[:size]
+}
+
+type macPRF struct {
+	size int
+}
+
+func (p *macPRF) Len() uint32 {
+	return uint32(p.size)
+}
+
+func (p *macPRF) String() string {
+	return fmt.Sprintf("Skein-MAC-512-%d", p.size*8)
+}
+
+func (p *macPRF) BlockSize() int {
+	return BlockSize
+}
+
+func (p *macPRF) KeySize() int {
+	return BlockSize
+}
+
+func (p *macPRF) CheckKeyLength(n int) error {
+	if n < 1 {
+		return fmt.Errorf("key length %d is shorter than the minimum of 1 byte", n)
+	}
+	return nil
+}
+
+func (p *macPRF) Run(s, x []byte) []byte {
+	return sum(s, x, p.size)
+}
+
+// NewMACPRF creates a new PRF based on Skein-512-MAC with the specified output
+// size in bytes, which must be between 1 and 64.
+func NewMACPRF(size int) kdf.PRF {
+	if size < 1 || size > MaxSize {
+		panic(fmt.Sprintf("invalid Skein output size %d", size))
+	}
+	return &macPRF{size: size}
+}
+
+func init() {
+	kdf.RegisterPRF("Skein-MAC-512-256", func() kdf.PRF { return NewMACPRF(32) })
+	kdf.RegisterPRF("Skein-MAC-512-512", func() kdf.PRF { return NewMACPRF(64) })
+}
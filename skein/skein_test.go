@@ -0,0 +1,88 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package skein_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/skein"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type skeinSuite struct{}
+
+var _ = Suite(&skeinSuite{})
+
+// The following tests correspond to the known answer tests in the Skein 1.3
+// specification.
+
+func (s *skeinSuite) TestSkein512OneByte(c *C) {
+	c.Check(Sum(nil, []byte{0xff}, 64), DeepEquals, decodeHexString(c, "71b7bce6fe6452227b9ced6014249e5bf9a9754c3ad618ccc4e0aae16b316cc8ca698d864307ed3e80b6ef1570812ac5272dc409b5a012df2a579102f340617a"))
+}
+
+func (s *skeinSuite) TestSkein512OneBlock(c *C) {
+	msg := make([]byte, 64)
+	for i := range msg {
+		msg[i] = byte(0xff - i)
+	}
+	c.Check(Sum(nil, msg, 64), DeepEquals, decodeHexString(c, "45863ba3be0c4dfc27e75d358496f4ac9a736a505d9313b42b2f5eada79fc17f63861e947afb1d056aa199575ad3f8c9a3cc1780b5e5fa4cae050e989876625b"))
+}
+
+func (s *skeinSuite) TestSkein512_256Empty(c *C) {
+	c.Check(Sum(nil, nil, 32), DeepEquals, decodeHexString(c, "39ccc4554a8b31853b9de7a1fe638a24cce6b35a55f2431009e18780335d2621"))
+}
+
+func (s *skeinSuite) TestMACPRF(c *C) {
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	prf := NewMACPRF(32)
+	c.Check(prf.Len(), Equals, uint32(32))
+	c.Check(prf.Run(key, []byte("foo")), DeepEquals, Sum(key, []byte("foo"), 32))
+	c.Check(prf.Run(key, []byte("foo")), Not(DeepEquals), Sum(nil, []byte("foo"), 32))
+}
+
+func (s *skeinSuite) TestCounterMode(c *C) {
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	fixed := []byte("label\x00context\x00\x00\x02\x80")
+
+	var expected []byte
+	expected = append(expected, Sum(key, append([]byte{0, 0, 0, 1}, fixed...), 64)...)
+	expected = append(expected, Sum(key, append([]byte{0, 0, 0, 2}, fixed...), 64)...)
+
+	c.Check(kdf.CounterModeKey(NewMACPRF(64), key, []byte("label"), []byte("context"), 640), DeepEquals, expected[:80])
+}
+
+func (s *skeinSuite) TestString(c *C) {
+	c.Check(NewMACPRF(64).(interface{ String() string }).String(), Equals, "Skein-MAC-512-512")
+}
+
+func (s *skeinSuite) TestInvalidSize(c *C) {
+	c.Check(func() { NewMACPRF(0) }, PanicMatches, "invalid Skein output size 0")
+	c.Check(func() { NewMACPRF(65) }, PanicMatches, "invalid Skein output size 65")
+}
+
+func (s *skeinSuite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("Skein-MAC-512-512")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewMACPRF(64))
+}
+
+func (s *skeinSuite) TestKeyInfo(c *C) {
+	info := NewMACPRF(32).(kdf.PRFKeyInfo)
+	c.Check(info.BlockSize(), Equals, 64)
+	c.Check(info.KeySize(), Equals, 64)
+	c.Check(info.CheckKeyLength(1), IsNil)
+	c.Check(info.CheckKeyLength(0), ErrorMatches, "key length 0 is shorter than the minimum of 1 byte")
+}
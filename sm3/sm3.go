@@ -0,0 +1,210 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package sm3 implements the SM3 hash algorithm defined in GB/T 32905-2016, and
+provides an HMAC-SM3 PRF for use with the key derivation functions in the parent
+package. This allows keys to be derived in a way that is compatible with Chinese
+commercial cryptography implementations.
+
+SM3 is not approved by NIST.
+*/
+package sm3
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math/bits"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+const (
+	// Size is the size of a SM3 digest in bytes.
+	Size = 32
+
+	// BlockSize is the block size of SM3 in bytes.
+	BlockSize = 64
+)
+
+var iv = [8]uint32{
+	0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600,
+	0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e}
+
+type digest struct {
+	h   [8]uint32
+	x   [BlockSize]byte
+	nx  int
+	len uint64
+}
+
+// New returns a new hash.Hash computing the SM3 digest.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+func (d *digest) Reset() {
+	d.h = iv
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *digest) Clone() (hash.Cloner, error) {
+	d0 := *d
+	return &d0, nil
+}
+
+func (d *digest) Size() int {
+	return Size
+}
+
+func (d *digest) BlockSize() int {
+	return BlockSize
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx < BlockSize {
+			return n, nil
+		}
+		block(&d.h, d.x[:])
+		d.nx = 0
+	}
+
+	for len(p) >= BlockSize {
+		block(&d.h, p[:BlockSize])
+		p = p[BlockSize:]
+	}
+
+	d.nx = copy(d.x[:], p)
+	return n, nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	// Make a copy so that the caller can keep writing.
+	d0 := *d
+
+	var pad [BlockSize + 8]byte
+	pad[0] = 0x80
+	n := 1 + (BlockSize+BlockSize-9-d0.nx)%BlockSize
+	binary.BigEndian.PutUint64(pad[n:], d0.len*8)
+	d0.Write(pad[:n+8])
+
+	var out [Size]byte
+	for i, v := range d0.h {
+		binary.BigEndian.PutUint32(out[i*4:], v)
+	}
+	return append(b, out[:]...)
+}
+
+func p0(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 9) ^ bits.RotateLeft32(x, 17)
+}
+
+func p1(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23)
+}
+
+func block(h *[8]uint32, p []byte) {
+	var w [68]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[i*4:])
+	}
+	for j := 16; j < 68; j++ {
+		w[j] = p1(w[j-16]^w[j-9]^bits.RotateLeft32(w[j-3], 15)) ^ bits.RotateLeft32(w[j-13], 7) ^ w[j-6]
+	}
+
+	a, b, c, d, e, f, g, hh := h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7]
+
+	for j := 0; j < 64; j++ {
+		var t, ff, gg uint32
+		if j < 16 {
+			t = 0x79cc4519
+			ff = a ^ b ^ c
+			gg = e ^ f ^ g
+		} else {
+			t = 0x7a879d8a
+			ff = (a & b) | (a & c) | (b & c)
+			gg = (e & f) | (^e & g)
+		}
+
+		a12 := bits.RotateLeft32(a, 12)
+		ss1 := bits.RotateLeft32(a12+e+bits.RotateLeft32(t, j%32), 7)
+		ss2 := ss1 ^ a12
+		tt1 := ff + d + ss2 + (w[j] ^ w[j+4])
+		tt2 := gg + hh + ss1 + w[j]
+
+		d = c
+		c = bits.RotateLeft32(b, 9)
+		b = a
+		a = tt1
+		hh = g
+		g = bits.RotateLeft32(f, 19)
+		f = e
+		e = p0(tt2)
+	}
+
+	h[0] ^= a
+	h[1] ^= b
+	h[2] ^= c
+	h[3] ^= d
+	h[4] ^= e
+	h[5] ^= f
+	h[6] ^= g
+	h[7] ^= hh
+}
+
+type hmacPRF struct{}
+
+func (hmacPRF) Len() uint32 {
+	return Size
+}
+
+func (hmacPRF) String() string {
+	return "HMAC-SM3"
+}
+
+func (hmacPRF) BlockSize() int {
+	return BlockSize
+}
+
+func (hmacPRF) KeySize() int {
+	return Size
+}
+
+func (hmacPRF) CheckKeyLength(n int) error {
+	if n < kdf.MinHMACKeyLength {
+		return fmt.Errorf("key length %d is shorter than the minimum of %d bytes", n, kdf.MinHMACKeyLength)
+	}
+	return nil
+}
+
+func (hmacPRF) Run(s, x []byte) []byte {
+	h := hmac.New(New, s)
+	h.Write(x)
+	return h.Sum(nil)
+}
+
+func (hmacPRF) NewKeyedPRF(key []byte) kdf.KeyedPRF {
+	return kdf.NewKeyedHMAC(New, key)
+}
+
+// NewHMACPRF creates a new HMAC-SM3 based PRF.
+func NewHMACPRF() kdf.PRF {
+	return hmacPRF{}
+}
+
+func init() {
+	kdf.RegisterPRF("HMAC-SM3", NewHMACPRF)
+}
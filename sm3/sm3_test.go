@@ -0,0 +1,101 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package sm3_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/sm3"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type sm3Suite struct{}
+
+var _ = Suite(&sm3Suite{})
+
+func (s *sm3Suite) testDigest(c *C, data []byte, expected string) {
+	h := New()
+	h.Write(data)
+	c.Check(h.Sum(nil), DeepEquals, decodeHexString(c, expected))
+}
+
+// The following tests correspond to the examples in GB/T 32905-2016.
+
+func (s *sm3Suite) TestDigestExample1(c *C) {
+	s.testDigest(c, []byte("abc"), "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0")
+}
+
+func (s *sm3Suite) TestDigestExample2(c *C) {
+	s.testDigest(c, bytes.Repeat([]byte("abcd"), 16), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732")
+}
+
+func (s *sm3Suite) TestDigestIncremental(c *C) {
+	h := New()
+	for _, b := range bytes.Repeat([]byte("abcd"), 16) {
+		h.Write([]byte{b})
+	}
+	c.Check(h.Sum(nil), DeepEquals, decodeHexString(c, "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"))
+
+	h.Reset()
+	h.Write([]byte("ab"))
+	c.Check(h.Sum(nil), HasLen, Size)
+	h.Write([]byte("c"))
+	c.Check(h.Sum(nil), DeepEquals, decodeHexString(c, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"))
+}
+
+// The following tests were generated with OpenSSL.
+
+var sm3TestKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func (s *sm3Suite) TestHMAC(c *C) {
+	prf := NewHMACPRF()
+	c.Check(prf.Len(), Equals, uint32(32))
+	c.Check(prf.Run(decodeHexString(c, sm3TestKey), []byte("abc")), DeepEquals, decodeHexString(c, "a8f95cf26f204957e7ca73c9602a25dda35f168b28103b51dfc968c810416b63"))
+}
+
+func (s *sm3Suite) TestKeyedHMAC(c *C) {
+	prf := NewHMACPRF()
+	for _, key := range [][]byte{decodeHexString(c, sm3TestKey), bytes.Repeat([]byte{0xaa}, 100)} {
+		k := prf.(kdf.PRFKeyer).NewKeyedPRF(key)
+		c.Check(k.Run([]byte("abc")), DeepEquals, prf.Run(key, []byte("abc")))
+		c.Check(k.Run([]byte("def")), DeepEquals, prf.Run(key, []byte("def")))
+	}
+}
+
+func (s *sm3Suite) TestCounterMode(c *C) {
+	c.Check(kdf.CounterModeKey(NewHMACPRF(), decodeHexString(c, sm3TestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "a85d4c0aab6c0e8e68929b513ad3662b7ce85e2d27bc5611af57dce4b0f707d578c0f651eb0c4e47b21e18f38855a55464f6999a2be722fb16edf1465899098948e2e009e464c38cfc4d72fe0a772cc4"))
+}
+
+func (s *sm3Suite) TestFeedbackMode(c *C) {
+	c.Check(kdf.FeedbackModeKey(NewHMACPRF(), decodeHexString(c, sm3TestKey), []byte("label"), []byte("context"), decodeHexString(c, sm3TestKey), 640, true), DeepEquals,
+		decodeHexString(c, "4aa39a7bf49ce86abd41b959fc6a520d032e0e645adcf63db85845b7875d8a35bdb898ca5664eeff0dd093c2efa09a30bd8ad35772f1f99b27c90051b6ff4535192c6e56c2e7d9af8338833ae1c45bab"))
+}
+
+func (s *sm3Suite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("HMAC-SM3")
+	c.Assert(err, IsNil)
+	c.Check(prf, DeepEquals, NewHMACPRF())
+}
+
+func (s *sm3Suite) TestKeyInfo(c *C) {
+	info := NewHMACPRF().(kdf.PRFKeyInfo)
+	c.Check(info.BlockSize(), Equals, 64)
+	c.Check(info.KeySize(), Equals, 32)
+	c.Check(info.CheckKeyLength(14), IsNil)
+	c.Check(info.CheckKeyLength(8), ErrorMatches, "key length 8 is shorter than the minimum of 14 bytes")
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package sm4_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/sm4"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type sm4Suite struct{}
+
+var _ = Suite(&sm4Suite{})
+
+// The following tests correspond to the examples in GB/T 32907-2016.
+
+func (s *sm4Suite) TestExample1(c *C) {
+	key := decodeHexString(c, "0123456789abcdeffedcba9876543210")
+	b, err := NewCipher(key)
+	c.Assert(err, IsNil)
+
+	ciphertext := make([]byte, BlockSize)
+	b.Encrypt(ciphertext, key)
+	c.Check(ciphertext, DeepEquals, decodeHexString(c, "681edf34d206965e86b3e94f536e4246"))
+
+	plaintext := make([]byte, BlockSize)
+	b.Decrypt(plaintext, ciphertext)
+	c.Check(plaintext, DeepEquals, key)
+}
+
+func (s *sm4Suite) TestExample2(c *C) {
+	key := decodeHexString(c, "0123456789abcdeffedcba9876543210")
+	b, err := NewCipher(key)
+	c.Assert(err, IsNil)
+
+	x := make([]byte, BlockSize)
+	copy(x, key)
+	for i := 0; i < 1000000; i++ {
+		b.Encrypt(x, x)
+	}
+	c.Check(x, DeepEquals, decodeHexString(c, "595298c7c6fd271f0402f804c33d3f66"))
+}
+
+func (s *sm4Suite) TestInvalidKeySize(c *C) {
+	_, err := NewCipher(make([]byte, 24))
+	c.Check(err, ErrorMatches, "invalid SM4 key size 24")
+}
+
+// The following CMAC-SM4 tests were generated with OpenSSL.
+
+const sm4TestKey = "000102030405060708090a0b0c0d0e0f"
+
+func (s *sm4Suite) testCMAC(c *C, msgLen int, expected string) {
+	prf := NewCMACPRF()
+	c.Check(prf.Len(), Equals, uint32(16))
+	msg := make([]byte, msgLen)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	c.Check(prf.Run(decodeHexString(c, sm4TestKey), msg), DeepEquals, decodeHexString(c, expected))
+}
+
+func (s *sm4Suite) TestCMACEmpty(c *C) {
+	s.testCMAC(c, 0, "4dcf78c73b13a3b9494de1152e66e9ef")
+}
+
+func (s *sm4Suite) TestCMACPartialBlock(c *C) {
+	s.testCMAC(c, 40, "266cb683c5b07fa69cfcd177e55a6dbb")
+}
+
+func (s *sm4Suite) TestCMACFourBlocks(c *C) {
+	s.testCMAC(c, 64, "8708196c1909ffea1678f3df05f8d4a3")
+}
+
+func (s *sm4Suite) TestCMACCounterMode(c *C) {
+	c.Check(kdf.CounterModeKey(NewCMACPRF(), decodeHexString(c, sm4TestKey), []byte("label"), []byte("context"), 320), DeepEquals,
+		decodeHexString(c, "a7de8f051b57c9407f79a1f71c279a24f5bb02b6208b0316b16b7d6ce00e537d83efcbf91fbb1552"))
+}
+
+func (s *sm4Suite) TestCMACInvalidKeyLength(c *C) {
+	c.Check(func() { NewCMACPRF().Run(make([]byte, 32), nil) }, PanicMatches, `invalid key length 32 for CMAC-SM4 \(expected 16 bytes\)`)
+}
+
+func (s *sm4Suite) TestCMACString(c *C) {
+	c.Check(NewCMACPRF().(interface{ String() string }).String(), Equals, "CMAC-SM4")
+}
+
+func (s *sm4Suite) TestCMACKeyed(c *C) {
+	key := decodeHexString(c, sm4TestKey)
+	keyed := NewCMACPRF().(kdf.PRFKeyer).NewKeyedPRF(key)
+	c.Check(keyed.Run([]byte("foo")), DeepEquals, NewCMACPRF().Run(key, []byte("foo")))
+}
+
+func (s *sm4Suite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("CMAC-SM4")
+	c.Assert(err, IsNil)
+	c.Check(prf.(interface{ String() string }).String(), Equals, "CMAC-SM4")
+	c.Check(prf.Len(), Equals, uint32(16))
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// SplitKey splits the supplied key material in to consecutive, non-overlapping
+// keys of the specified sizes in bytes. Each returned key has a capacity equal to
+// its length, so appending to one key never modifies another. An error is
+// returned if the sizes don't add up to the length of the key material.
+func SplitKey(key []byte, sizes ...int) ([][]byte, error) {
+	var total int
+	for _, n := range sizes {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid key size %d", n)
+		}
+		total += n
+	}
+	if total != len(key) {
+		return nil, fmt.Errorf("key sizes add up to %d bytes, but %d bytes were supplied", total, len(key))
+	}
+
+	keys := make([][]byte, 0, len(sizes))
+	for _, n := range sizes {
+		keys = append(keys, key[:n:n])
+		key = key[n:]
+	}
+	return keys, nil
+}
+
+// DeriveKeys derives a single block of key material in the same way as Derive,
+// and splits it in to consecutive keys of the specified sizes in bytes, eg, for
+// deriving an encryption key, a MAC key and an IV from a single derivation. The
+// length of the derivation is the sum of the sizes, and the BitLength field of
+// the supplied parameters is ignored.
+func DeriveKeys(params *Params, sizes ...int) ([][]byte, error) {
+	var total uint64
+	for _, n := range sizes {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid key size %d", n)
+		}
+		total += uint64(n)
+		if total*8 > math.MaxUint32 {
+			return nil, newError(ErrOutputTooLong, "total key size is too large")
+		}
+	}
+
+	p := *params
+	p.BitLength = uint32(total * 8)
+	key, err := Derive(&p)
+	if err != nil {
+		return nil, err
+	}
+	return SplitKey(key, sizes...)
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type splitSuite struct{}
+
+var _ = Suite(&splitSuite{})
+
+func (s *splitSuite) TestSplitKey(c *C) {
+	key := []byte("0123456789abcdef")
+	keys, err := SplitKey(key, 8, 0, 6, 2)
+	c.Assert(err, IsNil)
+	c.Check(keys, DeepEquals, [][]byte{[]byte("01234567"), []byte{}, []byte("89abcd"), []byte("ef")})
+
+	for _, k := range keys {
+		c.Check(cap(k), Equals, len(k))
+	}
+	keys[0] = append(keys[0], 'x')
+	c.Check(key, DeepEquals, []byte("0123456789abcdef"))
+}
+
+func (s *splitSuite) TestSplitKeyMismatch(c *C) {
+	_, err := SplitKey(make([]byte, 16), 8, 4)
+	c.Check(err, ErrorMatches, "key sizes add up to 12 bytes, but 16 bytes were supplied")
+}
+
+func (s *splitSuite) TestSplitKeyNegative(c *C) {
+	_, err := SplitKey(make([]byte, 16), 20, -4)
+	c.Check(err, ErrorMatches, "invalid key size -4")
+}
+
+func (s *splitSuite) TestDeriveKeys(c *C) {
+	params := testParams()
+
+	keys, err := DeriveKeys(params, 32, 32, 12)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 3)
+
+	expected := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 76*8)
+	c.Check(keys[0], DeepEquals, expected[:32])
+	c.Check(keys[1], DeepEquals, expected[32:64])
+	c.Check(keys[2], DeepEquals, expected[64:])
+	c.Check(params.BitLength, Equals, uint32(256))
+}
+
+func (s *splitSuite) TestDeriveKeysInvalid(c *C) {
+	_, err := DeriveKeys(&Params{PRF: NewHMACPRF(crypto.SHA256), Key: []byte("1234567890123456")}, 32)
+	c.Check(err, ErrorMatches, "counter mode requires a counter")
+
+	_, err = DeriveKeys(&Params{}, 32, -1)
+	c.Check(err, ErrorMatches, "invalid key size -1")
+
+	_, err = DeriveKeys(&Params{}, 1<<28, 1<<28)
+	c.Check(err, ErrorMatches, "total key size is too large")
+}
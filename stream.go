@@ -0,0 +1,190 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// PRFHasher is implemented by PRFs that can consume their input incrementally.
+// It is used by ModeKeyFromReader to avoid buffering the fixed input data.
+type PRFHasher interface {
+	// NewHash returns a hash.Hash that computes this PRF with the supplied
+	// key. Calling Sum on it returns the same value as Run does for the
+	// data written to it.
+	NewHash(key []byte) hash.Hash
+}
+
+// fixedDataReader is an io.ReadSeeker that concatenates a sequence of
+// io.ReadSeekers with known sizes.
+type fixedDataReader struct {
+	parts []io.ReadSeeker
+	sizes []int64
+	off   int64
+	size  int64
+}
+
+func (r *fixedDataReader) Read(p []byte) (n int, err error) {
+	start := int64(0)
+	for i, part := range r.parts {
+		end := start + r.sizes[i]
+		if r.off >= end {
+			start = end
+			continue
+		}
+		if _, err := part.Seek(r.off-start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if int64(len(p)) > end-r.off {
+			p = p[:end-r.off]
+		}
+		n, err = part.Read(p)
+		r.off += int64(n)
+		if err == io.EOF {
+			if r.off < end {
+				return n, io.ErrUnexpectedEOF
+			}
+			err = nil
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+func (r *fixedDataReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// NewFixedDataReader returns an io.ReadSeeker that produces the fixed input data
+// used by CounterModeKey, FeedbackModeKey and PipelineModeKey, ie,
+// Label || 0x00 || Context || [L]2, where the context is read from the supplied
+// reader rather than being held in memory. This is intended to be used with
+// ModeKeyFromReader in order to bind a derivation to a large context.
+func NewFixedDataReader(label []byte, context io.ReadSeeker, bitLength uint32) (io.ReadSeeker, error) {
+	contextSize, err := context.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := append(append([]byte{}, label...), 0x00)
+	suffix := binary.BigEndian.AppendUint32(nil, bitLength)
+
+	return &fixedDataReader{
+		parts: []io.ReadSeeker{bytes.NewReader(prefix), context, bytes.NewReader(suffix)},
+		sizes: []int64{int64(len(prefix)), contextSize, int64(len(suffix))},
+		size:  int64(len(prefix)) + contextSize + int64(len(suffix))}, nil
+}
+
+// readerPRF runs a PRF on inputs that consist of a prefix followed by the
+// contents of a reader.
+type readerPRF struct {
+	h   hash.Hash
+	r   io.ReadSeeker
+	buf []byte
+}
+
+func (p *readerPRF) run(prefix []byte) ([]byte, error) {
+	if _, err := p.r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	p.h.Reset()
+	p.h.Write(prefix)
+	if _, err := io.CopyBuffer(p.h, p.r, p.buf); err != nil {
+		return nil, err
+	}
+	return p.h.Sum(nil), nil
+}
+
+// ModeKeyFromReader derives a key of the specified length using the supplied
+// iteration mode, PRF and secret key, in the same way as CounterModeKey,
+// FeedbackModeKey or PipelineModeKey, but with the fixed input data read from
+// the supplied reader rather than being held in memory. A reader for the fixed
+// input data can be obtained with NewFixedDataReader. The reader is rewound and
+// read once for each PRF invocation, so it must return the same data each time.
+//
+// The iv argument is only used in feedback mode, and useCounter must be true in
+// counter mode. If the PRF doesn't implement PRFHasher, the fixed input data is
+// read in to memory once before the derivation.
+//
+// An error is returned if the mode is invalid or if reading from fixedData fails.
+func ModeKeyFromReader(mode Mode, prf PRF, key []byte, fixedData io.ReadSeeker, iv []byte, bitLength uint32, useCounter bool) ([]byte, error) {
+	switch mode {
+	case CounterMode:
+		if !useCounter {
+			return nil, errors.New("counter mode requires a counter")
+		}
+	case FeedbackMode, PipelineMode:
+	default:
+		return nil, newError(ErrInvalidMode, "invalid mode %d", mode)
+	}
+
+	hasher, ok := prf.(PRFHasher)
+	if !ok {
+		if _, err := fixedData.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		fixed, err := io.ReadAll(fixedData)
+		if err != nil {
+			return nil, err
+		}
+		switch mode {
+		case CounterMode:
+			return counterModeKeyInternal(prf, key, fixed, bitLength), nil
+		case FeedbackMode:
+			return feedbackModeKeyInternal(prf, key, fixed, iv, bitLength, useCounter), nil
+		default:
+			return pipelineModeKeyInternal(prf, key, fixed, bitLength, useCounter), nil
+		}
+	}
+
+	p := &readerPRF{h: hasher.NewHash(key), r: fixedData, buf: make([]byte, 32*1024)}
+
+	var chain []byte
+	switch mode {
+	case FeedbackMode:
+		chain = iv
+	case PipelineMode:
+		var err error
+		if chain, err = p.run(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var prefix []byte
+	return commonKDF(prf, bitLength, func(i uint32) ([]byte, error) {
+		if mode == PipelineMode && i > 1 {
+			chain = prf.Run(key, chain)
+		}
+		prefix = append(prefix[:0], chain...)
+		if useCounter {
+			prefix = binary.BigEndian.AppendUint32(prefix, i)
+		}
+		k, err := p.run(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if mode == FeedbackMode {
+			chain = k
+		}
+		return k, nil
+	})
+}
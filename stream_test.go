@@ -0,0 +1,120 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"io"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type streamSuite struct{}
+
+var _ = Suite(&streamSuite{})
+
+func (s *streamSuite) TestFixedDataReader(c *C) {
+	r, err := NewFixedDataReader([]byte("foo"), bytes.NewReader([]byte("bar")), 256)
+	c.Assert(err, IsNil)
+
+	data, err := io.ReadAll(r)
+	c.Check(err, IsNil)
+	c.Check(data, DeepEquals, FixedBytes([]byte("foo"), []byte("bar"), 256))
+
+	n, err := r.Seek(-6, io.SeekEnd)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, int64(5))
+	data, err = io.ReadAll(r)
+	c.Check(err, IsNil)
+	c.Check(data, DeepEquals, FixedBytes([]byte("foo"), []byte("bar"), 256)[5:])
+}
+
+func (s *streamSuite) TestFixedDataReaderNegativeSeek(c *C) {
+	r, err := NewFixedDataReader([]byte("foo"), bytes.NewReader([]byte("bar")), 256)
+	c.Assert(err, IsNil)
+
+	_, err = r.Seek(-1, io.SeekStart)
+	c.Check(err, ErrorMatches, "negative position")
+}
+
+func (s *streamSuite) testModeKeyFromReader(c *C, mode Mode, prf PRF, key []byte, useCounter bool, expected []byte) {
+	context := bytes.Repeat([]byte("context"), 10000)
+	r, err := NewFixedDataReader([]byte("foo"), bytes.NewReader(context), 1000)
+	c.Assert(err, IsNil)
+
+	var iv []byte
+	if mode == FeedbackMode {
+		iv = make([]byte, prf.Len())
+	}
+	k, err := ModeKeyFromReader(mode, prf, key, r, iv, 1000, useCounter)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, expected)
+}
+
+func (s *streamSuite) TestCounterMode(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	context := bytes.Repeat([]byte("context"), 10000)
+	s.testModeKeyFromReader(c, CounterMode, prf, key, true, CounterModeKey(prf, key, []byte("foo"), context, 1000))
+}
+
+func (s *streamSuite) TestFeedbackMode(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	context := bytes.Repeat([]byte("context"), 10000)
+	s.testModeKeyFromReader(c, FeedbackMode, prf, key, true, FeedbackModeKey(prf, key, []byte("foo"), context, make([]byte, 32), 1000, true))
+}
+
+func (s *streamSuite) TestFeedbackModeNoCounter(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	context := bytes.Repeat([]byte("context"), 10000)
+	s.testModeKeyFromReader(c, FeedbackMode, prf, key, false, FeedbackModeKey(prf, key, []byte("foo"), context, make([]byte, 32), 1000, false))
+}
+
+func (s *streamSuite) TestPipelineMode(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	key := decodeHexString(c, hmacTestKey)
+	context := bytes.Repeat([]byte("context"), 10000)
+	s.testModeKeyFromReader(c, PipelineMode, prf, key, true, PipelineModeKey(prf, key, []byte("foo"), context, 1000, true))
+}
+
+func (s *streamSuite) TestCounterModeCMAC(c *C) {
+	prf := NewCMACAESPRF(16)
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f")
+	context := bytes.Repeat([]byte("context"), 10000)
+	s.testModeKeyFromReader(c, CounterMode, prf, key, true, CounterModeKey(prf, key, []byte("foo"), context, 1000))
+}
+
+func (s *streamSuite) TestCounterModeNoHasher(c *C) {
+	prf := NewKMACPRF(KMAC128, nil, 0)
+	key := decodeHexString(c, hmacTestKey)
+	context := bytes.Repeat([]byte("context"), 10000)
+	s.testModeKeyFromReader(c, CounterMode, prf, key, true, CounterModeKey(prf, key, []byte("foo"), context, 1000))
+}
+
+func (s *streamSuite) TestCounterModeRequiresCounter(c *C) {
+	_, err := ModeKeyFromReader(CounterMode, NewHMACPRF(crypto.SHA256), nil, bytes.NewReader(nil), nil, 256, false)
+	c.Check(err, ErrorMatches, "counter mode requires a counter")
+}
+
+func (s *streamSuite) TestInvalidMode(c *C) {
+	_, err := ModeKeyFromReader(Mode(5), NewHMACPRF(crypto.SHA256), nil, bytes.NewReader(nil), nil, 256, true)
+	c.Check(err, ErrorMatches, "invalid mode 5")
+}
+
+type errorReadSeeker struct{}
+
+func (errorReadSeeker) Read([]byte) (int, error)       { return 0, errors.New("read error") }
+func (errorReadSeeker) Seek(int64, int) (int64, error) { return 0, nil }
+
+func (s *streamSuite) TestReadError(c *C) {
+	_, err := ModeKeyFromReader(CounterMode, NewHMACPRF(crypto.SHA256), nil, errorReadSeeker{}, nil, 256, true)
+	c.Check(err, ErrorMatches, "read error")
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package streebog_test
+
+import (
+	"encoding/hex"
+	"hash"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/streebog"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type streebogSuite struct{}
+
+var _ = Suite(&streebogSuite{})
+
+func testMessage(n int) []byte {
+	m := make([]byte, n)
+	for i := range m {
+		m[i] = byte(i)
+	}
+	return m
+}
+
+func (s *streebogSuite) testDigest(c *C, h hash.Hash, data []byte, expected string) {
+	h.Write(data)
+	c.Check(h.Sum(nil), DeepEquals, decodeHexString(c, expected))
+}
+
+// The following tests correspond to example 1 in RFC 6986.
+
+func (s *streebogSuite) TestDigest512Example1(c *C) {
+	s.testDigest(c, New512(), []byte("012345678901234567890123456789012345678901234567890123456789012"),
+		"1b54d01a4af5b9d5cc3d86d68d285462b19abc2475222f35c085122be4ba1ffa00ad30f8767b3a82384c6574f024c311e2a481332b08ef7f41797891c1646f48")
+}
+
+func (s *streebogSuite) TestDigest256Example1(c *C) {
+	s.testDigest(c, New256(), []byte("012345678901234567890123456789012345678901234567890123456789012"),
+		"9d151eefd8590b89daa6ba6cb74af9275dd051026bb149a452fd84e5e57b5500")
+}
+
+// The following tests were generated with the reference implementation in
+// Nettle.
+
+func (s *streebogSuite) TestDigest512Empty(c *C) {
+	s.testDigest(c, New512(), nil,
+		"8e945da209aa869f0455928529bcae4679e9873ab707b55315f56ceb98bef0a7362f715528356ee83cda5f2aac4c6ad2ba3a715c1bcd81cb8e9f90bf4c1c1a8a")
+}
+
+func (s *streebogSuite) TestDigest512TwoBlocks(c *C) {
+	s.testDigest(c, New512(), testMessage(128),
+		"a8d65e689c89d8cd4616215d14ebfc02993bde3f5c7d7219904d87848ce9249e7ce3525ae605d85a3596457c880f938eead974b91f61203d31665ca6f3a1decc")
+}
+
+func (s *streebogSuite) TestDigest512Incremental(c *C) {
+	h := New512()
+	for _, b := range testMessage(200) {
+		h.Write([]byte{b})
+	}
+	c.Check(h.Sum(nil), DeepEquals, decodeHexString(c, "43946b2e8d58cb727df9affa1fffa19884aec42156f0933138aef821a9a8809ead7d39c061f85734f5e97b52e99d4813b71d04d2f39f838ae7a6bd256d03fa04"))
+}
+
+var streebogTestKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func (s *streebogSuite) TestCounterModeHMAC256(c *C) {
+	prf := NewHMACPRF(32)
+	c.Check(prf.Len(), Equals, uint32(32))
+	c.Check(kdf.CounterModeKey(prf, decodeHexString(c, streebogTestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "4d47d46650789b104a8bd8de58b5c50449819fba837a0c52c6bcc799ecf36411542e502d78bb2ff874b98f270f312e02d75a6e10a9508d796854c8f1b1dfa4f9df4bab28d97bb0caca6b453a82816268"))
+}
+
+func (s *streebogSuite) TestCounterModeHMAC512(c *C) {
+	prf := NewHMACPRF(64)
+	c.Check(prf.Len(), Equals, uint32(64))
+	c.Check(kdf.CounterModeKey(prf, decodeHexString(c, streebogTestKey), []byte("label"), []byte("context"), 640), DeepEquals,
+		decodeHexString(c, "bfc8efacff3f962a9aeaa224e1928fd08f78a2692921a64c661bc742541c8152ed37d9918957120c4c17b478980bc7933a78a33ce4c648a45497f61588d88968e1aa266cb374af6cdf4cc164d862af4c"))
+}
+
+func (s *streebogSuite) TestString(c *C) {
+	c.Check(NewHMACPRF(32).(interface{ String() string }).String(), Equals, "HMAC-Streebog-256")
+	c.Check(NewHMACPRF(64).(interface{ String() string }).String(), Equals, "HMAC-Streebog-512")
+}
+
+func (s *streebogSuite) TestInvalidSize(c *C) {
+	c.Check(func() { NewHMACPRF(48) }, PanicMatches, "invalid Streebog digest size 48")
+}
+
+func (s *streebogSuite) TestRegistered(c *C) {
+	prf, err := kdf.NewPRFByName("HMAC-Streebog-256")
+	c.Assert(err, IsNil)
+	c.Check(prf.Len(), Equals, uint32(32))
+
+	prf, err = kdf.NewPRFByName("HMAC-Streebog-512")
+	c.Assert(err, IsNil)
+	c.Check(prf.Len(), Equals, uint32(64))
+}
+
+func (s *streebogSuite) TestKeyInfo(c *C) {
+	info := NewHMACPRF(64).(kdf.PRFKeyInfo)
+	c.Check(info.BlockSize(), Equals, 64)
+	c.Check(info.KeySize(), Equals, 64)
+	c.Check(info.CheckKeyLength(32), IsNil)
+	c.Check(info.CheckKeyLength(8), ErrorMatches, "key length 8 is shorter than the minimum of 14 bytes")
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Deriver derives keys with a fixed PRF, iteration mode and PRF input layout.
+type Deriver interface {
+	// Derive derives a key of the specified length from the supplied
+	// secret key, label and context.
+	Derive(key, label, context []byte, bitLength uint32) ([]byte, error)
+}
+
+var (
+	suitesMu sync.RWMutex
+	suites   = make(map[string]Deriver)
+)
+
+// RegisterSuite makes a Deriver available by the supplied name, so that it can
+// be obtained with NewSuite. This will panic if a suite is already registered
+// with the same name, or if d is a feedback mode Algorithm, which can't derive
+// keys without an IV.
+func RegisterSuite(name string, d Deriver) {
+	if a, ok := d.(*Algorithm); ok && a.Mode == FeedbackMode {
+		panic(fmt.Sprintf("cannot register suite %q: feedback mode requires an IV", name))
+	}
+
+	suitesMu.Lock()
+	defer suitesMu.Unlock()
+
+	if _, exists := suites[name]; exists {
+		panic(fmt.Sprintf("suite %q already registered", name))
+	}
+	suites[name] = d
+}
+
+// NewSuite returns the Deriver for the suite with the supplied name. The
+// following suites are always available:
+//   - "cng-default": the SP800_108_CTR_HMAC algorithm from Windows CNG, using
+//     HMAC-SHA-256, which is counter mode with a 32-bit counter before the fixed
+//     input data and a 32-bit length field.
+//   - "tpm2-kdfa": KDFa from the TPM 2.0 specification, using HMAC-SHA-256.
+//     The context is the concatenation of contextU and contextV, and a label
+//     that is already terminated with a zero byte is accepted. Keys of any
+//     length are accepted, as they are by the TPM. The length must be a whole
+//     number of bytes, as the TPM truncates partial bytes differently to NIST
+//     SP-800-108.
+//   - "scp03": the key derivation function from GlobalPlatform SCP03, using
+//     CMAC-AES with a key size that matches the length of the supplied key.
+//     The label is the 12-byte label including the derivation constant.
+func NewSuite(name string) (Deriver, error) {
+	suitesMu.RLock()
+	defer suitesMu.RUnlock()
+
+	d, exists := suites[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown suite %q", name)
+	}
+	return d, nil
+}
+
+// RegisteredSuites returns the sorted names of all of the suites that can
+// currently be obtained with NewSuite.
+func RegisteredSuites() []string {
+	suitesMu.RLock()
+	defer suitesMu.RUnlock()
+
+	var names []string
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type tpm2KDFaDeriver struct{}
+
+func (tpm2KDFaDeriver) Derive(key, label, context []byte, bitLength uint32) ([]byte, error) {
+	if bitLength%8 != 0 {
+		return nil, fmt.Errorf("bit length %d is not a whole number of bytes", bitLength)
+	}
+	if n := len(label); n > 0 && label[n-1] == 0 {
+		label = label[:n-1]
+	}
+	// The TPM doesn't impose a minimum key length - session keys are
+	// derived from authorization values that may be short or empty - so
+	// this doesn't apply the key length checks that Params does.
+	return CounterModeKey(NewHMACPRF(crypto.SHA256), key, label, context, bitLength), nil
+}
+
+type scp03Deriver struct{}
+
+func (scp03Deriver) Derive(key, label, context []byte, bitLength uint32) ([]byte, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, newError(ErrInvalidKeyLength, "invalid AES key length %d", len(key))
+	}
+	if bitLength > 0xffff {
+		return nil, newError(ErrOutputTooLong, "length %d overflows the 16-bit length field", bitLength)
+	}
+	// The PRF input is label || 0x00 || [L]16 || [i]8 || context.
+	assembler := &LayoutAssembler{
+		FixedData:             (&FixedDataBuilder{Label: label, LengthWidth: 16}).Build(bitLength),
+		FixedDataAfterCounter: context,
+		CounterLocation:       CounterMiddleFixed,
+		CounterWidth:          8}
+	return ModeKeyWithAssemblerE(CounterMode, NewCMACAESPRF(len(key)), key, assembler, nil, bitLength)
+}
+
+func mustParseAlgorithm(s string) *Algorithm {
+	a, err := ParseAlgorithm(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func init() {
+	RegisterSuite("cng-default", mustParseAlgorithm("SP800-108-CTR-HMAC-SHA-256/R32/L32"))
+	RegisterSuite("tpm2-kdfa", tpm2KDFaDeriver{})
+	RegisterSuite("scp03", scp03Deriver{})
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type suiteSuite struct{}
+
+var _ = Suite(&suiteSuite{})
+
+func (s *suiteSuite) TestRegisteredSuites(c *C) {
+	c.Check(RegisteredSuites(), DeepEquals, []string{"cng-default", "scp03", "tpm2-kdfa"})
+}
+
+func (s *suiteSuite) TestUnknownSuite(c *C) {
+	_, err := NewSuite("foo")
+	c.Check(err, ErrorMatches, `unknown suite "foo"`)
+}
+
+type testSuiteData struct {
+	key       []byte
+	label     []byte
+	context   []byte
+	bitLength uint32
+	expected  []byte
+}
+
+func (s *suiteSuite) testSuite(c *C, name string, data *testSuiteData) {
+	d, err := NewSuite(name)
+	c.Assert(err, IsNil)
+
+	k, err := d.Derive(data.key, data.label, data.context, data.bitLength)
+	c.Check(err, IsNil)
+	c.Check(k, DeepEquals, data.expected)
+}
+
+// The cng-default suite uses the same PRF input layout as KDF-HMAC-SHA2 from
+// RFC 8009, so these are the aes128-cts-hmac-sha256-128 key derivation test
+// vectors from section A of that RFC, for key usage 2.
+
+func (s *suiteSuite) TestCNGDefaultRFC8009Kc(c *C) {
+	s.testSuite(c, "cng-default", &testSuiteData{
+		key:       decodeHexString(c, "3705d96080c17728a0e800eab6e0d23c"),
+		label:     decodeHexString(c, "0000000299"),
+		bitLength: 128,
+		expected:  decodeHexString(c, "b31a018a48f54776f403e9a396325dc3")})
+}
+
+func (s *suiteSuite) TestCNGDefaultRFC8009Ke(c *C) {
+	s.testSuite(c, "cng-default", &testSuiteData{
+		key:       decodeHexString(c, "3705d96080c17728a0e800eab6e0d23c"),
+		label:     decodeHexString(c, "00000002aa"),
+		bitLength: 128,
+		expected:  decodeHexString(c, "9b197dd1e8c5609d6e67c3e37c62c72e")})
+}
+
+func (s *suiteSuite) TestCNGDefaultRFC8009Ki(c *C) {
+	s.testSuite(c, "cng-default", &testSuiteData{
+		key:       decodeHexString(c, "3705d96080c17728a0e800eab6e0d23c"),
+		label:     decodeHexString(c, "0000000255"),
+		bitLength: 128,
+		expected:  decodeHexString(c, "9fda0e56ab2d85e1569a688696c26a6c")})
+}
+
+// These are the KDFa test vectors for SHA-256 from github.com/google/go-tpm,
+// where the context is contextU || contextV.
+
+func (s *suiteSuite) TestTPM2KDFa1(c *C) {
+	s.testSuite(c, "tpm2-kdfa", &testSuiteData{
+		key:       []byte("yolo\x00"),
+		label:     []byte("IDENTITY"),
+		context:   []byte("kek\x00yoyo\x00"),
+		bitLength: 128,
+		expected:  decodeHexString(c, "d2d72cc7a8a5eb09e8c79012e2da9f22")})
+}
+
+func (s *suiteSuite) TestTPM2KDFa2(c *C) {
+	s.testSuite(c, "tpm2-kdfa", &testSuiteData{
+		key:       []byte("ca\x00"),
+		label:     []byte("IDENTITY"),
+		context:   []byte("abc\x00"),
+		bitLength: 1024,
+		expected: decodeHexString(c, "1aae7151ac1a5690eda7dcabd56800c11c56a3810ba059826fe4776348d6ae8e"+
+			"5d5d18c7ccf8373f7b942ada8b912b12da56fb37f64b935872841ec07d38e1fb"+
+			"8e7ec86efcbfb444756bc8863f858d2690a621c9afb983cd77e7a1048ae1a759"+
+			"8ac895323d44c10227af0a00144cab55111075dc6b72ad6eb163c7458b878e8c")})
+}
+
+func (s *suiteSuite) TestTPM2KDFaTerminatedLabel(c *C) {
+	d, err := NewSuite("tpm2-kdfa")
+	c.Assert(err, IsNil)
+
+	key := decodeHexString(c, hmacTestKey)
+	key1, err := d.Derive(key, []byte("STORAGE\x00"), nil, 256)
+	c.Check(err, IsNil)
+	key2, err := d.Derive(key, []byte("STORAGE"), nil, 256)
+	c.Check(err, IsNil)
+	c.Check(key1, DeepEquals, key2)
+}
+
+func (s *suiteSuite) TestTPM2KDFaPartialByte(c *C) {
+	d, err := NewSuite("tpm2-kdfa")
+	c.Assert(err, IsNil)
+
+	_, err = d.Derive(decodeHexString(c, hmacTestKey), []byte("STORAGE"), nil, 252)
+	c.Check(err, ErrorMatches, "bit length 252 is not a whole number of bytes")
+}
+
+func (s *suiteSuite) TestSCP03(c *C) {
+	d, err := NewSuite("scp03")
+	c.Assert(err, IsNil)
+
+	key := decodeHexString(c, "404142434445464748494a4b4c4d4e4f")
+	label := decodeHexString(c, "000000000000000000000004")
+	context := decodeHexString(c, "00112233445566778899aabbccddeeff")
+
+	k, err := d.Derive(key, label, context, 256)
+	c.Check(err, IsNil)
+
+	prf := NewCMACAESPRF(16)
+	var expected []byte
+	for _, i := range []byte{1, 2} {
+		x := append(append([]byte{}, label...), 0x00, 0x01, 0x00, i)
+		expected = append(expected, prf.Run(key, append(x, context...))...)
+	}
+	c.Check(k, DeepEquals, expected)
+}
+
+func (s *suiteSuite) TestSCP03InvalidKeyLength(c *C) {
+	d, err := NewSuite("scp03")
+	c.Assert(err, IsNil)
+
+	_, err = d.Derive(make([]byte, 20), nil, nil, 128)
+	c.Check(err, ErrorMatches, "invalid AES key length 20")
+}
+
+func (s *suiteSuite) TestSCP03LengthOverflow(c *C) {
+	d, err := NewSuite("scp03")
+	c.Assert(err, IsNil)
+
+	_, err = d.Derive(make([]byte, 16), nil, nil, 0x10000)
+	c.Check(err, ErrorMatches, "length 65536 overflows the 16-bit length field")
+}
+
+func (s *suiteSuite) TestRegisterSuiteDuplicate(c *C) {
+	c.Check(func() { RegisterSuite("scp03", &Algorithm{}) }, PanicMatches, `suite "scp03" already registered`)
+}
+
+func (s *suiteSuite) TestRegisterSuiteFeedback(c *C) {
+	a, err := ParseAlgorithm("SP800-108-FB-HMAC-SHA-256/R32/L32")
+	c.Assert(err, IsNil)
+	c.Check(func() { RegisterSuite("fb", a) }, PanicMatches, `cannot register suite "fb": feedback mode requires an IV`)
+	c.Check(RegisteredSuites(), DeepEquals, []string{"cng-default", "scp03", "tpm2-kdfa"})
+}
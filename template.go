@@ -0,0 +1,217 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"hash"
+	"sync"
+	"time"
+)
+
+// templateBlock contains the parts of the PRF input for a single block of a
+// Template that don't depend on the context or the iteration variable. The
+// input for the block is:
+//
+//	pre || chain || mid || Context || post
+//
+// where chain is the iteration variable in feedback and double-pipeline mode.
+type templateBlock struct {
+	pre, mid, post []byte
+}
+
+// templateState is the state used by a single derivation from a Template,
+// which is retained in a pool for reuse by subsequent derivations.
+type templateState struct {
+	keyed KeyedPRF
+	h     hash.Hash // The keyed hash, if the keyed PRF implements keyedPRFHasher
+	input []byte
+	block []byte
+	chain []byte
+}
+
+// Template is a key derivation described by a set of parameters that has been
+// prepared in advance, so that keys can be derived for many different contexts
+// as cheaply as possible. The encoding of the fixed input data, the iteration
+// counter and the length field, and the number of iterations are all computed
+// once when the template is created, and the key derivation key is bound to the
+// PRF once, so that a derivation only has to invoke the PRF.
+//
+// A Template is safe for concurrent use by multiple goroutines.
+type Template struct {
+	prf       PRF
+	mode      Mode
+	iv        []byte
+	prefix    []byte // The part of the fixed input data before the context
+	suffix    []byte // The part of the fixed input data after the context
+	blocks    []templateBlock
+	bitLength uint32
+	pool      sync.Pool
+}
+
+// NewTemplate creates a new Template for the supplied parameters. The Context
+// field of the parameters is ignored, and is instead supplied to each
+// derivation. An error is returned if the parameters are invalid.
+func NewTemplate(params *Params) (*Template, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	key, _ := ConditionKey(params.PRF, params.Key, params.KeyConditioning)
+	key = append([]byte(nil), key...)
+
+	b := params.fixedDataBuilder()
+	b.Context = nil
+	fixed := b.Build(params.BitLength)
+	split := len(fixed)
+	if params.LengthLocation == LengthAfterContext {
+		width := params.LengthWidth
+		if width == 0 {
+			width = 32
+		}
+		split -= width / 8
+	}
+
+	t := &Template{
+		prf:       params.PRF,
+		mode:      params.Mode,
+		iv:        append([]byte(nil), params.IV...),
+		prefix:    fixed[:split],
+		suffix:    fixed[split:],
+		bitLength: params.BitLength}
+
+	// Encode the counter for every block, and lay out the PRF input
+	// around the context and iteration variable.
+	assembler := params.assembler()
+	n := numBlocks(params.PRF.Len(), params.BitLength)
+	t.blocks = make([]templateBlock, n)
+	for i := range t.blocks {
+		var ctr []byte
+		if !assembler.OmitCounter {
+			ctr = assembler.encodeCounter(uint64(i) + 1)
+		}
+
+		blk := &t.blocks[i]
+		switch params.CounterLocation {
+		case CounterBeforeFixed:
+			blk.mid = append(ctr, t.prefix...)
+			blk.post = t.suffix
+		case CounterAfterFixed:
+			blk.mid = t.prefix
+			blk.post = append(append([]byte(nil), t.suffix...), ctr...)
+		case CounterBeforeIter:
+			blk.pre = ctr
+			blk.mid = t.prefix
+			blk.post = t.suffix
+		}
+	}
+
+	t.pool.New = func() any {
+		s := &templateState{keyed: newKeyedPRF(t.prf, key)}
+		if h, ok := s.keyed.(keyedPRFHasher); ok {
+			s.h = h.keyedHash()
+		}
+		return s
+	}
+
+	return t, nil
+}
+
+// Len returns the length in bytes of the keys derived from this template, which
+// is the length in bits rounded up to a whole number of bytes.
+func (t *Template) Len() int {
+	return int((uint64(t.bitLength) + 7) / 8)
+}
+
+// run invokes the keyed PRF for the input formed by the supplied segments, and
+// returns the output, which is only valid until the next call.
+func (s *templateState) run(segments ...[]byte) []byte {
+	if s.h != nil {
+		s.h.Reset()
+		for _, seg := range segments {
+			s.h.Write(seg)
+		}
+		s.block = s.h.Sum(s.block[:0])
+		return s.block
+	}
+
+	s.input = s.input[:0]
+	for _, seg := range segments {
+		s.input = append(s.input, seg...)
+	}
+	if a, ok := s.keyed.(KeyedPRFAppender); ok {
+		s.block = a.AppendRun(s.block[:0], s.input)
+		return s.block
+	}
+	return s.keyed.Run(s.input)
+}
+
+// Derive derives a key for the supplied context.
+func (t *Template) Derive(context []byte) []byte {
+	if t.bitLength == 0 {
+		return nil
+	}
+	out := make([]byte, t.Len())
+	t.derive(out, context)
+	return out
+}
+
+// DeriveInto derives a key for the supplied context in to the supplied buffer,
+// and returns the number of bytes written, which is the value returned from
+// Len. An error is returned if the buffer is too small.
+func (t *Template) DeriveInto(dst []byte, context []byte) (int, error) {
+	n := t.Len()
+	if len(dst) < n {
+		return 0, newError(ErrBufferTooSmall, "buffer of %d bytes is too small for a %d byte key", len(dst), n)
+	}
+	t.derive(dst[:n], context)
+	return n, nil
+}
+
+func (t *Template) derive(out, context []byte) {
+	m := currentMetrics()
+	var start time.Time
+	if m != nil {
+		start = time.Now()
+	}
+
+	s := t.pool.Get().(*templateState)
+	defer t.pool.Put(s)
+
+	var chain []byte
+	switch t.mode {
+	case FeedbackMode:
+		chain = t.iv
+	case PipelineMode:
+		s.chain = append(s.chain[:0], s.run(t.prefix, context, t.suffix)...)
+		chain = s.chain
+	}
+
+	off := 0
+	for i := range t.blocks {
+		if t.mode == PipelineMode && i > 0 {
+			s.chain = append(s.chain[:0], s.run(s.chain)...)
+			chain = s.chain
+		}
+
+		blk := &t.blocks[i]
+		k := s.run(blk.pre, chain, blk.mid, context, blk.post)
+		off += copy(out[off:], k)
+
+		if t.mode == FeedbackMode {
+			s.chain = append(s.chain[:0], k...)
+			chain = s.chain
+		}
+	}
+	if t.bitLength%8 != 0 {
+		out[len(out)-1] &= 0xff << (8 - t.bitLength%8)
+	}
+
+	clear(s.block)
+	clear(s.chain)
+
+	if m != nil {
+		m.RecordDerivation(prfName(t.prf), uint32(len(t.blocks)), time.Since(start))
+	}
+}
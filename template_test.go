@@ -0,0 +1,221 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type templateSuite struct{}
+
+var _ = Suite(&templateSuite{})
+
+func (s *templateSuite) testTemplate(c *C, params *Params) {
+	t, err := NewTemplate(params)
+	c.Assert(err, IsNil)
+	c.Check(t.Len(), Equals, int((params.BitLength+7)/8))
+
+	for _, context := range []string{"", "bar", "a much longer context that spans more than a single block of the underlying digest"} {
+		p := *params
+		p.Context = []byte(context)
+		expected, err := Derive(&p)
+		c.Assert(err, IsNil)
+		c.Check(t.Derive([]byte(context)), DeepEquals, expected, Commentf("%q", context))
+	}
+}
+
+func (s *templateSuite) TestModesAndLayouts(c *C) {
+	for _, t := range []struct {
+		prf PRF
+		key []byte
+	}{
+		{prf: NewHMACPRF(crypto.SHA256), key: decodeHexString(c, hmacTestKey)},
+		{prf: NewCMACAESPRF(16), key: decodeHexString(c, "000102030405060708090a0b0c0d0e0f")},
+		{prf: testPRF{NewHMACPRF(crypto.SHA256)}, key: decodeHexString(c, hmacTestKey)},
+	} {
+		prf := t.prf
+		for _, mode := range []Mode{CounterMode, FeedbackMode, PipelineMode} {
+			var iv []byte
+			if mode == FeedbackMode {
+				iv = make([]byte, prf.Len())
+				iv[0] = 1
+			}
+			for _, location := range []CounterLocation{CounterBeforeFixed, CounterAfterFixed, CounterBeforeIter} {
+				for _, length := range []LengthLocation{LengthAfterContext, LengthBeforeLabel, LengthOmitted} {
+					s.testTemplate(c, &Params{
+						PRF:             prf,
+						Mode:            mode,
+						Key:             t.key,
+						Label:           []byte("foo"),
+						IV:              iv,
+						CounterWidth:    16,
+						CounterLocation: location,
+						LengthLocation:  length,
+						BitLength:       1000})
+				}
+			}
+		}
+	}
+}
+
+func (s *templateSuite) TestNoCounter(c *C) {
+	s.testTemplate(c, &Params{
+		PRF:       NewHMACPRF(crypto.SHA256),
+		Mode:      PipelineMode,
+		Key:       decodeHexString(c, hmacTestKey),
+		Label:     []byte("foo"),
+		BitLength: 512})
+}
+
+func (s *templateSuite) TestLengthOptions(c *C) {
+	s.testTemplate(c, &Params{
+		PRF:           NewHMACPRF(crypto.SHA512),
+		Mode:          CounterMode,
+		Key:           decodeHexString(c, hmacTestKey),
+		Label:         []byte("foo"),
+		CounterWidth:  8,
+		LengthWidth:   16,
+		LengthInBytes: true,
+		BitLength:     1001})
+}
+
+func (s *templateSuite) TestSingleBlock(c *C) {
+	s.testTemplate(c, &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         CounterMode,
+		Key:          decodeHexString(c, hmacTestKey),
+		Label:        []byte("foo"),
+		CounterWidth: 32,
+		BitLength:    128})
+}
+
+func (s *templateSuite) TestEmpty(c *C) {
+	t, err := NewTemplate(&Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         CounterMode,
+		Key:          decodeHexString(c, hmacTestKey),
+		CounterWidth: 32})
+	c.Assert(err, IsNil)
+	c.Check(t.Derive([]byte("foo")), IsNil)
+}
+
+func (s *templateSuite) TestCopiesInputs(c *C) {
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         FeedbackMode,
+		Key:          decodeHexString(c, hmacTestKey),
+		Label:        []byte("foo"),
+		IV:           make([]byte, 32),
+		CounterWidth: 32,
+		BitLength:    512}
+	t, err := NewTemplate(params)
+	c.Assert(err, IsNil)
+	expected := t.Derive([]byte("bar"))
+
+	params.Key[0] ^= 0xff
+	params.Label[0] ^= 0xff
+	params.IV[0] ^= 0xff
+	c.Check(t.Derive([]byte("bar")), DeepEquals, expected)
+}
+
+func (s *templateSuite) TestDeriveInto(c *C) {
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         CounterMode,
+		Key:          decodeHexString(c, hmacTestKey),
+		Label:        []byte("foo"),
+		CounterWidth: 32,
+		BitLength:    300}
+	t, err := NewTemplate(params)
+	c.Assert(err, IsNil)
+
+	dst := make([]byte, 40)
+	n, err := t.DeriveInto(dst, []byte("bar"))
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 38)
+	c.Check(dst[:n], DeepEquals, t.Derive([]byte("bar")))
+	c.Check(dst[n:], DeepEquals, make([]byte, 2))
+
+	_, err = t.DeriveInto(make([]byte, 37), []byte("bar"))
+	c.Check(err, ErrorMatches, "buffer of 37 bytes is too small for a 38 byte key")
+	c.Check(errors.Is(err, ErrBufferTooSmall), Equals, true)
+}
+
+func (s *templateSuite) TestInvalidParams(c *C) {
+	_, err := NewTemplate(&Params{PRF: NewHMACPRF(crypto.SHA256), Mode: CounterMode, Key: decodeHexString(c, hmacTestKey)})
+	c.Check(err, ErrorMatches, "counter mode requires a counter")
+}
+
+func (s *templateSuite) TestConcurrent(c *C) {
+	params := &Params{
+		PRF:          NewHMACPRF(crypto.SHA256),
+		Mode:         CounterMode,
+		Key:          decodeHexString(c, hmacTestKey),
+		Label:        []byte("foo"),
+		CounterWidth: 32,
+		BitLength:    512}
+	t, err := NewTemplate(params)
+	c.Assert(err, IsNil)
+
+	const n = 16
+	results := make([][][]byte, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				results[i] = append(results[i], t.Derive([]byte(fmt.Sprintf("%d-%d", i, j))))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		for j, k := range r {
+			c.Check(k, DeepEquals, CounterModeKey(params.PRF, params.Key, params.Label, []byte(fmt.Sprintf("%d-%d", i, j)), 512))
+		}
+	}
+}
+
+func (s *templateSuite) TestDeriveIntoDoesntAllocate(c *C) {
+	if raceEnabled {
+		c.Skip("allocation counts are unreliable with the race detector")
+	}
+
+	for _, prf := range []PRF{NewHMACPRF(crypto.SHA256), NewCMACAESPRF(32)} {
+		for _, mode := range []Mode{CounterMode, FeedbackMode, PipelineMode} {
+			var iv []byte
+			if mode == FeedbackMode {
+				iv = make([]byte, prf.Len())
+			}
+			t, err := NewTemplate(&Params{
+				PRF:          prf,
+				Mode:         mode,
+				Key:          decodeHexString(c, hmacTestKey),
+				Label:        []byte("foo"),
+				IV:           iv,
+				CounterWidth: 32,
+				BitLength:    1000})
+			c.Assert(err, IsNil)
+
+			dst := make([]byte, t.Len())
+			context := []byte("bar")
+			allocs := testing.AllocsPerRun(100, func() {
+				t.DeriveInto(dst, context)
+			})
+			c.Check(allocs, Equals, float64(0), Commentf("%v %v", prf, mode))
+		}
+	}
+}
@@ -15,6 +15,11 @@ import (
 
 var (
 	prfs = map[string]string{
+		"CMAC_AES128": "NewCMACAESPRF(16)",
+		"CMAC_AES192": "NewCMACAESPRF(24)",
+		"CMAC_AES256": "NewCMACAESPRF(32)",
+		"CMAC_TDES2": "NewCMACTDEAPRF(16)",
+		"CMAC_TDES3": "NewCMACTDEAPRF(24)",
 		"HMAC_SHA1": "NewHMACPRF(crypto.SHA1)",
 		"HMAC_SHA224": "NewHMACPRF(crypto.SHA224)",
 		"HMAC_SHA256": "NewHMACPRF(crypto.SHA256)",
@@ -71,6 +76,7 @@ type testCase struct {
 	key string
 	iv string
 	fixed string
+	fixedAfter string
 	expected string
 }
 
@@ -110,8 +116,10 @@ func (p *parser) handleTestCaseParam(tok string) (stateFunc, error) {
 		p.currentTest.key = tok
 	case "IV":
 		p.currentTest.iv = tok
-	case "FixedInputData":
+	case "FixedInputData", "DataBeforeCtrData":
 		p.currentTest.fixed = tok
+	case "DataAfterCtrData":
+		p.currentTest.fixedAfter = tok
 	case "KO":
 		p.currentTest.expected = tok
 	}
@@ -287,13 +295,45 @@ func generateTests(w io.Writer, vectors, ctrLocation, rlen, suiteTpl, testTpl st
 		fmt.Fprintf(w, suiteTpl, suite.prf, newPrf)
 
 		for i, test := range suite.tests {
-			fmt.Fprintf(w, testTpl, suite.prf, i, test.key, test.fixed, test.iv, test.l, test.expected)
+			fmt.Fprintf(w, testTpl, suite.prf, i, test.key, test.fixed, test.iv, test.l, test.expected, test.fixedAfter)
 		}
 	}
 
 	return nil
 }
 
+var counterLocations = map[string]string{
+	"AFTER_FIXED": "CounterAfterFixed",
+	"AFTER_ITER": "CounterAfterIter",
+	"BEFORE_FIXED": "CounterBeforeFixed",
+	"BEFORE_ITER": "CounterBeforeIter",
+	"MIDDLE_FIXED": "CounterMiddleFixed",
+}
+
+// generateLayoutTests generates tests for the suites with the specified counter
+// location and width, using a LayoutAssembler.
+func generateLayoutTests(w io.Writer, vectors, name, mode, ctrLocation string, width int) error {
+	location := counterLocations[ctrLocation]
+	prefix := fmt.Sprintf("%s%s%dBit", name, strings.TrimPrefix(location, "Counter"), width)
+
+	return generateTests(w, vectors, ctrLocation, fmt.Sprintf("%d_BITS", width), `
+
+func (s *kdfSuite) test`+prefix+`%[1]s(c *C, data *testData) {
+	s.test`+mode+`WithLayout(c, %[2]s, data, `+location+`, `+fmt.Sprint(width)+`)
+}`, `
+
+func (s *kdfSuite) Test`+prefix+`%[1]s_%[2]d(c *C) {
+	s.test`+prefix+`%[1]s(c, &testData{
+		key: decodeHexString(c, "%[3]s"),
+		fixed: decodeHexString(c, "%[4]s"),
+		fixedAfter: decodeHexString(c, "%[8]s"),
+		iv: decodeHexString(c, "%[5]s"),
+		bitLength: %[6]s,
+		expected: decodeHexString(c, "%[7]s"),
+	})
+}`)
+}
+
 func run(in io.Reader, out io.Writer) error {
 	if _, err := io.Copy(out, in); err != nil {
 		return fmt.Errorf("cannot copy test prologue: %v", err)
@@ -404,6 +444,41 @@ func (s *kdfSuite) TestPipelineModeNoCounter%[1]s_%[2]d(c *C) {
 	return err
 }
 
+	for _, width := range []int{8, 16, 24, 32} {
+		if err := generateLayoutTests(out, "testdata/KDFCTR_gen.rsp", "CounterMode", "CounterMode", "AFTER_FIXED", width); err != nil {
+			return err
+		}
+		if err := generateLayoutTests(out, "testdata/KDFCTR_gen.rsp", "CounterMode", "CounterMode", "MIDDLE_FIXED", width); err != nil {
+			return err
+		}
+		for _, location := range []string{"BEFORE_ITER", "AFTER_FIXED"} {
+			if err := generateLayoutTests(out, "testdata/FeedbackModeNOzeroiv/KDFFeedback_gen.rsp", "FeedbackModeNoZeroIV", "FeedbackMode", location, width); err != nil {
+				return err
+			}
+			if err := generateLayoutTests(out, "testdata/FeedbackModewzeroiv/KDFFeedback_gen.rsp", "FeedbackModeZeroIV", "FeedbackMode", location, width); err != nil {
+				return err
+			}
+			if err := generateLayoutTests(out, "testdata/PipelineModewithCounter/KDFDblPipeline_gen.rsp", "PipelineMode", "PipelineMode", location, width); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, width := range []int{8, 16, 24} {
+		if err := generateLayoutTests(out, "testdata/KDFCTR_gen.rsp", "CounterMode", "CounterMode", "BEFORE_FIXED", width); err != nil {
+			return err
+		}
+		if err := generateLayoutTests(out, "testdata/FeedbackModeNOzeroiv/KDFFeedback_gen.rsp", "FeedbackModeNoZeroIV", "FeedbackMode", "AFTER_ITER", width); err != nil {
+			return err
+		}
+		if err := generateLayoutTests(out, "testdata/FeedbackModewzeroiv/KDFFeedback_gen.rsp", "FeedbackModeZeroIV", "FeedbackMode", "AFTER_ITER", width); err != nil {
+			return err
+		}
+		if err := generateLayoutTests(out, "testdata/PipelineModewithCounter/KDFDblPipeline_gen.rsp", "PipelineMode", "PipelineMode", "AFTER_ITER", width); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,67 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// TLVType identifies the type of a component of TLV encoded fixed data.
+type TLVType uint8
+
+const (
+	// TLVLabel identifies a component containing the label.
+	TLVLabel TLVType = 1
+
+	// TLVContext identifies a component containing the context.
+	TLVContext TLVType = 2
+
+	// TLVPurpose identifies a component describing the purpose of the derived key.
+	TLVPurpose TLVType = 3
+)
+
+// TLV is a single type-length-value component of fixed data.
+type TLV struct {
+	Type  TLVType
+	Value []byte
+}
+
+const tlvHeaderSize = 5
+
+// BuildTLVFixedData encodes the supplied components in to fixed data that can be
+// used with the *WithFixedData key derivation functions. Each component is encoded
+// as a single type byte, followed by the length of the value as a big-endian 32-bit
+// integer, followed by the value itself. The encoding is unambiguous, so the
+// resulting fixed data can be decoded again with ParseTLVFixedData.
+func BuildTLVFixedData(components []TLV) []byte {
+	var res bytes.Buffer
+	for _, c := range components {
+		res.WriteByte(byte(c.Type))
+		binary.Write(&res, binary.BigEndian, uint32(len(c.Value)))
+		res.Write(c.Value)
+	}
+	return res.Bytes()
+}
+
+// ParseTLVFixedData decodes fixed data created by BuildTLVFixedData.
+func ParseTLVFixedData(data []byte) ([]TLV, error) {
+	var components []TLV
+	for len(data) > 0 {
+		if len(data) < tlvHeaderSize {
+			return nil, errors.New("truncated component header")
+		}
+		t := TLVType(data[0])
+		n := binary.BigEndian.Uint32(data[1:])
+		data = data[tlvHeaderSize:]
+		if uint64(n) > uint64(len(data)) {
+			return nil, errors.New("truncated component value")
+		}
+		components = append(components, TLV{Type: t, Value: data[:n]})
+		data = data[n:]
+	}
+	return components, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type tlvSuite struct{}
+
+var _ = Suite(&tlvSuite{})
+
+func (s *tlvSuite) TestBuild(c *C) {
+	fixed := BuildTLVFixedData([]TLV{
+		{Type: TLVLabel, Value: []byte("encryption")},
+		{Type: TLVContext, Value: []byte{1, 2, 3, 4}},
+		{Type: TLVPurpose, Value: []byte("disk")}})
+	c.Check(fixed, DeepEquals, decodeHexString(c, "010000000a656e6372797074696f6e02000000040102030403000000046469736b"))
+}
+
+func (s *tlvSuite) TestBuildEmptyValue(c *C) {
+	c.Check(BuildTLVFixedData([]TLV{{Type: TLVLabel}}), DeepEquals, []byte{1, 0, 0, 0, 0})
+}
+
+func (s *tlvSuite) TestRoundTrip(c *C) {
+	components := []TLV{
+		{Type: TLVLabel, Value: []byte("foo")},
+		{Type: TLVContext, Value: []byte{}},
+		{Type: TLVPurpose, Value: []byte("bar")},
+		{Type: 0xff, Value: []byte{0, 0, 0}}}
+	decoded, err := ParseTLVFixedData(BuildTLVFixedData(components))
+	c.Check(err, IsNil)
+	c.Check(decoded, DeepEquals, components)
+}
+
+func (s *tlvSuite) TestParseTruncatedHeader(c *C) {
+	_, err := ParseTLVFixedData([]byte{1, 0, 0, 0})
+	c.Check(err, ErrorMatches, "truncated component header")
+}
+
+func (s *tlvSuite) TestParseTruncatedValue(c *C) {
+	_, err := ParseTLVFixedData([]byte{1, 0, 0, 0, 4, 'f', 'o', 'o'})
+	c.Check(err, ErrorMatches, "truncated component value")
+}
+
+func (s *tlvSuite) TestDerive(c *C) {
+	fixed := BuildTLVFixedData([]TLV{
+		{Type: TLVLabel, Value: []byte("encryption")},
+		{Type: TLVContext, Value: []byte{1, 2, 3, 4}},
+		{Type: TLVPurpose, Value: []byte("disk")}})
+	key := decodeHexString(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	c.Check(CounterModeKeyWithFixedData(NewHMACPRF(crypto.SHA256), key, fixed, 320), DeepEquals,
+		decodeHexString(c, "a10176e50b9bbdef0f012a5f04b84881ac831a2e7ab48d38cac743191084416c11ba064f781f4d85"))
+}
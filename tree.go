@@ -0,0 +1,79 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+// keyTreeLabel is the label used to derive each child key in a KeyTree.
+var keyTreeLabel = []byte("SP800-108 key tree")
+
+// KeyTree is a node in a hierarchy of key derivation keys, where the key for
+// each node is derived from its parent's key and a path component. For
+// example, a tree can be used to derive a separate key derivation key for the
+// storage of each tenant with root.Derive("tenants", tenantID, "storage").
+//
+// Each child key is derived in counter mode with a fixed label and a context
+// that encodes the depth of the child and its path component, using the
+// encoding described by ContextBuilder. Because the encoding is canonical and
+// each level is derived from the one above it, distinct paths always produce
+// independent keys. The length of each child key is the natural key size of
+// the PRF if it implements PRFKeyInfo, or its output length otherwise.
+type KeyTree struct {
+	prf  PRF
+	key  []byte
+	path []string
+}
+
+// NewKeyTree creates the root of a key hierarchy from the supplied PRF and root
+// key derivation key. The key is copied. An error is returned if the key is not
+// acceptable to CheckKeyLength.
+func NewKeyTree(prf PRF, key []byte) (*KeyTree, error) {
+	if err := CheckKeyLength(prf, len(key)); err != nil {
+		return nil, err
+	}
+	return &KeyTree{prf: prf, key: append([]byte(nil), key...)}, nil
+}
+
+func (t *KeyTree) childKeySize() uint32 {
+	if info, ok := t.prf.(PRFKeyInfo); ok {
+		return uint32(info.KeySize())
+	}
+	return t.prf.Len()
+}
+
+// Derive returns the descendant of this node that is identified by the
+// supplied path components, relative to this node. Calling Derive with several
+// components is equivalent to calling it once for each component in turn.
+func (t *KeyTree) Derive(path ...string) *KeyTree {
+	node := t
+	for _, component := range path {
+		var context ContextBuilder
+		context.AddUint32(uint32(len(node.path) + 1))
+		context.AddString(component)
+
+		node = &KeyTree{
+			prf:  t.prf,
+			key:  CounterModeKey(t.prf, node.key, keyTreeLabel, context.Bytes(), node.childKeySize()*8),
+			path: append(node.path[:len(node.path):len(node.path)], component)}
+	}
+	return node
+}
+
+// Path returns the path components that identify this node relative to the
+// root.
+func (t *KeyTree) Path() []string {
+	return append([]string(nil), t.path...)
+}
+
+// Key returns a copy of the key derivation key for this node, eg, for passing
+// to another system that derives keys from it.
+func (t *KeyTree) Key() []byte {
+	return append([]byte(nil), t.key...)
+}
+
+// DeriveKey derives a key of the specified length from the key derivation key
+// for this node in the same way as CounterModeKey, using the supplied label and
+// context.
+func (t *KeyTree) DeriveKey(label, context []byte, bitLength uint32) []byte {
+	return CounterModeKey(t.prf, t.key, label, context, bitLength)
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	"crypto"
+
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type treeSuite struct{}
+
+var _ = Suite(&treeSuite{})
+
+func (s *treeSuite) root(c *C) *KeyTree {
+	root, err := NewKeyTree(NewHMACPRF(crypto.SHA256), decodeHexString(c, hmacTestKey))
+	c.Assert(err, IsNil)
+	return root
+}
+
+func (s *treeSuite) TestDerive(c *C) {
+	prf := NewHMACPRF(crypto.SHA256)
+	node := s.root(c).Derive("tenants")
+
+	var context ContextBuilder
+	context.AddUint32(1).AddString("tenants")
+	expected := CounterModeKey(prf, decodeHexString(c, hmacTestKey), []byte("SP800-108 key tree"), context.Bytes(), 256)
+
+	c.Check(node.Key(), DeepEquals, expected)
+	c.Check(node.Path(), DeepEquals, []string{"tenants"})
+}
+
+func (s *treeSuite) TestDerivePath(c *C) {
+	root := s.root(c)
+	node1 := root.Derive("tenants", "1234", "storage")
+	node2 := root.Derive("tenants").Derive("1234").Derive("storage")
+
+	c.Check(node1.Key(), DeepEquals, node2.Key())
+	c.Check(node1.Path(), DeepEquals, []string{"tenants", "1234", "storage"})
+	c.Check(node2.Path(), DeepEquals, []string{"tenants", "1234", "storage"})
+}
+
+func (s *treeSuite) TestDomainSeparation(c *C) {
+	root := s.root(c)
+	keys := [][]byte{
+		root.Key(),
+		root.Derive("a").Key(),
+		root.Derive("ab").Key(),
+		root.Derive("a", "b").Key(),
+		root.Derive("b", "a").Key(),
+		root.Derive("", "ab").Key(),
+		root.Derive("ab", "").Key()}
+
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			c.Check(keys[i], Not(DeepEquals), keys[j], Commentf("%d and %d", i, j))
+		}
+	}
+}
+
+func (s *treeSuite) TestSiblingsDontShareParentPath(c *C) {
+	parent := s.root(c).Derive("tenants")
+	a := parent.Derive("a")
+	b := parent.Derive("b")
+	c.Check(a.Path(), DeepEquals, []string{"tenants", "a"})
+	c.Check(b.Path(), DeepEquals, []string{"tenants", "b"})
+}
+
+func (s *treeSuite) TestDeriveKey(c *C) {
+	node := s.root(c).Derive("tenants", "1234")
+	c.Check(node.DeriveKey([]byte("foo"), []byte("bar"), 128), DeepEquals,
+		CounterModeKey(NewHMACPRF(crypto.SHA256), node.Key(), []byte("foo"), []byte("bar"), 128))
+}
+
+func (s *treeSuite) TestCMAC(c *C) {
+	root, err := NewKeyTree(NewCMACAESPRF(16), make([]byte, 16))
+	c.Assert(err, IsNil)
+	c.Check(root.Derive("a", "b").Key(), HasLen, 16)
+}
+
+func (s *treeSuite) TestNewKeyTreeInvalidKey(c *C) {
+	_, err := NewKeyTree(NewCMACAESPRF(16), make([]byte, 24))
+	c.Check(err, ErrorMatches, `invalid key length 24 for CMAC-AES128 \(expected 16 bytes\)`)
+}
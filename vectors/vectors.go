@@ -0,0 +1,120 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+/*
+Package vectors generates test vectors for the key derivation functions defined in
+NIST SP-800-108, in the style of the NIST CAVP response files. This allows
+reference vectors to be produced for other implementations by running this
+package's implementation forwards.
+*/
+package vectors
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+)
+
+// Mode describes the key derivation mode that a vector was generated with.
+type Mode int
+
+const (
+	// CounterMode corresponds to the counter mode function.
+	CounterMode Mode = iota
+
+	// FeedbackMode corresponds to the feedback mode function with an
+	// iteration counter.
+	FeedbackMode
+
+	// FeedbackModeNoCounter corresponds to the feedback mode function
+	// without an iteration counter.
+	FeedbackModeNoCounter
+
+	// PipelineMode corresponds to the double-pipeline iteration mode function
+	// with an iteration counter.
+	PipelineMode
+
+	// PipelineModeNoCounter corresponds to the double-pipeline iteration mode
+	// function without an iteration counter.
+	PipelineModeNoCounter
+)
+
+func (m Mode) String() string {
+	switch m {
+	case CounterMode:
+		return "counter"
+	case FeedbackMode:
+		return "feedback"
+	case FeedbackModeNoCounter:
+		return "feedback-no-counter"
+	case PipelineMode:
+		return "pipeline"
+	case PipelineModeNoCounter:
+		return "pipeline-no-counter"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+func (m Mode) derive(prf kdf.PRF, key, fixed, iv []byte, bitLength uint32) []byte {
+	switch m {
+	case CounterMode:
+		return kdf.CounterModeKeyWithFixedData(prf, key, fixed, bitLength)
+	case FeedbackMode, FeedbackModeNoCounter:
+		return kdf.FeedbackModeKeyWithFixedData(prf, key, fixed, iv, bitLength, m == FeedbackMode)
+	case PipelineMode, PipelineModeNoCounter:
+		return kdf.PipelineModeKeyWithFixedData(prf, key, fixed, bitLength, m == PipelineMode)
+	default:
+		panic(fmt.Sprintf("invalid mode %v", m))
+	}
+}
+
+// Vector is a single test vector.
+type Vector struct {
+	Mode      Mode
+	BitLength uint32 // L
+	Key       []byte // KI
+	IV        []byte // Only used in feedback mode
+	FixedData []byte // FixedInputData
+	Expected  []byte // KO
+}
+
+// GenerateVector generates a test vector by deriving a key with the supplied PRF,
+// mode and input parameters. The iv argument is ignored for modes other than
+// feedback mode.
+func GenerateVector(prf kdf.PRF, mode Mode, key, fixed, iv []byte, bitLength uint32) *Vector {
+	if mode != FeedbackMode && mode != FeedbackModeNoCounter {
+		iv = nil
+	}
+	return &Vector{
+		Mode:      mode,
+		BitLength: bitLength,
+		Key:       key,
+		IV:        iv,
+		FixedData: fixed,
+		Expected:  mode.derive(prf, key, fixed, iv, bitLength)}
+}
+
+// Verify derives a key using the supplied PRF and the parameters of this vector,
+// and returns whether it matches the expected value.
+func (v *Vector) Verify(prf kdf.PRF) bool {
+	return subtle.ConstantTimeCompare(v.Mode.derive(prf, v.Key, v.FixedData, v.IV, v.BitLength), v.Expected) == 1
+}
+
+// String returns this vector in the format used by the NIST CAVP response files.
+func (v *Vector) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "L = %d\n", v.BitLength)
+	fmt.Fprintf(&b, "KI = %x\n", v.Key)
+	if v.Mode == FeedbackMode || v.Mode == FeedbackModeNoCounter {
+		fmt.Fprintf(&b, "IVlen = %d\n", len(v.IV)*8)
+		fmt.Fprintf(&b, "IV = %x\n", v.IV)
+	}
+	fmt.Fprintf(&b, "FixedInputDataByteLen = %d\n", len(v.FixedData))
+	fmt.Fprintf(&b, "FixedInputData = %x\n", v.FixedData)
+	fmt.Fprintf(&b, "KO = %x\n", v.Expected)
+	return b.String()
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package vectors_test
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	kdf "github.com/chrisccoulson/go-sp800.108-kdf"
+	. "github.com/chrisccoulson/go-sp800.108-kdf/vectors"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+func decodeHexString(c *C, s string) []byte {
+	x, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return x
+}
+
+type vectorsSuite struct{}
+
+var _ = Suite(&vectorsSuite{})
+
+func (s *vectorsSuite) TestGenerateCounterMode(c *C) {
+	// COUNT=0 from the HMAC_SHA256, BEFORE_FIXED, 32_BITS suite in KDFCTR_gen.rsp
+	prf := kdf.NewHMACPRF(crypto.SHA256)
+	v := GenerateVector(prf, CounterMode,
+		decodeHexString(c, "dd1d91b7d90b2bd3138533ce92b272fbf8a369316aefe242e659cc0ae238afe0"),
+		decodeHexString(c, "01322b96b30acd197979444e468e1c5c6859bf1b1cf951b7e725303e237e46b864a145fab25e517b08f8683d0315bb2911d80a0e8aba17f3b413faac"),
+		[]byte("ignored"), 128)
+	c.Check(v.Mode, Equals, CounterMode)
+	c.Check(v.IV, IsNil)
+	c.Check(v.Expected, DeepEquals, decodeHexString(c, "10621342bfb0fd40046c0e29f2cfdbf0"))
+	c.Check(v.Verify(prf), Equals, true)
+	c.Check(v.String(), Equals, `L = 128
+KI = dd1d91b7d90b2bd3138533ce92b272fbf8a369316aefe242e659cc0ae238afe0
+FixedInputDataByteLen = 60
+FixedInputData = 01322b96b30acd197979444e468e1c5c6859bf1b1cf951b7e725303e237e46b864a145fab25e517b08f8683d0315bb2911d80a0e8aba17f3b413faac
+KO = 10621342bfb0fd40046c0e29f2cfdbf0
+`)
+}
+
+func (s *vectorsSuite) TestGenerateFeedbackMode(c *C) {
+	// COUNT=0 from the HMAC_SHA256, AFTER_ITER, 32_BITS suite in
+	// FeedbackModeNOzeroiv/KDFFeedback_gen.rsp
+	prf := kdf.NewHMACPRF(crypto.SHA256)
+	v := GenerateVector(prf, FeedbackMode,
+		decodeHexString(c, "93f698e842eed75394d629d957e2e89c6e741f810b623c8b901e38376d068e7b"),
+		decodeHexString(c, "53b89c18690e2057a1d167822e636de50be0018532c431f7f5e37f77139220d5e042599ebe266af5767ee18cd2c5c19a1f0f80"),
+		decodeHexString(c, "9f575d9059d3e0c0803f08112f8a806de3c3471912cdf42b095388b14b33508e"), 512)
+	c.Check(v.Expected, DeepEquals, decodeHexString(c, "bd1476f43a4e315747cf5918e0ea5bc0d98769457477c3ab18b742def0e079a933b756365afb5541f253fee43c6fd788a44041038509e9eeb68f7d65ffbb5f95"))
+	c.Check(v.Verify(prf), Equals, true)
+	c.Check(v.String(), Equals, `L = 512
+KI = 93f698e842eed75394d629d957e2e89c6e741f810b623c8b901e38376d068e7b
+IVlen = 256
+IV = 9f575d9059d3e0c0803f08112f8a806de3c3471912cdf42b095388b14b33508e
+FixedInputDataByteLen = 51
+FixedInputData = 53b89c18690e2057a1d167822e636de50be0018532c431f7f5e37f77139220d5e042599ebe266af5767ee18cd2c5c19a1f0f80
+KO = bd1476f43a4e315747cf5918e0ea5bc0d98769457477c3ab18b742def0e079a933b756365afb5541f253fee43c6fd788a44041038509e9eeb68f7d65ffbb5f95
+`)
+}
+
+func (s *vectorsSuite) TestGeneratePipelineModes(c *C) {
+	prf := kdf.NewHMACPRF(crypto.SHA256)
+	key := []byte("1234567890123456")
+	fixed := []byte("fixed")
+
+	v := GenerateVector(prf, PipelineMode, key, fixed, nil, 1024)
+	c.Check(v.Expected, DeepEquals, kdf.PipelineModeKeyWithFixedData(prf, key, fixed, 1024, true))
+	c.Check(v.Verify(prf), Equals, true)
+
+	v = GenerateVector(prf, PipelineModeNoCounter, key, fixed, nil, 1024)
+	c.Check(v.Expected, DeepEquals, kdf.PipelineModeKeyWithFixedData(prf, key, fixed, 1024, false))
+	c.Check(v.Verify(prf), Equals, true)
+
+	v = GenerateVector(prf, FeedbackModeNoCounter, key, fixed, nil, 1024)
+	c.Check(v.Expected, DeepEquals, kdf.FeedbackModeKeyWithFixedData(prf, key, fixed, nil, 1024, false))
+	c.Check(v.Verify(prf), Equals, true)
+}
+
+func (s *vectorsSuite) TestVerifyMismatch(c *C) {
+	prf := kdf.NewHMACPRF(crypto.SHA256)
+	v := GenerateVector(prf, CounterMode, []byte("1234567890123456"), []byte("fixed"), nil, 256)
+	c.Check(v.Verify(kdf.NewHMACPRF(crypto.SHA224)), Equals, false)
+
+	v.Expected[0] ^= 0xff
+	c.Check(v.Verify(prf), Equals, false)
+}
+
+func (s *vectorsSuite) TestModeString(c *C) {
+	c.Check(CounterMode.String(), Equals, "counter")
+	c.Check(PipelineModeNoCounter.String(), Equals, "pipeline-no-counter")
+	c.Check(Mode(10).String(), Equals, "Mode(10)")
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	kwIV  = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+	kwpIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+	errUnwrapFailed = errors.New("key unwrap failed")
+)
+
+// wrapBlocks implements the wrapping process W from RFC 3394 with the supplied
+// initial value and plaintext, which must be a multiple of 8 bytes and at least
+// 16 bytes long.
+func wrapBlocks(c cipher.Block, iv, plaintext []byte) []byte {
+	n := len(plaintext) / 8
+	out := make([]byte, 8+len(plaintext))
+	copy(out, iv)
+	copy(out[8:], plaintext)
+
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[i*8:])
+			c.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[i*8:], b[8:])
+		}
+	}
+	return out
+}
+
+// unwrapBlocks implements the unwrapping process W^-1 from RFC 3394, returning
+// the recovered initial value and plaintext.
+func unwrapBlocks(c cipher.Block, ciphertext []byte) (iv, plaintext []byte) {
+	n := len(ciphertext)/8 - 1
+	var a [8]byte
+	copy(a[:], ciphertext)
+	r := append([]byte(nil), ciphertext[8:]...)
+
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a[:])^t)
+			copy(b[8:], r[(i-1)*8:])
+			c.Decrypt(b[:], b[:])
+			copy(a[:], b[:8])
+			copy(r[(i-1)*8:], b[8:])
+		}
+	}
+	return a[:], r
+}
+
+// aesKeyWrap wraps a key with AES-KW as defined in RFC 3394.
+func aesKeyWrap(c cipher.Block, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, fmt.Errorf("invalid length %d for AES-KW (must be a multiple of 8 bytes and at least 16 bytes)", len(key))
+	}
+	return wrapBlocks(c, kwIV, key), nil
+}
+
+// aesKeyUnwrap unwraps a key that was wrapped with AES-KW.
+func aesKeyUnwrap(c cipher.Block, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errUnwrapFailed
+	}
+	iv, key := unwrapBlocks(c, wrapped)
+	if subtle.ConstantTimeCompare(iv, kwIV) != 1 {
+		clear(key)
+		return nil, errUnwrapFailed
+	}
+	return key, nil
+}
+
+// aesKeyWrapPad wraps a key with AES-KWP as defined in RFC 5649.
+func aesKeyWrapPad(c cipher.Block, key []byte) ([]byte, error) {
+	if len(key) == 0 || uint64(len(key)) > 0xffffffff {
+		return nil, fmt.Errorf("invalid length %d for AES-KWP", len(key))
+	}
+	iv := binary.BigEndian.AppendUint32(append([]byte(nil), kwpIV...), uint32(len(key)))
+	padded := make([]byte, (len(key)+7)/8*8)
+	copy(padded, key)
+	defer clear(padded)
+
+	if len(padded) == 8 {
+		out := append(iv, padded...)
+		c.Encrypt(out, out)
+		return out, nil
+	}
+	return wrapBlocks(c, iv, padded), nil
+}
+
+// aesKeyUnwrapPad unwraps a key that was wrapped with AES-KWP.
+func aesKeyUnwrapPad(c cipher.Block, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errUnwrapFailed
+	}
+
+	var iv, padded []byte
+	if len(wrapped) == 16 {
+		b := make([]byte, 16)
+		c.Decrypt(b, wrapped)
+		iv, padded = b[:8], b[8:]
+	} else {
+		iv, padded = unwrapBlocks(c, wrapped)
+	}
+
+	mli := uint64(binary.BigEndian.Uint32(iv[4:]))
+	if subtle.ConstantTimeCompare(iv[:4], kwpIV) != 1 || mli+8 <= uint64(len(padded)) || mli > uint64(len(padded)) {
+		clear(padded)
+		return nil, errUnwrapFailed
+	}
+	var nonZero byte
+	for _, b := range padded[mli:] {
+		nonZero |= b
+	}
+	if nonZero != 0 {
+		clear(padded)
+		return nil, errUnwrapFailed
+	}
+	return padded[:mli:mli], nil
+}
+
+// deriveKEK derives an AES key-encryption key of the specified size in the same
+// way as DeriveCipher and passes the resulting cipher to the supplied function.
+func deriveKEK(params *Params, kekSize int, fn func(c cipher.Block) ([]byte, error)) ([]byte, error) {
+	c, err := DeriveCipher(params, kekSize)
+	if err != nil {
+		return nil, err
+	}
+	return fn(c)
+}
+
+// DeriveAndWrapKey derives an AES key-encryption key of the specified size in
+// bytes in the same way as DeriveCipher, and uses it to wrap the supplied key
+// with AES-KW as defined in RFC 3394. Only the wrapped key is returned, so the
+// key-encryption key is never exposed to the caller. The key to be wrapped must
+// be a multiple of 8 bytes and at least 16 bytes long. Use
+// DeriveAndWrapKeyWithPadding for keys of other lengths.
+func DeriveAndWrapKey(params *Params, kekSize int, key []byte) ([]byte, error) {
+	return deriveKEK(params, kekSize, func(c cipher.Block) ([]byte, error) {
+		return aesKeyWrap(c, key)
+	})
+}
+
+// DeriveAndUnwrapKey derives an AES key-encryption key in the same way as
+// DeriveAndWrapKey, and uses it to unwrap a key that was wrapped with AES-KW. An
+// error is returned if the integrity check fails.
+func DeriveAndUnwrapKey(params *Params, kekSize int, wrapped []byte) ([]byte, error) {
+	return deriveKEK(params, kekSize, func(c cipher.Block) ([]byte, error) {
+		return aesKeyUnwrap(c, wrapped)
+	})
+}
+
+// DeriveAndWrapKeyWithPadding derives an AES key-encryption key in the same way
+// as DeriveAndWrapKey, and uses it to wrap the supplied key with AES-KWP as
+// defined in RFC 5649, which supports keys of any non-zero length.
+func DeriveAndWrapKeyWithPadding(params *Params, kekSize int, key []byte) ([]byte, error) {
+	return deriveKEK(params, kekSize, func(c cipher.Block) ([]byte, error) {
+		return aesKeyWrapPad(c, key)
+	})
+}
+
+// DeriveAndUnwrapKeyWithPadding derives an AES key-encryption key in the same
+// way as DeriveAndWrapKey, and uses it to unwrap a key that was wrapped with
+// AES-KWP. An error is returned if the integrity check fails.
+func DeriveAndUnwrapKeyWithPadding(params *Params, kekSize int, wrapped []byte) ([]byte, error) {
+	return deriveKEK(params, kekSize, func(c cipher.Block) ([]byte, error) {
+		return aesKeyUnwrapPad(c, wrapped)
+	})
+}
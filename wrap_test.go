@@ -0,0 +1,138 @@
+// Copyright 2021 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package kdf_test
+
+import (
+	. "github.com/chrisccoulson/go-sp800.108-kdf"
+
+	. "gopkg.in/check.v1"
+)
+
+type wrapSuite struct{}
+
+var _ = Suite(&wrapSuite{})
+
+func (s *wrapSuite) testAESKeyWrap(c *C, kek, key, expected string) {
+	wrapped, err := AESKeyWrap(decodeHexString(c, kek), decodeHexString(c, key))
+	c.Check(err, IsNil)
+	c.Check(wrapped, DeepEquals, decodeHexString(c, expected))
+
+	unwrapped, err := AESKeyUnwrap(decodeHexString(c, kek), wrapped)
+	c.Check(err, IsNil)
+	c.Check(unwrapped, DeepEquals, decodeHexString(c, key))
+}
+
+// Test vectors from RFC 3394 section 4.
+
+func (s *wrapSuite) TestAESKeyWrap128With128(c *C) {
+	s.testAESKeyWrap(c, "000102030405060708090a0b0c0d0e0f", "00112233445566778899aabbccddeeff",
+		"1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5")
+}
+
+func (s *wrapSuite) TestAESKeyWrap256With256(c *C) {
+	s.testAESKeyWrap(c, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+		"28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21")
+}
+
+func (s *wrapSuite) TestAESKeyWrapInvalidLength(c *C) {
+	_, err := AESKeyWrap(make([]byte, 16), make([]byte, 12))
+	c.Check(err, ErrorMatches, `invalid length 12 for AES-KW \(must be a multiple of 8 bytes and at least 16 bytes\)`)
+}
+
+func (s *wrapSuite) TestAESKeyUnwrapIntegrityFailure(c *C) {
+	wrapped := decodeHexString(c, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5")
+	wrapped[0] ^= 1
+	_, err := AESKeyUnwrap(decodeHexString(c, "000102030405060708090a0b0c0d0e0f"), wrapped)
+	c.Check(err, ErrorMatches, "key unwrap failed")
+}
+
+func (s *wrapSuite) testAESKeyWrapPad(c *C, kek, key, expected string) {
+	wrapped, err := AESKeyWrapPad(decodeHexString(c, kek), decodeHexString(c, key))
+	c.Check(err, IsNil)
+	c.Check(wrapped, DeepEquals, decodeHexString(c, expected))
+
+	unwrapped, err := AESKeyUnwrapPad(decodeHexString(c, kek), wrapped)
+	c.Check(err, IsNil)
+	c.Check(unwrapped, DeepEquals, decodeHexString(c, key))
+}
+
+// Test vectors from RFC 5649 section 6.
+
+func (s *wrapSuite) TestAESKeyWrapPad20(c *C) {
+	s.testAESKeyWrapPad(c, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "c37b7e6492584340bed12207808941155068f738",
+		"138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a")
+}
+
+func (s *wrapSuite) TestAESKeyWrapPad7(c *C) {
+	s.testAESKeyWrapPad(c, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "466f7250617369",
+		"afbeb0f07dfbf5419200f2ccb50bb24f")
+}
+
+func (s *wrapSuite) TestAESKeyWrapPadEmpty(c *C) {
+	_, err := AESKeyWrapPad(make([]byte, 16), nil)
+	c.Check(err, ErrorMatches, "invalid length 0 for AES-KWP")
+}
+
+func (s *wrapSuite) TestAESKeyUnwrapPadIntegrityFailure(c *C) {
+	wrapped := decodeHexString(c, "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a")
+	wrapped[31] ^= 1
+	_, err := AESKeyUnwrapPad(decodeHexString(c, "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8"), wrapped)
+	c.Check(err, ErrorMatches, "key unwrap failed")
+}
+
+func (s *wrapSuite) TestAESKeyUnwrapPadInvalidLength(c *C) {
+	_, err := AESKeyUnwrapPad(make([]byte, 16), make([]byte, 20))
+	c.Check(err, ErrorMatches, "key unwrap failed")
+}
+
+func (s *wrapSuite) TestDeriveAndWrapKey(c *C) {
+	params := testParams()
+	key := decodeHexString(c, "00112233445566778899aabbccddeeff")
+
+	wrapped, err := DeriveAndWrapKey(params, 32, key)
+	c.Assert(err, IsNil)
+
+	kek := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 256)
+	expected, err := AESKeyWrap(kek, key)
+	c.Check(err, IsNil)
+	c.Check(wrapped, DeepEquals, expected)
+
+	unwrapped, err := DeriveAndUnwrapKey(params, 32, wrapped)
+	c.Check(err, IsNil)
+	c.Check(unwrapped, DeepEquals, key)
+}
+
+func (s *wrapSuite) TestDeriveAndWrapKeyWithPadding(c *C) {
+	params := testParams()
+	key := []byte("a short key")
+
+	wrapped, err := DeriveAndWrapKeyWithPadding(params, 16, key)
+	c.Assert(err, IsNil)
+
+	kek := CounterModeKey(params.PRF, params.Key, params.Label, params.Context, 128)
+	expected, err := AESKeyWrapPad(kek, key)
+	c.Check(err, IsNil)
+	c.Check(wrapped, DeepEquals, expected)
+
+	unwrapped, err := DeriveAndUnwrapKeyWithPadding(params, 16, wrapped)
+	c.Check(err, IsNil)
+	c.Check(unwrapped, DeepEquals, key)
+}
+
+func (s *wrapSuite) TestDeriveAndUnwrapKeyWrongKEK(c *C) {
+	params := testParams()
+	wrapped, err := DeriveAndWrapKey(params, 16, make([]byte, 32))
+	c.Assert(err, IsNil)
+
+	params.Context = []byte("baz")
+	_, err = DeriveAndUnwrapKey(params, 16, wrapped)
+	c.Check(err, ErrorMatches, "key unwrap failed")
+}
+
+func (s *wrapSuite) TestDeriveAndWrapKeyInvalidKEKSize(c *C) {
+	_, err := DeriveAndWrapKey(testParams(), 20, make([]byte, 16))
+	c.Check(err, ErrorMatches, "invalid AES key size 20")
+}